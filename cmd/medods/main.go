@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"os/signal"
+	"syscall"
+
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
 	"github.com/DKhorkov/medods/internal/app"
 	"github.com/DKhorkov/medods/internal/config"
 	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
 	"github.com/DKhorkov/medods/internal/database"
-	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
+	"github.com/DKhorkov/medods/internal/emails"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/notifiers"
 	"github.com/DKhorkov/medods/internal/repositories"
 	"github.com/DKhorkov/medods/internal/services"
 	"github.com/DKhorkov/medods/internal/usecases"
+	"github.com/DKhorkov/medods/internal/webhooks"
+	"github.com/DKhorkov/medods/internal/workers"
 )
 
 func main() {
@@ -20,37 +28,136 @@ func main() {
 		settings.Logging.LogFilePath,
 	)
 
+	// Waiting for database is interrupted by system signal, which is not handled by application yet:
+	connectCtx, stopConnecting := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	dbConnector, err := database.New(
-		settings.Databases.PostgreSQL,
+		connectCtx,
+		settings.Databases,
 		logger,
 	)
 
+	stopConnecting()
+
 	if err != nil {
 		panic(err)
 	}
 
-	defer dbConnector.CloseConnection()
+	err = repositories.BootstrapDatabase(
+		context.Background(),
+		settings.Databases,
+		settings.Emails.DefaultLocale,
+		dbConnector,
+		logger,
+	)
+
+	if err != nil {
+		panic(err)
+	}
 
-	authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
-	usersRepository := &mocks.MockedUsersRepository{}
-	authService := &services.CommonAuthService{AuthRepository: authRepository}
+	notifier, err := notifiers.New(settings.Notifications, settings.SMTP, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	// Notifications are persisted to outbox and delivered by dispatcher in background:
+	outboxRepository := &repositories.CommonOutboxRepository{DBConnector: dbConnector}
+	outboxNotifier := &notifiers.OutboxNotifier{OutboxRepository: outboxRepository}
+	outboxDispatcher := workers.NewOutboxDispatcher(
+		outboxRepository,
+		notifier,
+		settings.Notifications.Outbox,
+		logger,
+	)
+
+	eventPublisher := webhooks.NewPublisher(settings.Webhooks, logger)
+	authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+		settings.Databases,
+		settings.Cache,
+		dbConnector,
+		logger,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	defer closeAuthRepository()
+
+	usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+		settings.Users,
+		settings.Databases,
+		dbConnector,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	defer closeUsersRepository()
+
+	unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+	authService := &services.CommonAuthService{
+		AuthRepository: authRepository,
+		UnitOfWork:     unitOfWork,
+	}
 	usersService := &services.CommonUsersService{UsersRepository: usersRepository}
 	useCases := &usecases.CommonUseCases{
-		AuthService:  authService,
-		UsersService: usersService,
-		HashCost:     settings.Security.HashCost,
-		JWTConfig:    settings.Security.JWT,
-		SMTPConfig:   settings.SMTP,
-		Logger:       logger,
+		AuthService:    authService,
+		UsersService:   usersService,
+		HashCost:       settings.Security.HashCost,
+		JWTConfig:      settings.Security.JWT,
+		SessionConfig:  settings.Security.Session,
+		Notifier:       outboxNotifier,
+		EmailRenderer:  emails.NewRenderer(settings.Emails.TemplatesDirectory, settings.Emails.DefaultLocale),
+		EmailsConfig:   settings.Emails,
+		EventPublisher: eventPublisher,
+		UnitOfWork:     unitOfWork,
+		Logger:         logger,
+	}
+
+	adminUseCases := &usecases.CommonAdminUseCases{
+		AuthService:    authService,
+		JWTConfig:      settings.Security.JWT,
+		EventPublisher: eventPublisher,
+		Logger:         logger,
+	}
+
+	// Users of SSO are always active, so that only users of database are checked:
+	usersStatusCheckInterval := settings.Users.StatusCheckInterval
+	if settings.Users.Source == config.SSOUsersSource {
+		usersStatusCheckInterval = 0
 	}
 
+	disabledUsersRevoker := workers.NewDisabledUsersRevoker(useCases, usersStatusCheckInterval, logger)
+	databaseHealthProbe := workers.NewDatabaseHealthProbe(dbConnector, settings.Databases.HealthProbe, logger)
+	janitor := workers.NewJanitor(authRepository, outboxRepository, settings.Janitor, logger)
 	controller := httpcontroller.New(
-		settings.HTTP.Host,
-		settings.HTTP.Port,
+		settings.HTTP,
+		settings.Users,
 		useCases,
+		adminUseCases,
+		settings.Admin,
+		map[string]interfaces.HealthProbe{"database": databaseHealthProbe},
+		map[string]interfaces.StatusReporter{"janitor": janitor, "webhooks": eventPublisher},
 		logger,
 	)
 
-	application := app.New(controller)
+	// Workers are stopped in order, so that producers of events and notifications are stopped before workers, which
+	// deliver them, and nothing emitted during shutdown is dropped:
+	workersToRun := []interfaces.Worker{
+		disabledUsersRevoker,
+		janitor,
+		eventPublisher,
+		outboxDispatcher,
+		databaseHealthProbe,
+	}
+
+	// Snapshot is saved the last, after other workers have written to database:
+	if settings.Databases.Backend == config.MemoryDatabaseBackend {
+		workersToRun = append(
+			workersToRun,
+			workers.NewMemorySnapshotter(dbConnector, settings.Databases.Memory, logger),
+		)
+	}
+
+	application := app.New(controller, dbConnector, workersToRun...)
 	application.Run()
 }
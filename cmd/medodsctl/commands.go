@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/DKhorkov/medods/internal/services"
+	"github.com/DKhorkov/medods/internal/usecases"
+	"github.com/DKhorkov/medods/internal/workers"
+)
+
+// components are the same repositories, services and use cases, which are used by the server.
+type components struct {
+	dbConnector      *database.CommonDBConnector
+	authRepository   interfaces.AuthRepository
+	outboxRepository interfaces.OutboxRepository
+	useCases         *usecases.CommonUseCases
+	adminUseCases    *usecases.CommonAdminUseCases
+	close            func()
+}
+
+// newComponents connects to database and prepares it the same way, as the server does. In-memory database of
+// memory backend is a copy of the server one, restored from its snapshot, which is never saved back, so that only
+// read-only commands can be run with memory backend.
+func newComponents(ctx context.Context, settings *config.Config, logger *slog.Logger) (*components, error) {
+	dbConnector, err := database.New(ctx, settings.Databases, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repositories.BootstrapDatabase(ctx, settings.Databases, settings.Emails.DefaultLocale, dbConnector, logger)
+	if err != nil {
+		dbConnector.CloseConnection()
+		return nil, err
+	}
+
+	usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+		settings.Users,
+		settings.Databases,
+		dbConnector,
+	)
+	if err != nil {
+		dbConnector.CloseConnection()
+		return nil, err
+	}
+
+	authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+		settings.Databases,
+		settings.Cache,
+		dbConnector,
+		logger,
+	)
+	if err != nil {
+		closeUsersRepository()
+		dbConnector.CloseConnection()
+		return nil, err
+	}
+
+	authService := &services.CommonAuthService{
+		AuthRepository: authRepository,
+		UnitOfWork:     &database.CommonUnitOfWork{DBConnector: dbConnector},
+	}
+	usersService := &services.CommonUsersService{UsersRepository: usersRepository}
+	return &components{
+		dbConnector:      dbConnector,
+		authRepository:   authRepository,
+		outboxRepository: &repositories.CommonOutboxRepository{DBConnector: dbConnector},
+		useCases: &usecases.CommonUseCases{
+			AuthService:   authService,
+			UsersService:  usersService,
+			HashCost:      settings.Security.HashCost,
+			JWTConfig:     settings.Security.JWT,
+			SessionConfig: settings.Security.Session,
+			Logger:        logger,
+		},
+		adminUseCases: &usecases.CommonAdminUseCases{
+			AuthService: authService,
+			JWTConfig:   settings.Security.JWT,
+			Logger:      logger,
+		},
+		close: func() {
+			closeAuthRepository()
+			closeUsersRepository()
+			dbConnector.CloseConnection()
+		},
+	}, nil
+}
+
+// requirePersistentBackend rejects commands, which change data, for memory backend, because changes would be made
+// to copy of in-memory database of the server and lost.
+func requirePersistentBackend(settings *config.Config) error {
+	if settings.Databases.Backend != config.MemoryDatabaseBackend {
+		return nil
+	}
+
+	return customerrors.UnsupportedDatabaseBackendError{
+		Backend: settings.Databases.Backend,
+		Message: "command changes data, which is kept in memory of the server with database backend memory, " +
+			"so that it can not be run with it",
+	}
+}
+
+func runIssue(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("issue", flag.ExitOnError)
+	guid := flags.String("guid", "", "GUID of user")
+	ip := flags.String("ip", "127.0.0.1", "IP address, to which tokens are bound")
+	_ = flags.Parse(args)
+
+	if *guid == "" {
+		return customerrors.ParameterRequiredError{Parameter: "guid"}
+	}
+
+	if err := requirePersistentBackend(settings); err != nil {
+		return err
+	}
+
+	app, err := newComponents(ctx, settings, logger)
+	if err != nil {
+		return err
+	}
+
+	defer app.close()
+
+	tokens, err := app.useCases.CreateTokens(ctx, entities.CreateTokensDTO{GUID: *guid, IP: *ip})
+	if err != nil {
+		return err
+	}
+
+	// Refresh token is encoded the same way as in HTTP responses:
+	return printJSON(
+		map[string]string{
+			"accessToken":  tokens.AccessToken,
+			"refreshToken": security.Encode([]byte(tokens.RefreshToken)),
+		},
+	)
+}
+
+func runDecode(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	token := flags.String("token", "", "access, refresh (plain or base64 encoded) or revoke token")
+	_ = flags.Parse(args)
+
+	if *token == "" && flags.NArg() > 0 {
+		*token = flags.Arg(0)
+	}
+
+	if *token == "" {
+		return customerrors.ParameterRequiredError{Parameter: "token"}
+	}
+
+	payload, err := security.ParseJWT(*token, settings.Security.JWT.SecretKey)
+	if err != nil {
+		// Refresh tokens are returned to clients base64 encoded:
+		decoded, decodeErr := security.Decode(*token)
+		if decodeErr != nil {
+			return err
+		}
+
+		if payload, err = security.ParseJWT(string(decoded), settings.Security.JWT.SecretKey); err != nil {
+			return err
+		}
+	}
+
+	kind := "refresh"
+	if _, err = strconv.Atoi(payload.Value); err == nil {
+		kind = "access"
+	} else if strings.HasPrefix(payload.Value, "revoke:") {
+		kind = "revoke"
+	}
+
+	return printJSON(
+		map[string]any{
+			"kind":      kind,
+			"GUID":      payload.GUID,
+			"IP":        payload.IP,
+			"value":     payload.Value,
+			"issuedAt":  payload.IssuedAt,
+			"expiresAt": payload.ExpiresAt,
+		},
+	)
+}
+
+func runSessions(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(`sessions subcommand is required: "list" or "revoke"`)
+	}
+
+	switch args[0] {
+	case "list":
+		return runSessionsList(ctx, settings, logger, args[1:])
+	case "revoke":
+		return runSessionsRevoke(ctx, settings, logger, args[1:])
+	default:
+		return fmt.Errorf("unknown sessions subcommand %q", args[0])
+	}
+}
+
+func runSessionsList(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("sessions list", flag.ExitOnError)
+	guid := flags.String("guid", "", "list sessions of user with GUID")
+	ip := flags.String("ip", "", "search sessions by IP address")
+	limit := flags.Int("limit", settings.Admin.DefaultPageSize, "page size")
+	offset := flags.Int("offset", 0, "page offset")
+	_ = flags.Parse(args)
+
+	if *guid == "" && *ip == "" {
+		return customerrors.ParameterRequiredError{Parameter: "guid or ip"}
+	}
+
+	app, err := newComponents(ctx, settings, logger)
+	if err != nil {
+		return err
+	}
+
+	defer app.close()
+
+	pagination := entities.Pagination{Limit: *limit, Offset: *offset}
+	var page *entities.SessionsPage
+	if *guid != "" {
+		page, err = app.adminUseCases.GetUserSessions(ctx, *guid, pagination)
+	} else {
+		page, err = app.adminUseCases.SearchSessionsByIP(ctx, *ip, pagination)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return printJSON(page)
+}
+
+func runSessionsRevoke(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("sessions revoke", flag.ExitOnError)
+	id := flags.Int("id", 0, "revoke session with ID")
+	guid := flags.String("guid", "", "revoke all sessions of user with GUID")
+	_ = flags.Parse(args)
+
+	if *id == 0 && *guid == "" {
+		return customerrors.ParameterRequiredError{Parameter: "id or guid"}
+	}
+
+	if err := requirePersistentBackend(settings); err != nil {
+		return err
+	}
+
+	app, err := newComponents(ctx, settings, logger)
+	if err != nil {
+		return err
+	}
+
+	defer app.close()
+
+	if *id != 0 {
+		if err = app.adminUseCases.RevokeSession(ctx, *id); err != nil {
+			return err
+		}
+
+		return printJSON(map[string]int{"revoked": 1})
+	}
+
+	revoked, err := app.adminUseCases.RevokeAllUserSessions(ctx, *guid)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(map[string]int{"revoked": revoked})
+}
+
+func runPurge(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	retention := flags.Duration(
+		"retention",
+		settings.Janitor.Retention,
+		"keep revoked refresh tokens and delivered or dead notifications for retention",
+	)
+
+	_ = flags.Parse(args)
+
+	if err := requirePersistentBackend(settings); err != nil {
+		return err
+	}
+
+	app, err := newComponents(ctx, settings, logger)
+	if err != nil {
+		return err
+	}
+
+	defer app.close()
+
+	janitorConfig := settings.Janitor
+	janitorConfig.Retention = *retention
+	purged, err := workers.NewJanitor(app.authRepository, app.outboxRepository, janitorConfig, logger).Purge(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(
+		map[string]int{
+			"refreshTokens":       purged.RefreshTokens,
+			"accessTokensDenials": purged.Denials,
+			"outboxMessages":      purged.Outbox,
+		},
+	)
+}
+
+func runKeygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	size := flags.Int("size", 32, "key size in bytes")
+	_ = flags.Parse(args)
+
+	if *size <= 0 {
+		return customerrors.InvalidParameterError{Parameter: "size"}
+	}
+
+	key, err := security.GenerateSecretKey(*size)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key)
+	return nil
+}
+
+func runMigrate(ctx context.Context, settings *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return errors.New(`migrate subcommand is required: "up", "down" or "status"`)
+	}
+
+	if err := requirePersistentBackend(settings); err != nil {
+		return err
+	}
+
+	dbConnector, err := database.New(ctx, settings.Databases, logger)
+	if err != nil {
+		return err
+	}
+
+	defer dbConnector.CloseConnection()
+
+	migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(map[string]int{"applied": applied})
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			fmt.Printf("%-10s %s\n", status.State, filepath.Base(status.Source.Path))
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", flags.Arg(0))
+	}
+}
+
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
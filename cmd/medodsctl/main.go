@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/DKhorkov/medods/internal/config"
+)
+
+const usage = `medodsctl is a command line tool for medods operational tasks.
+
+Usage:
+  medodsctl <command> [flags]
+
+Commands:
+  issue      issue tokens for GUID
+  decode     decode and verify token with configured key
+  sessions   list or revoke sessions ("sessions list", "sessions revoke")
+  purge      delete expired refresh tokens, revoked ones after retention and expired access tokens denials
+  keygen     generate signing key
+  migrate    run database migrations ("migrate up", "migrate down", "migrate status")
+
+Run "medodsctl <command> -h" for command flags. Configuration is read from the same environment variables
+as the server.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	settings := config.New()
+
+	// Logs are written to stderr, so that they do not mix with command output:
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	// Interrupted command cancels its database queries:
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	var err error
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "issue":
+		err = runIssue(ctx, settings, logger, args)
+	case "decode":
+		err = runDecode(settings, args)
+	case "sessions":
+		err = runSessions(ctx, settings, logger, args)
+	case "purge":
+		err = runPurge(ctx, settings, logger, args)
+	case "keygen":
+		err = runKeygen(args)
+	case "migrate":
+		err = runMigrate(ctx, settings, logger, args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -3,30 +3,69 @@ package app
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/DKhorkov/medods/internal/interfaces"
 )
 
 type App struct {
-	controller interfaces.Controller
+	controller  interfaces.Controller
+	dbConnector interfaces.DBConnector
+	workers     []interfaces.Worker
+	stopChannel chan struct{}
+	stopOnce    sync.Once
 }
 
+// Run starts controller and workers and blocks until system signal is received or Stop is called. Then
+// application is stopped in order: controller drains requests in progress, which may still produce work for
+// workers, workers complete their work in the order they were provided, and only then database connection,
+// which is used by both, is closed.
 func (application *App) Run() {
 	// Launch asynchronous for graceful shutdown purpose:
 	go application.controller.Run()
+	for _, worker := range application.workers {
+		go worker.Run()
+	}
 
 	// Graceful shutdown. When system signal will be received, signal.Notify function will write it to channel.
-	// After this event, main goroutine will be unblocked (<-stopChannel blocks it) and application will be
-	// gracefully stopped:
-	stopChannel := make(chan os.Signal, 1)
-	signal.Notify(stopChannel, syscall.SIGINT, syscall.SIGTERM)
-	<-stopChannel
+	// After this event, main goroutine will be unblocked and application will be gracefully stopped:
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChannel)
+
+	select {
+	case <-signalChannel:
+	case <-application.stopChannel:
+	}
+
 	application.controller.Stop()
+	for _, worker := range application.workers {
+		worker.Stop()
+	}
+
+	if application.dbConnector != nil {
+		application.dbConnector.CloseConnection()
+	}
+}
+
+// Stop unblocks Run, which stops application the same way, as on system signal.
+func (application *App) Stop() {
+	application.stopOnce.Do(func() {
+		close(application.stopChannel)
+	})
 }
 
-func New(controller interfaces.Controller) *App {
+// New creates an instance of App. Database connection is closed by App after controller and workers are stopped.
+func New(
+	controller interfaces.Controller,
+	dbConnector interfaces.DBConnector,
+	workers ...interfaces.Worker,
+) *App {
 	return &App{
-		controller: controller,
+		controller:  controller,
+		dbConnector: dbConnector,
+		workers:     workers,
+		stopChannel: make(chan struct{}),
 	}
 }
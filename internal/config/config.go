@@ -3,9 +3,11 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/entities"
 
 	"github.com/DKhorkov/hmtm-bff/pkg/loadenv"
 )
@@ -15,6 +17,12 @@ func New() *Config {
 		HTTP: HTTPConfig{
 			Host: loadenv.GetEnv("HOST", "0.0.0.0"),
 			Port: loadenv.GetEnvAsInt("PORT", 8070),
+			RequestTimeout: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("HTTP_REQUEST_TIMEOUT", 10),
+			),
+			DrainTimeout: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("HTTP_DRAIN_TIMEOUT", 15),
+			),
 		},
 		Security: SecurityConfig{
 			HashCost: loadenv.GetEnvAsInt("HASH_COST", 8), // Auth speed sensitive if large
@@ -28,8 +36,26 @@ func New() *Config {
 				Algorithm: loadenv.GetEnv("JWT_ALGORITHM", "HS256"),
 				SecretKey: loadenv.GetEnv("JWT_SECRET", "defaultSecret"),
 			},
+			Session: SessionConfig{
+				MaxAge: time.Hour * time.Duration(
+					loadenv.GetEnvAsInt("SESSION_MAX_AGE", 720), // 30 days
+				),
+				IdleTimeout: time.Hour * time.Duration(
+					loadenv.GetEnvAsInt("SESSION_IDLE_TIMEOUT", 24),
+				),
+				ReuseGracePeriod: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("SESSION_REUSE_GRACE_PERIOD", 10),
+				),
+			},
+		},
+		Admin: AdminConfig{
+			Token:           loadenv.GetEnv("ADMIN_TOKEN", ""),
+			DefaultPageSize: loadenv.GetEnvAsInt("ADMIN_DEFAULT_PAGE_SIZE", 20),
+			MaxPageSize:     loadenv.GetEnvAsInt("ADMIN_MAX_PAGE_SIZE", 100),
 		},
 		Databases: DatabasesConfig{
+			Backend:        loadenv.GetEnv("DATABASE_BACKEND", PostgreSQLDatabaseBackend),
+			MigrateOnStart: loadenv.GetEnvAsBool("DATABASE_MIGRATE_ON_START", false),
 			PostgreSQL: DatabaseConfig{
 				Host:         loadenv.GetEnv("POSTGRES_HOST", "0.0.0.0"),
 				Port:         loadenv.GetEnvAsInt("POSTGRES_PORT", 5432),
@@ -38,7 +64,75 @@ func New() *Config {
 				DatabaseName: loadenv.GetEnv("POSTGRES_DB", "postgres"),
 				SSLMode:      loadenv.GetEnv("POSTGRES_SSL_MODE", "disable"),
 				Driver:       loadenv.GetEnv("POSTGRES_DRIVER", "postgres"),
+				ReplicaHosts: parseList(loadenv.GetEnv("POSTGRES_REPLICA_HOSTS", "")),
+			},
+			MySQL: DatabaseConfig{
+				Host:         loadenv.GetEnv("MYSQL_HOST", "0.0.0.0"),
+				Port:         loadenv.GetEnvAsInt("MYSQL_PORT", 3306),
+				User:         loadenv.GetEnv("MYSQL_USER", "mysql"),
+				Password:     loadenv.GetEnv("MYSQL_PASSWORD", "mysql"),
+				DatabaseName: loadenv.GetEnv("MYSQL_DB", "medods"),
+				Driver:       loadenv.GetEnv("MYSQL_DRIVER", "mysql"),
+				ReplicaHosts: parseList(loadenv.GetEnv("MYSQL_REPLICA_HOSTS", "")),
+			},
+			SQLite: DatabaseConfig{
+				DatabaseName: loadenv.GetEnv("SQLITE_PATH", "medods.db"),
+				Driver:       loadenv.GetEnv("SQLITE_DRIVER", "sqlite3"),
+			},
+			Memory: MemoryStoreConfig{
+				SnapshotPath: loadenv.GetEnv("MEMORY_SNAPSHOT_PATH", ""),
+				SnapshotInterval: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("MEMORY_SNAPSHOT_INTERVAL", 60),
+				),
+				UsersPath: loadenv.GetEnv("MEMORY_USERS_PATH", ""),
+			},
+			Pool: DatabasePoolConfig{
+				MaxOpenConnections: loadenv.GetEnvAsInt("DATABASE_MAX_OPEN_CONNECTIONS", 25),
+				MaxIdleConnections: loadenv.GetEnvAsInt("DATABASE_MAX_IDLE_CONNECTIONS", 5),
+				ConnectionMaxLifetime: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("DATABASE_CONNECTION_MAX_LIFETIME", 300),
+				),
 			},
+			Connect: DatabaseConnectConfig{
+				Timeout: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("DATABASE_CONNECT_TIMEOUT", 5),
+				),
+				MaxAttempts: loadenv.GetEnvAsInt("DATABASE_CONNECT_MAX_ATTEMPTS", 5),
+				BaseBackoff: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("DATABASE_CONNECT_BASE_BACKOFF", 1),
+				),
+			},
+			HealthProbe: DatabaseHealthProbeConfig{
+				Interval: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("DATABASE_HEALTH_PROBE_INTERVAL", 10),
+				),
+				Timeout: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("DATABASE_HEALTH_PROBE_TIMEOUT", 2),
+				),
+			},
+		},
+		Cache: CacheConfig{
+			Address:  loadenv.GetEnv("REDIS_ADDRESS", ""),
+			Password: loadenv.GetEnv("REDIS_PASSWORD", ""),
+			DB:       loadenv.GetEnvAsInt("REDIS_DB", 0),
+			TTL: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("CACHE_TTL", 60),
+			),
+			Timeout: time.Millisecond * time.Duration(
+				loadenv.GetEnvAsInt("CACHE_TIMEOUT", 100),
+			),
+			FailureBackoff: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("CACHE_FAILURE_BACKOFF", 5),
+			),
+		},
+		Janitor: JanitorConfig{
+			Interval: time.Minute * time.Duration(
+				loadenv.GetEnvAsInt("JANITOR_INTERVAL", 60),
+			),
+			Retention: time.Hour * time.Duration(
+				loadenv.GetEnvAsInt("JANITOR_RETENTION", 168), // 7 days
+			),
+			BatchSize: loadenv.GetEnvAsInt("JANITOR_BATCH_SIZE", 1000),
 		},
 		Logging: LoggingConfig{
 			Level:       logging.LogLevels.DEBUG,
@@ -50,12 +144,81 @@ func New() *Config {
 			Login:    loadenv.GetEnv("SMTP_LOGIN", "smtp"),
 			Password: loadenv.GetEnv("SMTP_PASSWORD", "smtp"),
 		},
+		Emails: EmailsConfig{
+			TemplatesDirectory: loadenv.GetEnv("EMAILS_TEMPLATES_DIRECTORY", ""),
+			DefaultLocale:      loadenv.GetEnv("EMAILS_DEFAULT_LOCALE", "ru"),
+			RevokeLinkURL:      loadenv.GetEnv("EMAILS_REVOKE_LINK_URL", "http://0.0.0.0:8070/sessions/revoke"),
+			RevokeLinkTTL: time.Hour * time.Duration(
+				loadenv.GetEnvAsInt("EMAILS_REVOKE_LINK_TTL", 24),
+			),
+		},
+		Webhooks: WebhooksConfig{
+			Subscriptions: parseWebhookSubscriptions(loadenv.GetEnv("WEBHOOKS_SUBSCRIPTIONS", "")),
+			Timeout: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("WEBHOOKS_TIMEOUT", 5),
+			),
+			MaxAttempts: loadenv.GetEnvAsInt("WEBHOOKS_MAX_ATTEMPTS", 5),
+			BaseBackoff: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("WEBHOOKS_BASE_BACKOFF", 1),
+			),
+		},
+		Users: UsersConfig{
+			Source: loadenv.GetEnv("USERS_SOURCE", DatabaseUsersSource),
+			StatusCheckInterval: time.Second * time.Duration(
+				loadenv.GetEnvAsInt("USERS_STATUS_CHECK_INTERVAL", 60),
+			),
+			SSO: SSOConfig{
+				Host: loadenv.GetEnv("SSO_HOST", "0.0.0.0"),
+				Port: loadenv.GetEnvAsInt("SSO_PORT", 8060),
+				Timeout: time.Millisecond * time.Duration(
+					loadenv.GetEnvAsInt("SSO_TIMEOUT", 500),
+				),
+				MaxAttempts: loadenv.GetEnvAsInt("SSO_MAX_ATTEMPTS", 3),
+				RetryBackoff: time.Millisecond * time.Duration(
+					loadenv.GetEnvAsInt("SSO_RETRY_BACKOFF", 100),
+				),
+				CacheTTL: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("SSO_CACHE_TTL", 60),
+				),
+				CacheSize: loadenv.GetEnvAsInt("SSO_CACHE_SIZE", 10000),
+			},
+		},
+		Notifications: NotificationsConfig{
+			Channels: strings.Split(loadenv.GetEnv("NOTIFICATIONS_CHANNELS", "smtp,log"), ","),
+			Webhook: WebhookConfig{
+				URL: loadenv.GetEnv("NOTIFICATIONS_WEBHOOK_URL", ""),
+				Timeout: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("NOTIFICATIONS_WEBHOOK_TIMEOUT", 5),
+				),
+			},
+			Outbox: OutboxConfig{
+				PollInterval: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("OUTBOX_POLL_INTERVAL", 5),
+				),
+				BatchSize:   loadenv.GetEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+				MaxAttempts: loadenv.GetEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+				BaseBackoff: time.Second * time.Duration(
+					loadenv.GetEnvAsInt("OUTBOX_BASE_BACKOFF", 10),
+				),
+				MaxBackoff: time.Minute * time.Duration(
+					loadenv.GetEnvAsInt("OUTBOX_MAX_BACKOFF", 60),
+				),
+				Lease: time.Minute * time.Duration(
+					loadenv.GetEnvAsInt("OUTBOX_LEASE", 5),
+				),
+			},
+		},
 	}
 }
 
+// HTTPConfig configures HTTP server. RequestTimeout cancels work of request, including database queries,
+// if request is handled for too long. Zero value disables timeout. On shutdown requests in progress are given
+// DrainTimeout to complete, after which their connections are closed.
 type HTTPConfig struct {
-	Host string
-	Port int
+	Host           string
+	Port           int
+	RequestTimeout time.Duration
+	DrainTimeout   time.Duration
 }
 
 type JWTConfig struct {
@@ -65,9 +228,27 @@ type JWTConfig struct {
 	AccessTokenTTL  time.Duration
 }
 
+// SessionConfig limits lifetime of a session, which starts with tokens creation and continues with every refresh.
+// MaxAge is counted from the moment of session creation and IdleTimeout from the last refresh.
+// Rotated refresh token, presented again within ReuseGracePeriod after rotation, is considered to be retry of
+// the client instead of reuse. Zero value disables corresponding check.
+type SessionConfig struct {
+	MaxAge           time.Duration
+	IdleTimeout      time.Duration
+	ReuseGracePeriod time.Duration
+}
+
 type SecurityConfig struct {
 	HashCost int
 	JWT      JWTConfig
+	Session  SessionConfig
+}
+
+// AdminConfig configures admin API, which is available only, if Token is provided.
+type AdminConfig struct {
+	Token           string
+	DefaultPageSize int
+	MaxPageSize     int
 }
 
 type DatabaseConfig struct {
@@ -78,12 +259,87 @@ type DatabaseConfig struct {
 	DatabaseName string
 	SSLMode      string
 	Driver       string
+
+	// ReplicaHosts are addresses ("host" or "host:port") of read replicas, which share credentials of primary.
+	ReplicaHosts []string
 }
 
+const (
+	PostgreSQLDatabaseBackend = "postgres"
+	SQLiteDatabaseBackend     = "sqlite"
+	MySQLDatabaseBackend      = "mysql"
+	MemoryDatabaseBackend     = "memory"
+)
+
+// DatabasesConfig describes databases. Backend selects, which of them is used. For SQLite DatabaseName is a path
+// to database file. Memory backend keeps all data in in-memory SQLite database, which is always migrated on start
+// and has the only connection, so that it runs one transaction at a time. If MigrateOnStart is set, embedded
+// migrations are applied on start.
 type DatabasesConfig struct {
-	PostgreSQL DatabaseConfig
-	MySQL      DatabaseConfig
-	SQLite     DatabaseConfig
+	Backend        string
+	MigrateOnStart bool
+	PostgreSQL     DatabaseConfig
+	MySQL          DatabaseConfig
+	SQLite         DatabaseConfig
+	Memory         MemoryStoreConfig
+	Pool           DatabasePoolConfig
+	Connect        DatabaseConnectConfig
+	HealthProbe    DatabaseHealthProbeConfig
+}
+
+// DatabasePoolConfig limits connections pool of database. Zero values keep defaults of database/sql.
+type DatabasePoolConfig struct {
+	MaxOpenConnections    int
+	MaxIdleConnections    int
+	ConnectionMaxLifetime time.Duration
+}
+
+// DatabaseConnectConfig configures check of database availability on start. Database is pinged up to
+// MaxAttempts times with Timeout, waiting exponentially growing from BaseBackoff time between attempts.
+type DatabaseConnectConfig struct {
+	Timeout     time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration
+}
+
+// DatabaseHealthProbeConfig configures periodic ping of database, which result is used for readiness check.
+// Non-positive Interval is replaced with default one.
+type DatabaseHealthProbeConfig struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// MemoryStoreConfig configures in-memory database of memory backend. If SnapshotPath is set, database is restored
+// from it on start and saved to it every SnapshotInterval and on shutdown. Expired and revoked refresh tokens are
+// purged by janitor, as in other backends. Users are seeded from JSON file at UsersPath on start, since in-memory
+// database is empty, unless it is restored.
+type MemoryStoreConfig struct {
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	UsersPath        string
+}
+
+// CacheConfig configures Redis cache of refresh tokens and access tokens denylist. Empty Address disables cache.
+// Entries live at most TTL, which bounds staleness, if invalidation has been lost while cache was unavailable.
+// Timeout is applied to every cache call, after which database is used instead. After failed call cache is not read
+// for FailureBackoff, so that requests do not wait for Timeout while Redis is down. Non-positive FailureBackoff
+// disables backoff.
+type CacheConfig struct {
+	Address        string
+	Password       string
+	DB             int
+	TTL            time.Duration
+	Timeout        time.Duration
+	FailureBackoff time.Duration
+}
+
+// JanitorConfig configures purge of expired rows. Revoked refresh tokens, delivered and dead notifications are
+// kept for Retention for investigation purposes. Non-positive Interval disables periodic purge and non-positive
+// BatchSize is replaced with default one.
+type JanitorConfig struct {
+	Interval  time.Duration
+	Retention time.Duration
+	BatchSize int
 }
 
 type LoggingConfig struct {
@@ -98,10 +354,129 @@ type SMTPConfig struct {
 	Password string
 }
 
+// EmailsConfig configures rendering of emails. Templates from TemplatesDirectory override embedded ones.
+// RevokeLinkURL is a public URL of sessions revocation endpoint, which is sent to users in security emails.
+type EmailsConfig struct {
+	TemplatesDirectory string
+	DefaultLocale      string
+	RevokeLinkURL      string
+	RevokeLinkTTL      time.Duration
+}
+
+type WebhookConfig struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// OutboxConfig configures delivery of notifications, persisted to outbox. Lease is the time, during which
+// claimed message can not be claimed by another dispatcher, and should exceed the longest delivery.
+// Non-positive PollInterval and BatchSize are replaced with default ones.
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Lease        time.Duration
+}
+
+// NotificationsConfig describes channels ("smtp", "webhook", "log"), through which users are notified.
+type NotificationsConfig struct {
+	Channels []string
+	Webhook  WebhookConfig
+	Outbox   OutboxConfig
+}
+
+// WebhookSubscription describes endpoint, which receives security events of provided types, signed with Secret.
+type WebhookSubscription struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+type WebhooksConfig struct {
+	Subscriptions []WebhookSubscription
+	Timeout       time.Duration
+	MaxAttempts   int
+	BaseBackoff   time.Duration
+}
+
+const (
+	DatabaseUsersSource = "database"
+	SSOUsersSource      = "sso"
+)
+
+// SSOConfig configures hmtm-sso gRPC client. Timeout is applied to every attempt, RetryBackoff is doubled after
+// every failed attempt. Users are cached for CacheTTL, zero value disables cache. At most CacheSize users are
+// cached, non-positive CacheSize is replaced with default one.
+type SSOConfig struct {
+	Host         string
+	Port         int
+	Timeout      time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	CacheTTL     time.Duration
+	CacheSize    int
+}
+
+// UsersConfig describes, where users are stored: in own database ("database") or in hmtm-sso service ("sso").
+// Sessions of users, disabled in database, are revoked every StatusCheckInterval. Non-positive StatusCheckInterval
+// disables periodic check, so that sessions are revoked only on the next request of user.
+type UsersConfig struct {
+	Source              string
+	StatusCheckInterval time.Duration
+	SSO                 SSOConfig
+}
+
 type Config struct {
-	HTTP      HTTPConfig
-	Security  SecurityConfig
-	Databases DatabasesConfig
-	Logging   LoggingConfig
-	SMTP      SMTPConfig
+	HTTP          HTTPConfig
+	Security      SecurityConfig
+	Admin         AdminConfig
+	Databases     DatabasesConfig
+	Cache         CacheConfig
+	Janitor       JanitorConfig
+	Logging       LoggingConfig
+	SMTP          SMTPConfig
+	Emails        EmailsConfig
+	Users         UsersConfig
+	Notifications NotificationsConfig
+	Webhooks      WebhooksConfig
+}
+
+// parseWebhookSubscriptions parses subscriptions in "<url>|<secret>|<event>;<event>" format, separated by
+// commas. If events are not provided, subscription receives all events. Invalid subscriptions are skipped.
+func parseWebhookSubscriptions(value string) []WebhookSubscription {
+	var subscriptions []WebhookSubscription
+	for _, rawSubscription := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(rawSubscription), "|")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+
+		subscription := WebhookSubscription{
+			URL:    parts[0],
+			Secret: parts[1],
+			Events: []string{entities.AllSecurityEventsWildcard},
+		}
+
+		if len(parts) > 2 && parts[2] != "" {
+			subscription.Events = strings.Split(parts[2], ";")
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions
+}
+
+// parseList parses comma separated values. Empty values are skipped.
+func parseList(value string) []string {
+	var values []string
+	for _, rawValue := range strings.Split(value, ",") {
+		if rawValue = strings.TrimSpace(rawValue); rawValue != "" {
+			values = append(values, rawValue)
+		}
+	}
+
+	return values
 }
@@ -0,0 +1,226 @@
+package httpcontroller
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/requestid"
+)
+
+const (
+	// adminTokenHeader contains static token of support team, which is compared with configured one.
+	adminTokenHeader = "X-Admin-Token"
+
+	// metricsContentType is content type of Prometheus text exposition format.
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type AdminHandler struct {
+	UseCases        interfaces.AdminUseCases
+	Config          config.AdminConfig
+	StatusReporters map[string]interfaces.StatusReporter
+
+	// UsersSource defines format of GUIDs, accepted from clients: numeric IDs for "sso" and UUIDs otherwise.
+	UsersSource string
+	Logger      *slog.Logger
+}
+
+// Authenticate allows request only with valid admin token.
+func (handler AdminHandler) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		token := request.Header.Get(adminTokenHeader)
+		if handler.Config.Token == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(handler.Config.Token)) != 1 {
+			requestid.Logger(request.Context(), handler.Logger).Warn(
+				"Admin authentication failed",
+				"RemoteAddr",
+				request.RemoteAddr,
+			)
+
+			renderProblem(writer, request, customerrors.HeaderError{Header: adminTokenHeader})
+			return
+		}
+
+		next(writer, request)
+	}
+}
+
+// GetSessionsHandleFunc returns handler, which lists sessions of user by "guid" or searches them by "ip".
+func (handler AdminHandler) GetSessionsHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		pagination, err := handler.getPagination(request)
+		if err != nil {
+			renderProblem(writer, request, err)
+			return
+		}
+
+		var page *entities.SessionsPage
+		query := request.URL.Query()
+		switch {
+		case query.Get("guid") != "":
+			if err = validateGUID("guid", query.Get("guid"), handler.UsersSource); err != nil {
+				renderProblem(writer, request, err)
+				return
+			}
+
+			page, err = handler.UseCases.GetUserSessions(request.Context(), query.Get("guid"), pagination)
+		case query.Get("ip") != "":
+			page, err = handler.UseCases.SearchSessionsByIP(request.Context(), query.Get("ip"), pagination)
+		default:
+			renderProblem(writer, request, customerrors.ParameterRequiredError{Parameter: "guid or ip"})
+			return
+		}
+
+		if err != nil {
+			logRequestError(request, handler.Logger, "Getting sessions error", logging.GetLogTraceback(), err)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, page)
+	}
+}
+
+// GetRevokeSessionHandleFunc returns handler, which revokes session with "id" path value.
+func (handler AdminHandler) GetRevokeSessionHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil {
+			renderProblem(writer, request, customerrors.InvalidParameterError{Parameter: "id"})
+			return
+		}
+
+		if err = handler.UseCases.RevokeSession(request.Context(), id); err != nil {
+			logRequestError(request, handler.Logger, "Revoking session error", logging.GetLogTraceback(), err)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, map[string]string{"message": "session has been revoked"})
+	}
+}
+
+// GetRevokeUserSessionsHandleFunc returns handler, which revokes all sessions of user with "guid" path value.
+func (handler AdminHandler) GetRevokeUserSessionsHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := validateGUID("guid", request.PathValue("guid"), handler.UsersSource); err != nil {
+			renderProblem(writer, request, err)
+			return
+		}
+
+		revoked, err := handler.UseCases.RevokeAllUserSessions(request.Context(), request.PathValue("guid"))
+		if err != nil {
+			logRequestError(request, handler.Logger, "Revoking user sessions error", logging.GetLogTraceback(), err)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, map[string]int{"revoked": revoked})
+	}
+}
+
+// GetExpireAccessTokensHandleFunc returns handler, which force-expires access tokens of user with "guid"
+// path value.
+func (handler AdminHandler) GetExpireAccessTokensHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := validateGUID("guid", request.PathValue("guid"), handler.UsersSource); err != nil {
+			renderProblem(writer, request, err)
+			return
+		}
+
+		if err := handler.UseCases.ExpireUserAccessTokens(request.Context(), request.PathValue("guid")); err != nil {
+			logRequestError(request, handler.Logger, "Expiring access tokens error", logging.GetLogTraceback(), err)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, map[string]string{"message": "access tokens have been expired"})
+	}
+}
+
+// GetStatusHandleFunc returns handler, which reports state of background components by their names.
+func (handler AdminHandler) GetStatusHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		statuses := make(map[string]any, len(handler.StatusReporters))
+		for name, reporter := range handler.StatusReporters {
+			statuses[name] = reporter.Status()
+		}
+
+		renderJSON(writer, request, statuses)
+	}
+}
+
+// GetMetricsHandleFunc returns handler, which writes metrics of status reporters, which report them, in Prometheus
+// text exposition format. Reporters are written in order of their names, so that output is stable.
+func (handler AdminHandler) GetMetricsHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		names := make([]string, 0, len(handler.StatusReporters))
+		for name := range handler.StatusReporters {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		var buffer bytes.Buffer
+		for _, name := range names {
+			reporter, ok := handler.StatusReporters[name].(interfaces.MetricsReporter)
+			if !ok {
+				continue
+			}
+
+			if err := reporter.WriteMetrics(&buffer); err != nil {
+				logRequestError(request, handler.Logger, "Writing metrics error", logging.GetLogTraceback(), err)
+
+				renderProblem(writer, request, err)
+				return
+			}
+		}
+
+		writer.Header().Set("Content-Type", metricsContentType)
+
+		// Response has been already started, so client is gone, if writing fails:
+		_, _ = writer.Write(buffer.Bytes())
+	}
+}
+
+// getPagination reads "limit" and "offset" query parameters. Limit is capped by MaxPageSize.
+func (handler AdminHandler) getPagination(request *http.Request) (entities.Pagination, error) {
+	pagination := entities.Pagination{Limit: handler.Config.DefaultPageSize}
+	query := request.URL.Query()
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 {
+			return pagination, customerrors.InvalidParameterError{Parameter: "limit"}
+		}
+
+		pagination.Limit = limit
+	}
+
+	if rawOffset := query.Get("offset"); rawOffset != "" {
+		offset, err := strconv.Atoi(rawOffset)
+		if err != nil || offset < 0 {
+			return pagination, customerrors.InvalidParameterError{Parameter: "offset"}
+		}
+
+		pagination.Offset = offset
+	}
+
+	if handler.Config.MaxPageSize > 0 && pagination.Limit > handler.Config.MaxPageSize {
+		pagination.Limit = handler.Config.MaxPageSize
+	}
+
+	return pagination, nil
+}
@@ -1,33 +1,35 @@
 package httpcontroller
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
 	"github.com/DKhorkov/medods/internal/interfaces"
 )
 
 type Controller struct {
-	httpServer *http.ServeMux
-	host       string
-	port       int
-	logger     *slog.Logger
+	httpServer   *http.Server
+	drainTimeout time.Duration
+	logger       *slog.Logger
 }
 
-// Run HTTP server.
+// Run HTTP server until Stop is called.
 func (controller *Controller) Run() {
 	controller.logger.Info(
-		fmt.Sprintf("Starting HTTP Server at http://%s:%d", controller.host, controller.port),
+		fmt.Sprintf("Starting HTTP Server at http://%s", controller.httpServer.Addr),
 		"Traceback",
 		logging.GetLogTraceback(),
 	)
 
-	if err := http.ListenAndServe(
-		fmt.Sprintf("%s:%d", controller.host, controller.port),
-		controller.httpServer,
-	); err != nil {
+	if err := controller.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		controller.logger.Error(
 			"Error occurred while listening to HTTP Server",
 			"Traceback",
@@ -41,20 +43,94 @@ func (controller *Controller) Run() {
 	controller.logger.Info("Stopped serving new connections.")
 }
 
-// Stop HTTP server gracefully (graceful shutdown).
+// Stop HTTP server gracefully (graceful shutdown): new connections are refused, while requests in progress are
+// waited for up to drain timeout. Connections, which are still active after timeout, are closed.
 func (controller *Controller) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), controller.drainTimeout)
+	defer cancel()
+
+	if err := controller.httpServer.Shutdown(ctx); err != nil {
+		controller.logger.Error(
+			"Failed to drain HTTP connections",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		_ = controller.httpServer.Close()
+		return
+	}
+
 	controller.logger.Info("Graceful shutdown completed.")
 }
 
-// New creates an instance of HTTP Controller.
-func New(host string, port int, useCases interfaces.UseCases, logger *slog.Logger) *Controller {
+// New creates an instance of HTTP Controller. Admin API is served only, if admin token is configured.
+// Health probes are reported by readiness endpoint and status reporters, including their metrics, by admin API.
+// Users source defines format of accepted GUIDs. Every request passes through middlewares, which assign request ID,
+// log it, recover from panics and set security headers.
+func New(
+	httpConfig config.HTTPConfig,
+	usersConfig config.UsersConfig,
+	useCases interfaces.UseCases,
+	adminUseCases interfaces.AdminUseCases,
+	adminConfig config.AdminConfig,
+	healthProbes map[string]interfaces.HealthProbe,
+	statusReporters map[string]interfaces.StatusReporter,
+	logger *slog.Logger,
+) *Controller {
 	server := http.NewServeMux()
-	server.HandleFunc("/tokens", TokensHandler{UseCases: useCases, Logger: logger}.GetHandleFunc())
+	tokensHandler := TokensHandler{UseCases: useCases, UsersSource: usersConfig.Source, Logger: logger}
+	server.HandleFunc("/tokens", tokensHandler.GetHandleFunc())
+	server.HandleFunc("/tokens/validate", tokensHandler.GetValidateHandleFunc())
+	server.HandleFunc("/sessions/revoke", SessionsHandler{UseCases: useCases, Logger: logger}.GetRevokeHandleFunc())
+	server.HandleFunc("GET /readyz", ReadinessHandler{Probes: healthProbes, Logger: logger}.GetHandleFunc())
+
+	if adminConfig.Token != "" {
+		adminHandler := AdminHandler{
+			UseCases:        adminUseCases,
+			Config:          adminConfig,
+			StatusReporters: statusReporters,
+			UsersSource:     usersConfig.Source,
+			Logger:          logger,
+		}
+
+		server.HandleFunc("GET /admin/status", adminHandler.Authenticate(adminHandler.GetStatusHandleFunc()))
+		server.HandleFunc("GET /admin/metrics", adminHandler.Authenticate(adminHandler.GetMetricsHandleFunc()))
+		server.HandleFunc(
+			"GET /admin/sessions",
+			adminHandler.Authenticate(adminHandler.GetSessionsHandleFunc()),
+		)
+		server.HandleFunc(
+			"DELETE /admin/sessions/{id}",
+			adminHandler.Authenticate(adminHandler.GetRevokeSessionHandleFunc()),
+		)
+		server.HandleFunc(
+			"DELETE /admin/users/{guid}/sessions",
+			adminHandler.Authenticate(adminHandler.GetRevokeUserSessionsHandleFunc()),
+		)
+		server.HandleFunc(
+			"POST /admin/users/{guid}/access-tokens/expire",
+			adminHandler.Authenticate(adminHandler.GetExpireAccessTokensHandleFunc()),
+		)
+	}
 
 	return &Controller{
-		httpServer: server,
-		port:       port,
-		host:       host,
-		logger:     logger,
+		httpServer: &http.Server{
+			Addr: net.JoinHostPort(httpConfig.Host, strconv.Itoa(httpConfig.Port)),
+			Handler: Chain(
+				server,
+				RequestID(),
+				Recovery(logger),
+				AccessLog(logger),
+				SecurityHeaders(),
+				RequestTimeout(httpConfig.RequestTimeout),
+			),
+
+			// Protects from clients, which hold connections by sending headers slowly:
+			ReadHeaderTimeout: httpConfig.RequestTimeout,
+		},
+		drainTimeout: httpConfig.DrainTimeout,
+		logger:       logger,
 	}
 }
@@ -2,12 +2,14 @@ package httpcontroller
 
 import (
 	"errors"
+	"html/template"
 	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
 
 	"github.com/DKhorkov/medods/internal/entities"
 	"github.com/DKhorkov/medods/internal/interfaces"
@@ -16,22 +18,16 @@ import (
 
 type TokensHandler struct {
 	UseCases interfaces.UseCases
-	Logger   *slog.Logger
+
+	// UsersSource defines format of GUIDs, accepted from clients: numeric IDs for "sso" and UUIDs otherwise.
+	UsersSource string
+	Logger      *slog.Logger
 }
 
 func (handler TokensHandler) GetHandleFunc() func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		handler.Logger.Info(
-			"Tokens request received",
-			"Method", request.Method,
-			"URL", request.URL,
-			"RequestURI", request.RequestURI,
-			"UserAgent", request.UserAgent(),
-			"RemoteAddr", request.RemoteAddr,
-		)
-
 		if request.Method != http.MethodPost && request.Method != http.MethodPut {
-			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			renderProblem(writer, request, customerrors.MethodNotAllowedError{Method: request.Method})
 			return
 		}
 
@@ -55,13 +51,14 @@ func (handler TokensHandler) createTokensHandler(
 ) {
 	var requestBody map[string]string
 	if err := getRequestBody(request, handler.Logger, &requestBody); err != nil {
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
+		return
 	}
 
 	guid, found := requestBody["GUID"]
 	if !found {
 		err := customerrors.ParameterRequiredError{Parameter: "GUID"}
-		handler.Logger.Error(
+		requestid.Logger(request.Context(), handler.Logger).Error(
 			"Parameter required",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -69,7 +66,12 @@ func (handler TokensHandler) createTokensHandler(
 			err,
 		)
 
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
+		return
+	}
+
+	if err := validateGUID("GUID", guid, handler.UsersSource); err != nil {
+		renderProblem(writer, request, err)
 		return
 	}
 
@@ -78,38 +80,27 @@ func (handler TokensHandler) createTokensHandler(
 		IP:   getUserIP(request),
 	}
 
-	tokens, err := handler.UseCases.CreateTokens(data)
+	tokens, err := handler.UseCases.CreateTokens(request.Context(), data)
 	if err != nil {
-		handler.Logger.Error(
-			"JSON decoding error",
-			"Traceback",
-			logging.GetLogTraceback(),
-			"Error",
-			err,
-		)
+		logRequestError(request, handler.Logger, "Creating tokens error", logging.GetLogTraceback(), err)
 
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
 		return
 	}
 
 	tokens.RefreshToken = security.Encode([]byte(tokens.RefreshToken))
 	writer.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
-	renderJSON(writer, map[string]string{"refreshToken": tokens.RefreshToken})
+	renderJSON(writer, request, map[string]string{"refreshToken": tokens.RefreshToken})
 }
 
 func (handler TokensHandler) refreshTokensHandler(
 	writer http.ResponseWriter,
 	request *http.Request,
 ) {
-	var requestBody map[string]string
-	if err := getRequestBody(request, handler.Logger, &requestBody); err != nil {
-		http.Error(writer, err.Error(), http.StatusBadRequest)
-	}
-
 	authorizationHeader := request.Header.Get("Authorization")
 	if authorizationHeader == "" {
 		err := customerrors.HeaderError{Header: "Authorization"}
-		handler.Logger.Error(
+		requestid.Logger(request.Context(), handler.Logger).Error(
 			"Authorization header required",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -117,14 +108,14 @@ func (handler TokensHandler) refreshTokensHandler(
 			err,
 		)
 
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
 		return
 	}
 
 	authorizationHeaderValues := strings.Split(authorizationHeader, " ")
 	if len(authorizationHeaderValues) != 2 || authorizationHeaderValues[0] != "Bearer" {
 		err := customerrors.HeaderError{Header: "Authorization"}
-		handler.Logger.Error(
+		requestid.Logger(request.Context(), handler.Logger).Error(
 			"Authorization header is invalid",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -132,16 +123,22 @@ func (handler TokensHandler) refreshTokensHandler(
 			err,
 		)
 
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
 		return
 	}
 
 	accessToken := authorizationHeaderValues[1]
 
+	var requestBody map[string]string
+	if err := getRequestBody(request, handler.Logger, &requestBody); err != nil {
+		renderProblem(writer, request, err)
+		return
+	}
+
 	encodedRefreshToken, found := requestBody["refreshToken"]
 	if !found {
 		err := customerrors.ParameterRequiredError{Parameter: "refreshToken"}
-		handler.Logger.Error(
+		requestid.Logger(request.Context(), handler.Logger).Error(
 			"Parameter required",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -149,13 +146,13 @@ func (handler TokensHandler) refreshTokensHandler(
 			err,
 		)
 
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, err)
 		return
 	}
 
 	refreshToken, err := security.Decode(encodedRefreshToken)
 	if err != nil {
-		handler.Logger.Error(
+		requestid.Logger(request.Context(), handler.Logger).Error(
 			"Refresh token decoding error",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -163,7 +160,7 @@ func (handler TokensHandler) refreshTokensHandler(
 			err,
 		)
 
-		http.Error(writer, customerrors.InvalidJWTError{}.Error(), http.StatusBadRequest)
+		renderProblem(writer, request, customerrors.InvalidJWTError{})
 		return
 	}
 
@@ -172,30 +169,135 @@ func (handler TokensHandler) refreshTokensHandler(
 			AccessToken:  accessToken,
 			RefreshToken: string(refreshToken),
 		},
-		IP: getUserIP(request),
+		IP:        getUserIP(request),
+		UserAgent: request.UserAgent(),
+		Location:  getUserLocation(request),
 	}
 
-	tokens, err := handler.UseCases.RefreshTokens(data)
+	tokens, err := handler.UseCases.RefreshTokens(request.Context(), data)
 	if err != nil {
-		handler.Logger.Error(
-			"Refreshing tokens error",
-			"Traceback",
-			logging.GetLogTraceback(),
-			"Error",
-			err,
-		)
+		logRequestError(request, handler.Logger, "Refreshing tokens error", logging.GetLogTraceback(), err)
 
-		var possibleError customerrors.AccessTokenDoesNotBelongToRefreshTokenError
-		if errors.As(err, &possibleError) {
-			http.Error(writer, possibleError.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(writer, customerrors.InvalidJWTError{}.Error(), http.StatusBadRequest)
+		// Refresh token is not found, if it has been reused or its session has been revoked, which makes
+		// tokens of client invalid:
+		var refreshTokenNotFoundError customerrors.RefreshTokenNotFoundError
+		if errors.As(err, &refreshTokenNotFoundError) {
+			err = customerrors.InvalidJWTError{}
 		}
 
+		renderProblem(writer, request, err)
 		return
 	}
 
 	tokens.RefreshToken = security.Encode([]byte(tokens.RefreshToken))
 	writer.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
-	renderJSON(writer, map[string]string{"refreshToken": tokens.RefreshToken})
+	renderJSON(writer, request, map[string]string{"refreshToken": tokens.RefreshToken})
+}
+
+// GetValidateHandleFunc returns handler, which checks access token from Authorization header for services,
+// trusting tokens of this one.
+func (handler TokensHandler) GetValidateHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			renderProblem(writer, request, customerrors.MethodNotAllowedError{Method: request.Method})
+			return
+		}
+
+		authorizationHeaderValues := strings.Split(request.Header.Get("Authorization"), " ")
+		if len(authorizationHeaderValues) != 2 || authorizationHeaderValues[0] != "Bearer" {
+			renderProblem(writer, request, customerrors.HeaderError{Header: "Authorization"})
+			return
+		}
+
+		guid, err := handler.UseCases.ValidateAccessToken(request.Context(), authorizationHeaderValues[1])
+		if err != nil {
+			requestid.Logger(request.Context(), handler.Logger).Warn(
+				"Access token validation failed",
+				"Error",
+				err,
+			)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, map[string]string{"GUID": guid})
+	}
+}
+
+type SessionsHandler struct {
+	UseCases interfaces.UseCases
+	Logger   *slog.Logger
+}
+
+// revokeConfirmationPage asks user to confirm revocation of session. Form has no action, so that it is submitted
+// to the same URL, and revoke token is posted with it.
+var revokeConfirmationPage = template.Must(
+	template.New("revokeConfirmation").Parse(
+		`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>End session</title>
+</head>
+<body>
+<p>Someone tried to refresh your session from unknown IP address. End the session, if it wasn't you.</p>
+<form method="post">
+<input type="hidden" name="token" value="{{ . }}">
+<button type="submit">End session</button>
+</form>
+</body>
+</html>
+`,
+	),
+)
+
+// GetRevokeHandleFunc returns handler for link from security emails, which ends user session. Links are opened
+// by mail scanners and link previews too, so that GET only shows page, which asks to confirm revocation, and
+// session is revoked, when confirmation form is submitted with POST.
+func (handler SessionsHandler) GetRevokeHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var revokeToken string
+		switch request.Method {
+		case http.MethodGet:
+			revokeToken = request.URL.Query().Get("token")
+		case http.MethodPost:
+			revokeToken = request.PostFormValue("token")
+		default:
+			renderProblem(writer, request, customerrors.MethodNotAllowedError{Method: request.Method})
+			return
+		}
+
+		if revokeToken == "" {
+			err := customerrors.ParameterRequiredError{Parameter: "token"}
+			requestid.Logger(request.Context(), handler.Logger).Error(
+				"Parameter required",
+				"Traceback",
+				logging.GetLogTraceback(),
+				"Error",
+				err,
+			)
+
+			renderProblem(writer, request, err)
+			return
+		}
+
+		if request.Method == http.MethodGet {
+			writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+			// Response has been already started, so client is gone, if writing fails:
+			_ = revokeConfirmationPage.Execute(writer, revokeToken)
+			return
+		}
+
+		if err := handler.UseCases.RevokeTokens(request.Context(), revokeToken); err != nil {
+			logRequestError(request, handler.Logger, "Revoking tokens error", logging.GetLogTraceback(), err)
+
+			// Session has already ended, if refresh token is not found:
+			renderProblem(writer, request, err)
+			return
+		}
+
+		renderJSON(writer, request, map[string]string{"message": "session has been revoked"})
+	}
 }
@@ -0,0 +1,62 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// ReadinessHandler reports, whether service is ready to serve requests. Service is ready, if every dependency,
+// checked by Probes, is healthy. Only state of dependencies is reported, while errors, which may disclose
+// addresses and credentials of dependencies, are logged.
+type ReadinessHandler struct {
+	Probes map[string]interfaces.HealthProbe
+	Logger *slog.Logger
+}
+
+const (
+	healthyCheck   = "healthy"
+	unhealthyCheck = "unhealthy"
+)
+
+type readinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (handler ReadinessHandler) GetHandleFunc() func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		response := readinessResponse{Ready: true, Checks: make(map[string]string, len(handler.Probes))}
+		for name, probe := range handler.Probes {
+			status := probe.Health()
+			if status.Healthy {
+				response.Checks[name] = healthyCheck
+				continue
+			}
+
+			handler.Logger.Warn("Dependency is unhealthy", "Dependency", name, "Error", status.Error)
+			response.Checks[name] = unhealthyCheck
+			response.Ready = false
+		}
+
+		statusCode := http.StatusOK
+		if !response.Ready {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(statusCode)
+		if err := json.NewEncoder(writer).Encode(response); err != nil {
+			handler.Logger.Error(
+				"Failed to write readiness response",
+				"Traceback",
+				logging.GetLogTraceback(),
+				"Error",
+				err,
+			)
+		}
+	}
+}
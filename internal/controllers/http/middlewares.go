@@ -0,0 +1,214 @@
+package httpcontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/DKhorkov/medods/internal/security"
+)
+
+const RequestIDHeader = requestid.Header
+
+// validRequestID limits request IDs, accepted from clients, so that they are safe to log and to return.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware wraps handler with behaviour, which is common for all requests.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler with middlewares. The first middleware is the outermost one and handles request first.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// GetRequestID returns ID of request, which is handled with ctx, or empty string, if there is no one.
+func GetRequestID(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// RequestID takes request ID from X-Request-ID header of request or generates new one, if header is missing or
+// invalid. Request ID is available through GetRequestID and is returned in X-Request-ID header of response. It is
+// added to logs of handlers and use cases and forwarded to other services, which are called while handling request.
+func RequestID() Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				requestID := request.Header.Get(RequestIDHeader)
+				if !validRequestID.MatchString(requestID) {
+					// UUID generation fails only, if system random source is broken, which ID is not worth failing for:
+					requestID, _ = security.GenerateUUID()
+				}
+
+				writer.Header().Set(RequestIDHeader, requestID)
+				handler.ServeHTTP(writer, request.WithContext(requestid.NewContext(request.Context(), requestID)))
+			},
+		)
+	}
+}
+
+// AccessLog logs every handled request with its status and latency. Requests, which handler panics on before
+// response is started, are logged with status 500, which Recovery responds with.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				startedAt := time.Now()
+				recorder := &responseRecorder{ResponseWriter: writer}
+				var completed bool
+				defer func() {
+					status := recorder.getStatus()
+					if !completed && !recorder.wroteHeader {
+						status = http.StatusInternalServerError
+					}
+
+					logger.Info(
+						"HTTP request handled",
+						"RequestID", GetRequestID(request.Context()),
+						"Method", request.Method,
+						"Path", request.URL.Path,
+						"Status", status,
+						"Bytes", recorder.written,
+						"Latency", time.Since(startedAt),
+						"IP", getUserIP(request),
+						"UserAgent", request.UserAgent(),
+					)
+				}()
+
+				handler.ServeHTTP(recorder, request)
+				completed = true
+			},
+		)
+	}
+}
+
+// Recovery responds with problem 500, if handler panics, so that client receives response and panic is
+// logged. If response has been already started, connection is aborted instead.
+func Recovery(logger *slog.Logger) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				recorder := &responseRecorder{ResponseWriter: writer}
+				defer func() {
+					recovered := recover()
+					if recovered == nil {
+						return
+					}
+
+					// Used by net/http to abort response on purpose:
+					if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+						panic(recovered)
+					}
+
+					requestID := GetRequestID(request.Context())
+					logger.Error(
+						"Panic occurred while handling HTTP request",
+						"Traceback",
+						logging.GetLogTraceback(),
+						"RequestID",
+						requestID,
+						"Panic",
+						recovered,
+						"Stack",
+						string(debug.Stack()),
+					)
+
+					if recorder.wroteHeader {
+						panic(http.ErrAbortHandler)
+					}
+
+					renderProblem(writer, request, fmt.Errorf("panic: %v", recovered))
+				}()
+
+				handler.ServeHTTP(recorder, request)
+			},
+		)
+	}
+}
+
+// SecurityHeaders forbids browsers to sniff content types, to frame responses and to cache them, because
+// responses contain tokens.
+func SecurityHeaders() Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				headers := writer.Header()
+				headers.Set("X-Content-Type-Options", "nosniff")
+				headers.Set("X-Frame-Options", "DENY")
+				headers.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+				headers.Set("Referrer-Policy", "no-referrer")
+				headers.Set("Cache-Control", "no-store")
+				handler.ServeHTTP(writer, request)
+			},
+		)
+	}
+}
+
+// RequestTimeout cancels context of request after timeout, so that database queries and calls to other
+// services, made while handling request, are cancelled too.
+func RequestTimeout(timeout time.Duration) Middleware {
+	return func(handler http.Handler) http.Handler {
+		if timeout <= 0 {
+			return handler
+		}
+
+		return http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				ctx, cancel := context.WithTimeout(request.Context(), timeout)
+				defer cancel()
+
+				handler.ServeHTTP(writer, request.WithContext(ctx))
+			},
+		)
+	}
+}
+
+// responseRecorder remembers status and size of response, written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	written     int
+	wroteHeader bool
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	if !recorder.wroteHeader {
+		recorder.WriteHeader(http.StatusOK)
+	}
+
+	written, err := recorder.ResponseWriter.Write(data)
+	recorder.written += written
+	return written, err
+}
+
+// Unwrap lets http.ResponseController reach underlying writer.
+func (recorder *responseRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+func (recorder *responseRecorder) getStatus() int {
+	if !recorder.wroteHeader {
+		return http.StatusOK
+	}
+
+	return recorder.status
+}
@@ -0,0 +1,172 @@
+package httpcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+
+	// problemTypePrefix forms URI of problem type from its code, as RFC 7807 requires type to be URI.
+	problemTypePrefix = "urn:medods:problem:"
+
+	// statusClientClosedRequest is non-standard status, which is used by nginx for requests, cancelled by client.
+	statusClientClosedRequest = 499
+)
+
+// Problem is RFC 7807 problem details object, which is returned for all failed requests. Code is stable and
+// machine-readable, so clients should rely on it instead of Detail, which is human-readable.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// problemDefinition describes, how error of some type is presented to client.
+type problemDefinition struct {
+	code   string
+	status int
+	title  string
+}
+
+const internalErrorTitle = "Internal server error"
+
+// getProblemDefinition maps error to problem, which is returned to client. Errors of unknown types are internal.
+func getProblemDefinition(err error) problemDefinition {
+	var (
+		methodNotAllowedError                       customerrors.MethodNotAllowedError
+		invalidRequestBodyError                     customerrors.InvalidRequestBodyError
+		parameterRequiredError                      customerrors.ParameterRequiredError
+		invalidParameterError                       customerrors.InvalidParameterError
+		headerError                                 customerrors.HeaderError
+		invalidJWTError                             customerrors.InvalidJWTError
+		jwtClaimsError                              customerrors.JWTClaimsError
+		accessTokenRevokedError                     customerrors.AccessTokenRevokedError
+		accessTokenDoesNotBelongToRefreshTokenError customerrors.AccessTokenDoesNotBelongToRefreshTokenError
+		sessionExpiredError                         customerrors.SessionExpiredError
+		sessionIdleTimeoutError                     customerrors.SessionIdleTimeoutError
+		ipAddressDoesNotMatchWithTokensIPError      customerrors.IPAddressDoesNotMatchWithTokensIPError
+		userNotFoundError                           customerrors.UserNotFoundError
+		userDisabledError                           customerrors.UserDisabledError
+		userLockedError                             customerrors.UserLockedError
+		refreshTokenNotFoundError                   customerrors.RefreshTokenNotFoundError
+		refreshTokenRotatedError                    customerrors.RefreshTokenRotatedError
+		invalidRefreshTokenTTLError                 customerrors.InvalidRefreshTokenTTLError
+		nilDBConnectionError                        customerrors.NilDBConnectionError
+		databaseUnavailableError                    customerrors.DatabaseUnavailableError
+		tooManyRequestsError                        customerrors.TooManyRequestsError
+		unsupportedDatabaseBackendError             customerrors.UnsupportedDatabaseBackendError
+		unsupportedUsersSourceError                 customerrors.UnsupportedUsersSourceError
+		unknownNotificationChannelError             customerrors.UnknownNotificationChannelError
+		webhookDeliveryError                        customerrors.WebhookDeliveryError
+	)
+
+	switch {
+	case errors.As(err, &methodNotAllowedError):
+		return problemDefinition{"method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed"}
+	case errors.As(err, &invalidRequestBodyError):
+		return problemDefinition{"invalid_request_body", http.StatusBadRequest, "Invalid request body"}
+	case errors.As(err, &parameterRequiredError):
+		return problemDefinition{"parameter_required", http.StatusBadRequest, "Parameter required"}
+	case errors.As(err, &invalidParameterError):
+		return problemDefinition{"invalid_parameter", http.StatusBadRequest, "Invalid parameter"}
+	case errors.As(err, &headerError):
+		return problemDefinition{"invalid_header", http.StatusUnauthorized, "Missing or invalid header"}
+	case errors.As(err, &invalidJWTError):
+		return problemDefinition{"invalid_token", http.StatusUnauthorized, "Invalid token"}
+	case errors.As(err, &jwtClaimsError):
+		return problemDefinition{"invalid_token_claims", http.StatusUnauthorized, "Invalid token claims"}
+	case errors.As(err, &accessTokenRevokedError):
+		return problemDefinition{"access_token_revoked", http.StatusUnauthorized, "Access token revoked"}
+	case errors.As(err, &accessTokenDoesNotBelongToRefreshTokenError):
+		return problemDefinition{"token_pair_mismatch", http.StatusUnauthorized, "Tokens do not match"}
+	case errors.As(err, &sessionExpiredError):
+		return problemDefinition{"session_expired", http.StatusUnauthorized, "Session expired"}
+	case errors.As(err, &sessionIdleTimeoutError):
+		return problemDefinition{"session_idle_timeout", http.StatusUnauthorized, "Session idle timeout"}
+	case errors.As(err, &ipAddressDoesNotMatchWithTokensIPError):
+		return problemDefinition{"ip_address_mismatch", http.StatusForbidden, "IP address mismatch"}
+	case errors.As(err, &userNotFoundError):
+		return problemDefinition{"user_not_found", http.StatusForbidden, "User not found"}
+	case errors.As(err, &userDisabledError):
+		return problemDefinition{"user_disabled", http.StatusForbidden, "User disabled"}
+	case errors.As(err, &userLockedError):
+		return problemDefinition{"user_locked", http.StatusForbidden, "User locked"}
+	case errors.As(err, &refreshTokenNotFoundError):
+		return problemDefinition{"session_not_found", http.StatusNotFound, "Session not found"}
+	case errors.As(err, &refreshTokenRotatedError):
+		return problemDefinition{"refresh_token_rotated", http.StatusConflict, "Refresh token already rotated"}
+	case errors.As(err, &tooManyRequestsError):
+		return problemDefinition{"too_many_requests", http.StatusTooManyRequests, "Too many requests"}
+	case errors.As(err, &nilDBConnectionError), errors.As(err, &databaseUnavailableError):
+		return problemDefinition{"database_unavailable", http.StatusServiceUnavailable, "Service unavailable"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return problemDefinition{"request_timeout", http.StatusGatewayTimeout, "Request timed out"}
+	case errors.Is(err, context.Canceled):
+		return problemDefinition{"request_cancelled", statusClientClosedRequest, "Request cancelled"}
+	case errors.As(err, &invalidRefreshTokenTTLError):
+		return problemDefinition{"invalid_refresh_token_ttl", http.StatusInternalServerError, internalErrorTitle}
+	case errors.As(err, &unsupportedDatabaseBackendError):
+		return problemDefinition{"unsupported_database", http.StatusInternalServerError, internalErrorTitle}
+	case errors.As(err, &unsupportedUsersSourceError):
+		return problemDefinition{"unsupported_users_source", http.StatusInternalServerError, internalErrorTitle}
+	case errors.As(err, &unknownNotificationChannelError), errors.As(err, &webhookDeliveryError):
+		return problemDefinition{"notification_failed", http.StatusInternalServerError, internalErrorTitle}
+	default:
+		return problemDefinition{"internal_error", http.StatusInternalServerError, internalErrorTitle}
+	}
+}
+
+// newProblem creates problem for error, which occurred while handling request. Details of server errors are not
+// disclosed to client, as they are logged instead.
+func newProblem(request *http.Request, err error) Problem {
+	definition := getProblemDefinition(err)
+	problem := Problem{
+		Type:      problemTypePrefix + definition.code,
+		Title:     definition.title,
+		Status:    definition.status,
+		Instance:  request.URL.Path,
+		Code:      definition.code,
+		RequestID: GetRequestID(request.Context()),
+	}
+
+	if definition.status < http.StatusInternalServerError {
+		problem.Detail = err.Error()
+	}
+
+	return problem
+}
+
+// logRequestError logs error, with which request has failed, with request ID. Requests, cancelled by client, are
+// not failures of the service, so that they are logged with lower level.
+func logRequestError(request *http.Request, logger *slog.Logger, message, traceback string, err error) {
+	logger = requestid.Logger(request.Context(), logger)
+	if errors.Is(err, context.Canceled) {
+		logger.Info(message, "Error", err)
+		return
+	}
+
+	logger.Error(message, "Traceback", traceback, "Error", err)
+}
+
+// renderProblem writes problem, which describes err, as response with status of problem.
+func renderProblem(writer http.ResponseWriter, request *http.Request, err error) {
+	writeProblem(writer, newProblem(request, err))
+}
+
+func writeProblem(writer http.ResponseWriter, problem Problem) {
+	writer.Header().Set("Content-Type", ProblemContentType)
+	writer.WriteHeader(problem.Status)
+	_ = json.NewEncoder(writer).Encode(problem)
+}
@@ -4,22 +4,42 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/DKhorkov/medods/internal/security"
 )
 
 // renderJSON преобразует 'v' в формат JSON и записывает результат, в виде ответа, в w.
-func renderJSON(writer http.ResponseWriter, value interface{}) {
+func renderJSON(writer http.ResponseWriter, request *http.Request, value interface{}) {
 	jsonResponse, err := json.Marshal(value)
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		renderProblem(writer, request, err)
 		return
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
-	if _, err = writer.Write(jsonResponse); err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+	// Response has been already started, so client is gone, if writing fails:
+	_, _ = writer.Write(jsonResponse)
+}
+
+// validateGUID checks GUID, received from client, so that malformed GUID is rejected with 400 before it reaches
+// database, which, as PostgreSQL, may fail to compare it with stored GUIDs. GUIDs of SSO users are their numeric
+// IDs, while GUIDs of users from own database are UUIDs.
+func validateGUID(parameter, guid, usersSource string) error {
+	if usersSource == config.SSOUsersSource {
+		if userID, err := strconv.ParseInt(guid, 10, 64); err == nil && userID > 0 {
+			return nil
+		}
+	} else if security.IsUUID(guid) {
+		return nil
 	}
+
+	return customerrors.InvalidParameterError{Parameter: parameter}
 }
 
 // getUserIP retrieves IP address from request.
@@ -36,10 +56,20 @@ func getUserIP(r *http.Request) string {
 	return ip
 }
 
+// getUserLocation retrieves location of user, resolved by reverse proxy or CDN, from request.
+func getUserLocation(r *http.Request) string {
+	location := r.Header.Get("X-Geo-Location")
+	if location == "" {
+		location = r.Header.Get("Cf-Ipcountry")
+	}
+
+	return location
+}
+
 func getRequestBody[T any](request *http.Request, logger *slog.Logger, storage T) error {
 	err := json.NewDecoder(request.Body).Decode(storage)
 	if err != nil {
-		logger.Error(
+		requestid.Logger(request.Context(), logger).Error(
 			"JSON decoding error",
 			"Traceback",
 			logging.GetLogTraceback(),
@@ -47,7 +77,7 @@ func getRequestBody[T any](request *http.Request, logger *slog.Logger, storage T
 			err,
 		)
 
-		return err
+		return customerrors.InvalidRequestBodyError{Err: err}
 	}
 
 	return nil
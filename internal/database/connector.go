@@ -1,9 +1,14 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
 
@@ -11,14 +16,20 @@ import (
 
 	"github.com/DKhorkov/medods/internal/config"
 
-	_ "github.com/lib/pq" // Postgres driver
+	"github.com/go-sql-driver/mysql" // MySQL driver
+
+	_ "github.com/lib/pq"           // Postgres driver
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
+// CommonDBConnector connects to primary database. Optional Replicas serve reads, which tolerate replication lag.
 type CommonDBConnector struct {
-	Connection *sql.DB
-	Driver     string
-	DSN        string
-	Logger     *slog.Logger
+	Connection   *sql.DB
+	Replicas     []*sql.DB
+	Driver       string
+	DSN          string
+	Logger       *slog.Logger
+	replicaIndex atomic.Uint64
 }
 
 func (connector *CommonDBConnector) Connect() error {
@@ -45,15 +56,46 @@ func (connector *CommonDBConnector) GetConnection() *sql.DB {
 	return connector.Connection
 }
 
+// GetReplicaConnection returns read replicas in turn or nil, if there are no replicas.
+func (connector *CommonDBConnector) GetReplicaConnection() *sql.DB {
+	if len(connector.Replicas) == 0 {
+		return nil
+	}
+
+	index := connector.replicaIndex.Add(1) % uint64(len(connector.Replicas))
+	return connector.Replicas[index]
+}
+
+func (connector *CommonDBConnector) GetDriver() string {
+	return connector.Driver
+}
+
 func (connector *CommonDBConnector) GetTransaction() (*sql.Tx, error) {
+	return connector.BeginTransaction(context.Background(), nil)
+}
+
+// BeginTransaction starts transaction, which is rolled back, if ctx is done before it is committed.
+func (connector *CommonDBConnector) BeginTransaction(ctx context.Context, options *sql.TxOptions) (*sql.Tx, error) {
 	if connector.Connection == nil {
 		return nil, customerrors.NilDBConnectionError{}
 	}
 
-	return connector.Connection.Begin()
+	return connector.Connection.BeginTx(ctx, options)
 }
 
 func (connector *CommonDBConnector) CloseConnection() {
+	for _, replica := range connector.Replicas {
+		if err := replica.Close(); err != nil {
+			connector.Logger.Error(
+				"Failed to close replica connection",
+				"Traceback",
+				logging.GetLogTraceback(),
+				"Error",
+				err,
+			)
+		}
+	}
+
 	if connector.Connection == nil {
 		return
 	}
@@ -69,16 +111,35 @@ func (connector *CommonDBConnector) CloseConnection() {
 	}
 }
 
-func New(dbConfig config.DatabaseConfig, logger *slog.Logger) (*CommonDBConnector, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		dbConfig.Host,
-		dbConfig.Port,
-		dbConfig.User,
-		dbConfig.Password,
-		dbConfig.DatabaseName,
-		dbConfig.SSLMode,
-	)
+// New connects to database of backend, selected in config, and waits until it is available or ctx is done, so
+// that wrong credentials or unreachable database are detected on start. In-memory database of memory backend is
+// restored from snapshot, if it exists.
+func New(
+	ctx context.Context,
+	databasesConfig config.DatabasesConfig,
+	logger *slog.Logger,
+) (*CommonDBConnector, error) {
+	var dbConfig config.DatabaseConfig
+	var dsn string
+	var buildDSN func(config.DatabaseConfig) string
+	switch databasesConfig.Backend {
+	case config.PostgreSQLDatabaseBackend:
+		dbConfig = databasesConfig.PostgreSQL
+		buildDSN = buildPostgreSQLDSN
+		dsn = buildDSN(dbConfig)
+	case config.MySQLDatabaseBackend:
+		dbConfig = databasesConfig.MySQL
+		buildDSN = buildMySQLDSN
+		dsn = buildDSN(dbConfig)
+	case config.SQLiteDatabaseBackend:
+		dbConfig = databasesConfig.SQLite
+		dsn = buildSQLiteDSN(dbConfig.DatabaseName)
+	case config.MemoryDatabaseBackend:
+		dbConfig = config.DatabaseConfig{DatabaseName: sqliteInMemory, Driver: "sqlite3"}
+		dsn = buildSQLiteDSN(dbConfig.DatabaseName)
+	default:
+		return nil, customerrors.UnsupportedDatabaseBackendError{Backend: databasesConfig.Backend}
+	}
 
 	dbConnector := &CommonDBConnector{
 		Driver: dbConfig.Driver,
@@ -90,5 +151,165 @@ func New(dbConfig config.DatabaseConfig, logger *slog.Logger) (*CommonDBConnecto
 		return nil, err
 	}
 
+	configurePool(dbConnector.Connection, databasesConfig.Pool)
+
+	// Every connection to in-memory SQLite database opens its own database, so the only one should be used and
+	// never closed:
+	if dbConfig.DatabaseName == sqliteInMemory && dbConfig.Driver == "sqlite3" {
+		dbConnector.Connection.SetMaxOpenConns(1)
+		dbConnector.Connection.SetMaxIdleConns(1)
+		dbConnector.Connection.SetConnMaxLifetime(0)
+	}
+
+	if err := waitForDatabase(ctx, dbConnector.Connection, databasesConfig.Connect, logger); err != nil {
+		dbConnector.CloseConnection()
+		return nil, err
+	}
+
+	if databasesConfig.Backend == config.MemoryDatabaseBackend && databasesConfig.Memory.SnapshotPath != "" {
+		err := restoreSnapshot(ctx, dbConnector.Connection, databasesConfig.Memory.SnapshotPath)
+		if err != nil {
+			dbConnector.CloseConnection()
+			return nil, err
+		}
+	}
+
+	// Replicas are not awaited, because reads fall back to primary, while replica is unavailable:
+	for _, replicaConfig := range replicaConfigs(dbConfig) {
+		replica, err := sql.Open(dbConfig.Driver, buildDSN(replicaConfig))
+		if err != nil {
+			dbConnector.CloseConnection()
+			return nil, err
+		}
+
+		configurePool(replica, databasesConfig.Pool)
+		dbConnector.Replicas = append(dbConnector.Replicas, replica)
+	}
+
 	return dbConnector, nil
 }
+
+// replicaConfigs returns configs of read replicas, which differ from primary only by address. Replica host
+// without port uses port of primary.
+func replicaConfigs(dbConfig config.DatabaseConfig) []config.DatabaseConfig {
+	replicaConfigs := make([]config.DatabaseConfig, 0, len(dbConfig.ReplicaHosts))
+	for _, replicaHost := range dbConfig.ReplicaHosts {
+		replicaConfig := dbConfig
+		replicaConfig.Host = replicaHost
+		if host, port, err := net.SplitHostPort(replicaHost); err == nil {
+			replicaConfig.Host = host
+			replicaConfig.Port, _ = strconv.Atoi(port)
+		}
+
+		replicaConfigs = append(replicaConfigs, replicaConfig)
+	}
+
+	return replicaConfigs
+}
+
+func configurePool(connection *sql.DB, poolConfig config.DatabasePoolConfig) {
+	if poolConfig.MaxOpenConnections > 0 {
+		connection.SetMaxOpenConns(poolConfig.MaxOpenConnections)
+	}
+
+	if poolConfig.MaxIdleConnections > 0 {
+		connection.SetMaxIdleConns(poolConfig.MaxIdleConnections)
+	}
+
+	if poolConfig.ConnectionMaxLifetime > 0 {
+		connection.SetConnMaxLifetime(poolConfig.ConnectionMaxLifetime)
+	}
+}
+
+// waitForDatabase pings database until it responds, attempts are exhausted or ctx is done. Backoff between
+// attempts is doubled after every failed one. Authentication errors are not retried, because credentials do not
+// become valid by waiting.
+func waitForDatabase(
+	ctx context.Context,
+	connection *sql.DB,
+	connectConfig config.DatabaseConnectConfig,
+	logger *slog.Logger,
+) error {
+	maxAttempts := max(connectConfig.MaxAttempts, 1)
+	backoff := connectConfig.BaseBackoff
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = ping(ctx, connection, connectConfig.Timeout); err == nil {
+			return nil
+		}
+
+		if attempt == maxAttempts || isAuthenticationError(err) {
+			return customerrors.DatabaseUnavailableError{Attempts: attempt, Err: err}
+		}
+
+		logger.Warn(
+			"Database is unavailable, retrying",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Attempt",
+			attempt,
+			"Error",
+			err,
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return customerrors.DatabaseUnavailableError{Attempts: attempt, Err: ctx.Err()}
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+
+	return customerrors.DatabaseUnavailableError{Attempts: maxAttempts, Err: err}
+}
+
+func ping(ctx context.Context, connection *sql.DB, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return connection.PingContext(ctx)
+}
+
+const sqliteInMemory = ":memory:"
+
+// buildSQLiteDSN builds DSN for database file. Transactions take write lock on begin, so that concurrent
+// token rotations wait for each other instead of failing to upgrade read lock, and WAL journal allows reads
+// during writes.
+func buildSQLiteDSN(path string) string {
+	return fmt.Sprintf(
+		"file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		path,
+	)
+}
+
+func buildPostgreSQLDSN(dbConfig config.DatabaseConfig) string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		dbConfig.Host,
+		dbConfig.Port,
+		dbConfig.User,
+		dbConfig.Password,
+		dbConfig.DatabaseName,
+		dbConfig.SSLMode,
+	)
+}
+
+// buildMySQLDSN builds DSN, with which DATETIME columns are scanned into time.Time in UTC.
+func buildMySQLDSN(dbConfig config.DatabaseConfig) string {
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = dbConfig.User
+	mysqlConfig.Passwd = dbConfig.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = net.JoinHostPort(dbConfig.Host, strconv.Itoa(dbConfig.Port))
+	mysqlConfig.DBName = dbConfig.DatabaseName
+	mysqlConfig.ParseTime = true
+	mysqlConfig.Loc = time.UTC
+	return mysqlConfig.FormatDSN()
+}
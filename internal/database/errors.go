@@ -0,0 +1,38 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// isAuthenticationError checks, whether database has rejected credentials: PostgreSQL invalid password (28P01) or
+// invalid authorization specification (28000) and MySQL access denied (1045) errors.
+func isAuthenticationError(err error) bool {
+	var postgresError *pq.Error
+	if errors.As(err, &postgresError) {
+		return postgresError.Code == "28P01" || postgresError.Code == "28000"
+	}
+
+	var mysqlError *mysql.MySQLError
+	return errors.As(err, &mysqlError) && mysqlError.Number == 1045
+}
+
+// IsUniqueViolation checks, whether query has failed, because it violates unique index or constraint:
+// PostgreSQL unique_violation (23505), MySQL duplicate entry (1062) and SQLite unique constraint errors.
+func IsUniqueViolation(err error) bool {
+	var postgresError *pq.Error
+	if errors.As(err, &postgresError) {
+		return postgresError.Code == "23505"
+	}
+
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		return mysqlError.Number == 1062
+	}
+
+	var sqliteError sqlite3.Error
+	return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,14 @@
+// Package migrations embeds SQL migrations into binary, so that database schema can be created on start.
+// Every supported dialect has its own directory with the same migration versions.
+package migrations
+
+import "embed"
+
+const (
+	PostgresDirectory = "postgres"
+	SQLiteDirectory   = "sqlite"
+	MySQLDirectory    = "mysql"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql mysql/*.sql
+var FS embed.FS
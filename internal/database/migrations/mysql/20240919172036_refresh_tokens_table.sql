@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id         INTEGER AUTO_INCREMENT PRIMARY KEY,
+    guid       VARCHAR(255) NOT NULL,
+    ttl        DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    value      VARCHAR(255) NOT NULL,
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    deleted_at DATETIME(6),
+    CONSTRAINT refresh_tokens_value_key UNIQUE (value)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS refresh_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notifications_outbox
+(
+    id              VARCHAR(36) PRIMARY KEY,
+    payload         TEXT        NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    last_error      TEXT,
+    created_at      DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at      DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- MySQL does not support partial indexes, so status is the leading column instead:
+CREATE INDEX notifications_outbox_pending_idx ON notifications_outbox (status, next_attempt_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notifications_outbox;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS users
+(
+    guid       VARCHAR(255) PRIMARY KEY,
+    email      VARCHAR(255) NOT NULL UNIQUE,
+    status     VARCHAR(16)  NOT NULL DEFAULT 'active',
+    locale     VARCHAR(8)   NOT NULL DEFAULT 'ru',
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX users_status_idx ON users (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS users;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Values are bcrypt hashes, which are never used for lookup, so uniqueness index is a waste:
+DROP INDEX refresh_tokens_value_key ON refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Unlike PostgreSQL, MySQL validates CHECK constraint against existing rows, so invalid ones are dropped:
+DELETE FROM refresh_tokens WHERE ttl <= created_at;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    MODIFY COLUMN guid VARCHAR(36) NOT NULL,
+    MODIFY COLUMN ttl DATETIME(6) NOT NULL,
+    MODIFY COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    ADD CONSTRAINT refresh_tokens_ttl_check CHECK (ttl > created_at);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- MySQL does not support partial indexes, so refresh_tokens_guid_created_at_idx serves active tokens lookup too:
+CREATE INDEX refresh_tokens_guid_created_at_idx ON refresh_tokens (guid, created_at DESC, id DESC);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX refresh_tokens_ttl_idx ON refresh_tokens (ttl);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX refresh_tokens_deleted_at_idx ON refresh_tokens (deleted_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Uniqueness of values is not restored, because tokens with same values may have been created.
+-- +goose StatementBegin
+DROP INDEX refresh_tokens_deleted_at_idx ON refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX refresh_tokens_ttl_idx ON refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX refresh_tokens_guid_created_at_idx ON refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    DROP CONSTRAINT refresh_tokens_ttl_check,
+    MODIFY COLUMN guid VARCHAR(255) NOT NULL,
+    MODIFY COLUMN ttl DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    MODIFY COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6);
+-- +goose StatementEnd
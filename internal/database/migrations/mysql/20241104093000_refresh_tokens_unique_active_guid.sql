@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Concurrent logins could have left several undeleted refresh tokens of user, so that all of them, except the
+-- latest one, are deleted before uniqueness is enforced. MySQL can not select from updated table in subquery:
+UPDATE refresh_tokens AS rt
+    JOIN refresh_tokens AS newer
+    ON newer.guid = rt.guid
+        AND newer.deleted_at IS NULL
+        AND newer.id > rt.id
+SET rt.deleted_at = UTC_TIMESTAMP(6)
+WHERE rt.deleted_at IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- MySQL does not support partial indexes, so that unique index is built on generated column, which is NULL for
+-- deleted refresh tokens. User has only one undeleted refresh token, so that concurrent logins can not both
+-- create one:
+ALTER TABLE refresh_tokens
+    ADD COLUMN active_guid VARCHAR(36) GENERATED ALWAYS AS (IF(deleted_at IS NULL, guid, NULL)) STORED,
+    ADD UNIQUE INDEX refresh_tokens_guid_active_idx (active_guid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    DROP INDEX refresh_tokens_guid_active_idx,
+    DROP COLUMN active_guid;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens ADD COLUMN ip VARCHAR(45) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_ip_idx ON refresh_tokens (ip);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS access_tokens_denylist
+(
+    guid          VARCHAR(255) PRIMARY KEY,
+    denied_before TIMESTAMP    NOT NULL,
+    expires_at    TIMESTAMP    NOT NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS access_tokens_denylist;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_ip_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens DROP COLUMN ip;
+-- +goose StatementEnd
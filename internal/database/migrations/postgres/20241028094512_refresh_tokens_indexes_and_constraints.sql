@@ -0,0 +1,143 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GUIDs are converted to UUID below. Values, which can not be cast, would fail migration with an obscure error,
+-- so they are reported with the table, where they are found:
+DO
+$$
+    DECLARE
+        uuid_pattern CONSTANT TEXT := '^[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}$';
+        table_name            TEXT;
+        invalid_guid          TEXT;
+    BEGIN
+        FOREACH table_name IN ARRAY ARRAY ['refresh_tokens', 'users', 'access_tokens_denylist']
+            LOOP
+                EXECUTE format('SELECT guid FROM %I WHERE guid !~* $1 LIMIT 1', table_name)
+                    INTO invalid_guid
+                    USING uuid_pattern;
+
+                IF invalid_guid IS NOT NULL THEN
+                    RAISE EXCEPTION 'Column %.guid contains value "%", which is not UUID', table_name, invalid_guid
+                        USING HINT = 'Fix or delete rows with such values before migration, GUIDs of users must be '
+                            || 'UUIDs with PostgreSQL backend';
+                END IF;
+            END LOOP;
+    END
+$$;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Values are bcrypt hashes, which are never used for lookup, so uniqueness index is a waste:
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_value_key;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    ALTER COLUMN guid TYPE UUID USING guid::UUID,
+    ALTER COLUMN ttl DROP DEFAULT,
+    ALTER COLUMN ttl TYPE TIMESTAMPTZ USING ttl AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMPTZ USING deleted_at AT TIME ZONE 'UTC',
+    ALTER COLUMN session_started_at TYPE TIMESTAMPTZ USING session_started_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE users ALTER COLUMN guid TYPE UUID USING guid::UUID;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE access_tokens_denylist
+    ALTER COLUMN guid TYPE UUID USING guid::UUID,
+    ALTER COLUMN denied_before TYPE TIMESTAMPTZ USING denied_before AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Outbox times are compared with times of application, so they are converted as well:
+ALTER TABLE notifications_outbox
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ USING next_attempt_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Existing rows are not validated, new ones can not be expired on creation:
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_ttl_check CHECK (ttl > created_at) NOT VALID;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_guid_active_idx
+    ON refresh_tokens (guid, created_at DESC)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_guid_created_at_idx ON refresh_tokens (guid, created_at DESC, id DESC);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_ttl_idx ON refresh_tokens (ttl);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_deleted_at_idx
+    ON refresh_tokens (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER refresh_tokens_set_updated_at
+    BEFORE UPDATE
+    ON refresh_tokens
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+-- +goose StatementEnd
+
+-- +goose Down
+-- Uniqueness of values is not restored, because tokens with same values may have been created.
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS refresh_tokens_set_updated_at ON refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS set_updated_at();
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_deleted_at_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_ttl_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_guid_created_at_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_guid_active_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_ttl_check;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE notifications_outbox
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMP USING next_attempt_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE access_tokens_denylist
+    ALTER COLUMN guid TYPE VARCHAR(255) USING guid::VARCHAR,
+    ALTER COLUMN denied_before TYPE TIMESTAMP USING denied_before AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMP USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE users ALTER COLUMN guid TYPE VARCHAR(255) USING guid::VARCHAR;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    ALTER COLUMN guid TYPE VARCHAR(255) USING guid::VARCHAR,
+    ALTER COLUMN ttl TYPE TIMESTAMP USING ttl AT TIME ZONE 'UTC',
+    ALTER COLUMN ttl SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMP USING deleted_at AT TIME ZONE 'UTC',
+    ALTER COLUMN session_started_at TYPE TIMESTAMP USING session_started_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Concurrent logins could have left several undeleted refresh tokens of user, so that all of them, except the
+-- latest one, are deleted before uniqueness is enforced:
+UPDATE refresh_tokens
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL
+  AND EXISTS (SELECT 1
+              FROM refresh_tokens AS newer
+              WHERE newer.guid = refresh_tokens.guid
+                AND newer.deleted_at IS NULL
+                AND newer.id > refresh_tokens.id);
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_guid_active_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- User has only one undeleted refresh token, so that concurrent logins can not both create one:
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_guid_active_idx
+    ON refresh_tokens (guid)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS refresh_tokens_guid_active_idx;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_guid_active_idx
+    ON refresh_tokens (guid, created_at DESC)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
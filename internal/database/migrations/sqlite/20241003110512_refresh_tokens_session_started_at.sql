@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens ADD COLUMN session_started_at TIMESTAMP;
+-- +goose StatementEnd
+-- +goose StatementBegin
+UPDATE refresh_tokens SET session_started_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens DROP COLUMN session_started_at;
+-- +goose StatementEnd
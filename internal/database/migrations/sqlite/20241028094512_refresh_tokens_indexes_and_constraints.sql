@@ -0,0 +1,91 @@
+-- +goose Up
+-- SQLite can neither change column types nor add constraints, so that table is rebuilt. Times are stored in UTC
+-- and guid is kept as text, because SQLite has no UUID type.
+-- +goose StatementBegin
+CREATE TABLE refresh_tokens_new
+(
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    guid               VARCHAR(36)  NOT NULL,
+    ttl                TIMESTAMP    NOT NULL,
+    value              VARCHAR(255) NOT NULL,
+    created_at         TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at         TIMESTAMP,
+    session_started_at TIMESTAMP,
+    ip                 VARCHAR(45)  NOT NULL DEFAULT '',
+    CONSTRAINT refresh_tokens_ttl_check CHECK (ttl > created_at)
+);
+-- +goose StatementEnd
+-- Tokens, expired on creation, violate constraint and are useless anyway:
+-- +goose StatementBegin
+INSERT INTO refresh_tokens_new (id, guid, ttl, value, created_at, updated_at, deleted_at, session_started_at, ip)
+SELECT id, guid, ttl, value, created_at, updated_at, deleted_at, session_started_at, ip
+FROM refresh_tokens
+WHERE ttl > created_at;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens_new RENAME TO refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_ip_idx ON refresh_tokens (ip);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_guid_active_idx
+    ON refresh_tokens (guid, created_at DESC)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_guid_created_at_idx ON refresh_tokens (guid, created_at DESC, id DESC);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_ttl_idx ON refresh_tokens (ttl);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_deleted_at_idx
+    ON refresh_tokens (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS refresh_tokens_set_updated_at
+    AFTER UPDATE
+    ON refresh_tokens
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE refresh_tokens SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Uniqueness of values is not restored, because tokens with same values may have been created.
+-- +goose StatementBegin
+CREATE TABLE refresh_tokens_old
+(
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    guid               VARCHAR(255) NOT NULL,
+    ttl                TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    value              VARCHAR(255) NOT NULL,
+    created_at         TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at         TIMESTAMP,
+    session_started_at TIMESTAMP,
+    ip                 VARCHAR(45)  NOT NULL DEFAULT ''
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+INSERT INTO refresh_tokens_old (id, guid, ttl, value, created_at, updated_at, deleted_at, session_started_at, ip)
+SELECT id, guid, ttl, value, created_at, updated_at, deleted_at, session_started_at, ip
+FROM refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens_old RENAME TO refresh_tokens;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS refresh_tokens_ip_idx ON refresh_tokens (ip);
+-- +goose StatementEnd
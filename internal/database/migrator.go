@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+
+	"github.com/DKhorkov/medods/internal/database/migrations"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies migrations, embedded into binary. For PostgreSQL migrations are guarded by advisory lock and
+// for MySQL by named lock, so that several simultaneously started instances do not apply them concurrently.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func NewMigrator(connection *sql.DB, driver string) (*Migrator, error) {
+	var options []goose.ProviderOption
+	var dialect goose.Dialect
+	var directory string
+	switch driver {
+	case "postgres", "pgx":
+		dialect = goose.DialectPostgres
+		directory = migrations.PostgresDirectory
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, goose.WithSessionLocker(locker))
+	case "sqlite3", "sqlite":
+		dialect = goose.DialectSQLite3
+		directory = migrations.SQLiteDirectory
+	case "mysql":
+		dialect = goose.DialectMySQL
+		directory = migrations.MySQLDirectory
+		options = append(options, goose.WithSessionLocker(NewMySQLSessionLocker()))
+	default:
+		return nil, fmt.Errorf("migrations are not supported for %q driver", driver)
+	}
+
+	migrationsFS, err := fs.Sub(migrations.FS, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := goose.NewProvider(dialect, connection, migrationsFS, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations and returns number of applied ones. Migration, which is in progress, when ctx
+// is done, is rolled back.
+func (migrator *Migrator) Up(ctx context.Context) (int, error) {
+	results, err := migrator.provider.Up(ctx)
+	return len(results), err
+}
+
+// Down rolls back the last applied migration.
+func (migrator *Migrator) Down(ctx context.Context) error {
+	_, err := migrator.provider.Down(ctx)
+	return err
+}
+
+// Status returns applied and pending migrations.
+func (migrator *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return migrator.provider.Status(ctx)
+}
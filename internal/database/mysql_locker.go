@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pressly/goose/v3/lock"
+)
+
+const (
+	mysqlMigrationsLockName = "medods_migrations"
+
+	// mysqlMigrationsLockTimeout is close to default overall wait time of PostgreSQL session locker.
+	mysqlMigrationsLockTimeout = time.Minute * 5
+)
+
+// mysqlSessionLocker guards migrations by MySQL named lock, which is held by connection, until it is released
+// or connection is closed, so that several simultaneously started instances do not apply migrations concurrently.
+type mysqlSessionLocker struct {
+	name    string
+	timeout time.Duration
+}
+
+// SessionLock waits for named lock. GET_LOCK returns 1, if lock is obtained, 0 on timeout and NULL on error.
+func (locker *mysqlSessionLocker) SessionLock(ctx context.Context, conn *sql.Conn) error {
+	var obtained sql.NullInt64
+	err := conn.QueryRowContext(
+		ctx,
+		"SELECT GET_LOCK(?, ?)",
+		locker.name,
+		int(locker.timeout.Seconds()),
+	).Scan(&obtained)
+
+	if err != nil {
+		return fmt.Errorf("failed to obtain migrations lock: %w", err)
+	}
+
+	if !obtained.Valid || obtained.Int64 != 1 {
+		return fmt.Errorf("failed to obtain migrations lock %q within %s", locker.name, locker.timeout)
+	}
+
+	return nil
+}
+
+// SessionUnlock releases named lock. RELEASE_LOCK returns 1, if lock is released, 0, if lock is held by other
+// connection and NULL, if lock does not exist.
+func (locker *mysqlSessionLocker) SessionUnlock(ctx context.Context, conn *sql.Conn) error {
+	var released sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", locker.name).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("failed to release migrations lock: %w", err)
+	}
+
+	if !released.Valid || released.Int64 != 1 {
+		return errors.New("migrations lock has not been held by connection")
+	}
+
+	return nil
+}
+
+// NewMySQLSessionLocker creates session locker, which guards migrations by GET_LOCK and RELEASE_LOCK functions.
+func NewMySQLSessionLocker() lock.SessionLocker {
+	return &mysqlSessionLocker{
+		name:    mysqlMigrationsLockName,
+		timeout: mysqlMigrationsLockTimeout,
+	}
+}
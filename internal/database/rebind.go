@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+const mysqlDriver = "mysql"
+
+var numberedPlaceholder = regexp.MustCompile(`\$\d+`)
+
+// Rebind replaces numbered placeholders ($1, $2, ...) with positional ones (?), used by MySQL. Placeholders
+// should appear in query in the order of their numbers and only once, because positional ones can not be reused.
+func Rebind(query string) string {
+	return numberedPlaceholder.ReplaceAllString(query, "?")
+}
+
+// rebindingExecutor rebinds queries before passing them to executor.
+type rebindingExecutor struct {
+	executor interfaces.DBExecutor
+}
+
+func (executor *rebindingExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return executor.executor.ExecContext(ctx, Rebind(query), args...)
+}
+
+func (executor *rebindingExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return executor.executor.QueryContext(ctx, Rebind(query), args...)
+}
+
+func (executor *rebindingExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return executor.executor.QueryRowContext(ctx, Rebind(query), args...)
+}
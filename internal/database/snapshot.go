@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveSnapshot copies in-memory SQLite database of memory backend to file at path. Snapshot is written to
+// temporary file, which then replaces previous snapshot, so that snapshot is not corrupted, if process dies
+// during saving. Snapshot is taken outside of transactions, so that it is consistent.
+func SaveSnapshot(ctx context.Context, dbConnector interfaces.DBConnector, path string) error {
+	connection := dbConnector.GetConnection()
+	if connection == nil {
+		return errors.New("database connection is not opened")
+	}
+
+	temporaryPath := path + ".tmp"
+	if err := os.Remove(temporaryPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if _, err := connection.ExecContext(ctx, "VACUUM INTO $1", temporaryPath); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, path)
+}
+
+// restoreSnapshot copies SQLite database from snapshot file at path into in-memory database by SQLite backup API,
+// if snapshot exists. Snapshot is opened read-only, so that it is never changed by restoring.
+func restoreSnapshot(ctx context.Context, connection *sql.DB, path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	snapshot, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = snapshot.Close()
+	}()
+
+	source, err := snapshot.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = source.Close()
+	}()
+
+	destination, err := connection.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = destination.Close()
+	}()
+
+	return destination.Raw(
+		func(destinationConnection any) error {
+			return source.Raw(
+				func(sourceConnection any) error {
+					return backupSQLite(destinationConnection, sourceConnection)
+				},
+			)
+		},
+	)
+}
+
+func backupSQLite(destinationConnection, sourceConnection any) error {
+	destination, ok := destinationConnection.(*sqlite3.SQLiteConn)
+	if !ok {
+		return fmt.Errorf("snapshot can not be restored into %T connection", destinationConnection)
+	}
+
+	source, ok := sourceConnection.(*sqlite3.SQLiteConn)
+	if !ok {
+		return fmt.Errorf("snapshot can not be restored from %T connection", sourceConnection)
+	}
+
+	backup, err := destination.Backup("main", source, "main")
+	if err != nil {
+		return err
+	}
+
+	if _, err = backup.Step(-1); err != nil {
+		_ = backup.Finish()
+		return err
+	}
+
+	return backup.Finish()
+}
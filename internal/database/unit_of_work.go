@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+type transactionContextKey struct{}
+
+// CommonUnitOfWork runs work in transaction of DBConnector. Transaction is passed to repositories through
+// context, so that every repository, which gets executor with GetExecutor, participates in it.
+type CommonUnitOfWork struct {
+	DBConnector interfaces.DBConnector
+}
+
+// Do commits transaction, if work succeeds, and rolls it back otherwise. Nested calls participate in the
+// outer transaction. Transaction is bound to ctx, so that cancelled request or timeout rolls it back and
+// releases its locks.
+func (unitOfWork *CommonUnitOfWork) Do(ctx context.Context, work func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(transactionContextKey{}).(*sql.Tx); ok {
+		return work(ctx)
+	}
+
+	transaction, err := unitOfWork.DBConnector.BeginTransaction(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			_ = transaction.Rollback()
+			panic(recovered)
+		}
+	}()
+
+	if err = work(context.WithValue(ctx, transactionContextKey{}, transaction)); err != nil {
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// GetExecutor returns transaction of unit of work, if ctx carries one, or connection of DBConnector otherwise.
+// For MySQL executor accepts PostgreSQL-style placeholders, so that portable queries work with every dialect.
+func GetExecutor(ctx context.Context, dbConnector interfaces.DBConnector) interfaces.DBExecutor {
+	var executor interfaces.DBExecutor
+	if transaction, ok := ctx.Value(transactionContextKey{}).(*sql.Tx); ok {
+		executor = transaction
+	} else {
+		executor = dbConnector.GetConnection()
+	}
+
+	if dbConnector.GetDriver() == mysqlDriver {
+		return &rebindingExecutor{executor: executor}
+	}
+
+	return executor
+}
+
+// GetReplicaExecutor returns connection to read replica of DBConnector or nil, if there are no replicas or ctx
+// carries transaction, which should see its own writes. Data on replica may be stale, so callers should fall back
+// to GetExecutor, if it is not found on replica.
+func GetReplicaExecutor(ctx context.Context, dbConnector interfaces.DBConnector) interfaces.DBExecutor {
+	if _, ok := ctx.Value(transactionContextKey{}).(*sql.Tx); ok {
+		return nil
+	}
+
+	replica := dbConnector.GetReplicaConnection()
+	if replica == nil {
+		return nil
+	}
+
+	if dbConnector.GetDriver() == mysqlDriver {
+		return &rebindingExecutor{executor: replica}
+	}
+
+	return replica
+}
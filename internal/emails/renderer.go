@@ -0,0 +1,129 @@
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+const (
+	SuspiciousIPTemplate    = "suspicious_ip"
+	SessionsRevokedTemplate = "sessions_revoked"
+
+	RussianLocale = "ru"
+	EnglishLocale = "en"
+)
+
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS
+
+// Renderer renders emails from templates, named as "<name>.<locale>.<part>.tmpl", where part is "subject",
+// "txt" or "html". Templates are embedded into binary and each of them can be overridden by file with the same
+// name in templates directory.
+type Renderer struct {
+	templatesDirectory string
+	defaultLocale      string
+}
+
+// Render renders email in provided locale. If there is no template for locale, default locale is used.
+func (renderer *Renderer) Render(name, locale string, data any) (*entities.Email, error) {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if _, err := renderer.readTemplate(name, locale, "subject"); err != nil {
+		locale = renderer.defaultLocale
+	}
+
+	subject, err := renderer.renderText(name, locale, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := renderer.renderText(name, locale, "txt", data)
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := renderer.renderHTML(name, locale, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Email{
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html,
+		Locale:  locale,
+	}, nil
+}
+
+func (renderer *Renderer) renderText(name, locale, part string, data any) (string, error) {
+	content, err := renderer.readTemplate(name, locale, part)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := texttemplate.New(name).Parse(string(content))
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+func (renderer *Renderer) renderHTML(name, locale string, data any) (string, error) {
+	content, err := renderer.readTemplate(name, locale, "html")
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := htmltemplate.New(name).Parse(string(content))
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// readTemplate reads template from templates directory, if it is configured and template exists there,
+// or from embedded templates otherwise.
+func (renderer *Renderer) readTemplate(name, locale, part string) ([]byte, error) {
+	filename := name + "." + locale + "." + part + ".tmpl"
+	if renderer.templatesDirectory != "" {
+		content, err := os.ReadFile(filepath.Join(renderer.templatesDirectory, filepath.Base(filename)))
+		if err == nil {
+			return content, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return embeddedTemplates.ReadFile("templates/" + filename)
+}
+
+// NewRenderer creates an instance of Renderer. Empty templatesDirectory means, that only embedded templates
+// are used.
+func NewRenderer(templatesDirectory, defaultLocale string) *Renderer {
+	return &Renderer{
+		templatesDirectory: templatesDirectory,
+		defaultLocale:      defaultLocale,
+	}
+}
@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+type Email struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+	Locale  string `json:"locale"`
+}
+
+// SuspiciousIPEmailData is used for rendering warning about refresh attempt from unknown IP address.
+type SuspiciousIPEmailData struct {
+	Time       time.Time
+	IP         string
+	Location   string
+	Device     string
+	RevokeLink string
+}
+
+// SessionsRevokedEmailData is used for rendering notification about sessions, ended after refresh token reuse.
+type SessionsRevokedEmailData struct {
+	Time     time.Time
+	IP       string
+	Location string
+	Device   string
+}
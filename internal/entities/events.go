@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+const (
+	TokenIssuedEvent          = "token.issued"
+	RefreshFromNewIPEvent     = "token.refresh_from_new_ip"
+	SessionRevokedEvent       = "session.revoked"
+	RefreshTokenReusedEvent   = "token.reuse_detected"
+	AllSecurityEventsWildcard = "*"
+)
+
+type SecurityEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	GUID       string    `json:"GUID"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
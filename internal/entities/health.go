@@ -0,0 +1,10 @@
+package entities
+
+import "time"
+
+// HealthStatus is a result of the last check of dependency, which service needs to serve requests.
+type HealthStatus struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
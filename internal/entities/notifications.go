@@ -0,0 +1,12 @@
+package entities
+
+type Notification struct {
+	// ID is set only for notifications, which are delivered through outbox.
+	ID         string   `json:"id,omitempty"`
+	Recipients []string `json:"recipients"`
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+
+	// HTMLBody is an optional alternative representation of Body.
+	HTMLBody string `json:"htmlBody,omitempty"`
+}
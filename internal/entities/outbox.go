@@ -0,0 +1,22 @@
+package entities
+
+import "time"
+
+const (
+	OutboxMessagePending   = "pending"
+	OutboxMessageDelivered = "delivered"
+	OutboxMessageDead      = "dead"
+)
+
+// OutboxMessage is a notification, persisted to database before delivery. ID of message is sent to receivers,
+// which support it, as idempotency key, because messages are delivered at least once.
+type OutboxMessage struct {
+	ID            string       `json:"id"`
+	Notification  Notification `json:"notification"`
+	Status        string       `json:"status"`
+	Attempts      int          `json:"attempts"`
+	NextAttemptAt time.Time    `json:"nextAttemptAt"`
+	LastError     string       `json:"lastError"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+}
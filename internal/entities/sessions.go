@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+// Session is a refresh token, as it is shown to administrators. Refresh token value is never exposed.
+type Session struct {
+	ID              int        `json:"id"`
+	GUID            string     `json:"GUID"`
+	IP              string     `json:"IP"`
+	StartedAt       time.Time  `json:"startedAt"`
+	LastRefreshedAt time.Time  `json:"lastRefreshedAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+	RevokedAt       *time.Time `json:"revokedAt,omitempty"`
+	Active          bool       `json:"active"`
+}
+
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type SessionsPage struct {
+	Sessions []Session `json:"sessions"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+	HasMore  bool      `json:"hasMore"`
+}
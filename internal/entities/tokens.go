@@ -9,7 +9,24 @@ type RefreshToken struct {
 	Value     string    `json:"value" gorm:"unique; not null"`
 	CreatedAt time.Time `json:"createdAt" gorm:"not null"`
 	UpdatedAt time.Time `json:"updatedAt" gorm:"not null"`
-	DeletedAt time.Time `json:"deletedAt" gorm:"not null"`
+
+	// SessionStartedAt is inherited by every refresh token of the session from the first one.
+	SessionStartedAt time.Time `json:"sessionStartedAt" gorm:"not null"`
+	IP               string    `json:"IP" gorm:"not null"`
+
+	// DeletedAt is set, when refresh token is rotated or revoked.
+	DeletedAt *time.Time `json:"deletedAt"`
+}
+
+type CreateRefreshTokenDTO struct {
+	GUID             string    `json:"GUID"`
+	Value            string    `json:"value"`
+	TTL              time.Time `json:"TTL"`
+	SessionStartedAt time.Time `json:"sessionStartedAt"`
+	IP               string    `json:"IP"`
+
+	// RotatedRefreshTokenID is set on refresh to ID of refresh token, which is replaced by new one.
+	RotatedRefreshTokenID int `json:"rotatedRefreshTokenID,omitempty"`
 }
 
 type Tokens struct {
@@ -23,6 +40,8 @@ type CreateTokensDTO struct {
 }
 
 type RefreshTokensDTO struct {
-	Tokens Tokens
-	IP     string `json:"ip"`
+	Tokens    Tokens
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Location  string `json:"location"`
 }
@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+const (
+	UserStatusActive  = "active"
+	UserStatusLocked  = "locked"
+	UserStatusBlocked = "blocked"
+	UserStatusDeleted = "deleted"
+)
+
+type User struct {
+	GUID      string    `json:"GUID" gorm:"primary_key"`
+	Email     string    `json:"email" gorm:"unique;not null"`
+	Status    string    `json:"status" gorm:"not null"`
+	Locale    string    `json:"locale" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null"`
+}
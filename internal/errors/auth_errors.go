@@ -59,3 +59,76 @@ func (e HeaderError) Error() string {
 
 	return "required Header is missing or invalid"
 }
+
+type SessionExpiredError struct {
+	Message string
+}
+
+func (e SessionExpiredError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "session has reached its maximum lifetime, login required"
+}
+
+type SessionIdleTimeoutError struct {
+	Message string
+}
+
+func (e SessionIdleTimeoutError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "session has expired due to inactivity, login required"
+}
+
+type AccessTokenRevokedError struct {
+	Message string
+}
+
+func (e AccessTokenRevokedError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "access token has been revoked"
+}
+
+type InvalidParameterError struct {
+	Parameter string
+}
+
+func (e InvalidParameterError) Error() string {
+	if e.Parameter != "" {
+		return e.Parameter + " parameter is invalid"
+	}
+
+	return "parameter is invalid"
+}
+
+type InvalidRefreshTokenTTLError struct {
+	Message string
+}
+
+func (e InvalidRefreshTokenTTLError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "refresh token TTL should be after its creation time"
+}
+
+// RefreshTokenRotatedError is returned, if refresh token has been rotated by concurrent request.
+type RefreshTokenRotatedError struct {
+	Message string
+}
+
+func (e RefreshTokenRotatedError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "refresh token has been already rotated by another request"
+}
@@ -1,5 +1,7 @@
 package errors
 
+import "fmt"
+
 type NilDBConnectionError struct {
 	Message string
 }
@@ -11,3 +13,33 @@ func (e NilDBConnectionError) Error() string {
 
 	return "DB connection error. Making operation on nil database connection."
 }
+
+type UnsupportedDatabaseBackendError struct {
+	Backend string
+	Message string
+}
+
+func (e UnsupportedDatabaseBackendError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	if e.Backend != "" {
+		return fmt.Sprintf("database backend %s is not supported", e.Backend)
+	}
+
+	return "database backend is not supported"
+}
+
+type DatabaseUnavailableError struct {
+	Attempts int
+	Err      error
+}
+
+func (e DatabaseUnavailableError) Error() string {
+	return fmt.Sprintf("database is unavailable after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e DatabaseUnavailableError) Unwrap() error {
+	return e.Err
+}
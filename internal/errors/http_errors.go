@@ -0,0 +1,47 @@
+package errors
+
+type MethodNotAllowedError struct {
+	Method string
+}
+
+func (e MethodNotAllowedError) Error() string {
+	if e.Method != "" {
+		return "method " + e.Method + " is not allowed"
+	}
+
+	return "method is not allowed"
+}
+
+type InvalidRequestBodyError struct {
+	Err error
+}
+
+func (e InvalidRequestBodyError) Error() string {
+	if e.Err != nil {
+		return "request body is invalid: " + e.Err.Error()
+	}
+
+	return "request body is invalid"
+}
+
+func (e InvalidRequestBodyError) Unwrap() error {
+	return e.Err
+}
+
+// TooManyRequestsError is returned, if request can not be handled, because rate limit of the service or of service,
+// which it depends on, is exceeded, so that client should retry later.
+type TooManyRequestsError struct {
+	Err error
+}
+
+func (e TooManyRequestsError) Error() string {
+	if e.Err != nil {
+		return "too many requests: " + e.Err.Error()
+	}
+
+	return "too many requests"
+}
+
+func (e TooManyRequestsError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,28 @@
+package errors
+
+import "fmt"
+
+type UnknownNotificationChannelError struct {
+	Channel string
+}
+
+func (e UnknownNotificationChannelError) Error() string {
+	if e.Channel != "" {
+		return "unknown notification channel: " + e.Channel
+	}
+
+	return "unknown notification channel"
+}
+
+type WebhookDeliveryError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e WebhookDeliveryError) Error() string {
+	if e.URL != "" {
+		return fmt.Sprintf("webhook %s responded with status code %d", e.URL, e.StatusCode)
+	}
+
+	return "webhook delivery failed"
+}
@@ -0,0 +1,61 @@
+package errors
+
+import "fmt"
+
+type UserNotFoundError struct {
+	Message string
+}
+
+func (e UserNotFoundError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "user not found"
+}
+
+// UserDisabledError is returned for blocked or deleted users, whose sessions are revoked.
+type UserDisabledError struct {
+	Status string
+}
+
+func (e UserDisabledError) Error() string {
+	if e.Status != "" {
+		return "user account is " + e.Status
+	}
+
+	return "user account is disabled"
+}
+
+// UserLockedError is returned for temporarily locked users, whose sessions are kept.
+type UserLockedError struct {
+	Message string
+}
+
+func (e UserLockedError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return "user account is locked"
+}
+
+// UnsupportedUsersSourceError is returned on start, if users source can not be used with configured database
+// backend.
+type UnsupportedUsersSourceError struct {
+	Source  string
+	Backend string
+	Message string
+}
+
+func (e UnsupportedUsersSourceError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	if e.Source != "" {
+		return fmt.Sprintf("users source %s can not be used with database backend %s", e.Source, e.Backend)
+	}
+
+	return "users source is not supported"
+}
@@ -1,10 +1,34 @@
 package interfaces
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type DBConnector interface {
 	Connect() error
 	CloseConnection()
 	GetTransaction() (*sql.Tx, error)
+
+	// BeginTransaction starts transaction, which is bound to ctx and is rolled back, if ctx is done before commit.
+	BeginTransaction(ctx context.Context, options *sql.TxOptions) (*sql.Tx, error)
 	GetConnection() *sql.DB
+	GetDriver() string
+
+	// GetReplicaConnection returns connection to read replica or nil, if there are no replicas.
+	GetReplicaConnection() *sql.DB
+}
+
+// DBExecutor executes queries. It is implemented by both *sql.DB and *sql.Tx, so that repositories work
+// the same way in and out of transaction.
+type DBExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// UnitOfWork makes repositories calls, done by work, atomic. Work should use provided context, because
+// transaction is carried by it.
+type UnitOfWork interface {
+	Do(ctx context.Context, work func(ctx context.Context) error) error
 }
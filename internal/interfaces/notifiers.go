@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, notification entities.Notification) error
+}
+
+type EmailRenderer interface {
+	Render(name, locale string, data any) (*entities.Email, error)
+}
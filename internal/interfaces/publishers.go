@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+// EventPublisher publishes security events asynchronously, so publishing never blocks caller. Delivery is not
+// bound to ctx, which only carries request ID to forward.
+type EventPublisher interface {
+	Publish(ctx context.Context, event entities.SecurityEvent)
+}
@@ -1,18 +1,50 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	"github.com/DKhorkov/medods/internal/entities"
 )
 
 type AuthRepository interface {
-	CreateRefreshToken(guid, value string, ttl time.Time) (int, error)
-	GetRefreshTokenByID(id int) (*entities.RefreshToken, error)
-	GetRefreshTokenByGUID(guid string) (*entities.RefreshToken, error)
-	DeleteRefreshToken(token *entities.RefreshToken) error
+	CreateRefreshToken(ctx context.Context, data entities.CreateRefreshTokenDTO) (int, error)
+	GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error)
+	GetRefreshTokenByIDIncludingDeleted(ctx context.Context, id int) (*entities.RefreshToken, error)
+	GetRefreshTokenByGUID(ctx context.Context, guid string) (*entities.RefreshToken, error)
+	DeleteRefreshToken(ctx context.Context, token *entities.RefreshToken) error
+	GetRefreshTokensByGUID(
+		ctx context.Context,
+		guid string,
+		pagination entities.Pagination,
+	) ([]entities.RefreshToken, error)
+	GetRefreshTokensByIP(
+		ctx context.Context,
+		ip string,
+		pagination entities.Pagination,
+	) ([]entities.RefreshToken, error)
+	DeleteRefreshTokensByGUID(ctx context.Context, guid string) (int, error)
+	DeleteRefreshTokensOfDisabledUsers(ctx context.Context) ([]string, error)
+	DenyAccessTokens(ctx context.Context, guid string, deniedBefore, expiresAt time.Time) error
+	GetAccessTokensDeniedBefore(ctx context.Context, guid string) (time.Time, error)
+	PurgeRefreshTokens(ctx context.Context, expiredBefore, deletedBefore time.Time, limit int) (int, error)
+	PurgeAccessTokensDenylist(ctx context.Context, before time.Time, limit int) (int, error)
 }
 
 type UsersRepository interface {
-	GetUserEmail(guid string) (string, error)
+	GetUserEmail(ctx context.Context, guid string) (string, error)
+	GetUserLocale(ctx context.Context, guid string) (string, error)
+	GetUserByGUID(ctx context.Context, guid string) (*entities.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*entities.User, error)
+	GetUsersByStatus(ctx context.Context, status string) ([]entities.User, error)
+}
+
+type OutboxRepository interface {
+	CreateOutboxMessage(ctx context.Context, notification entities.Notification) (string, error)
+	GetPendingOutboxMessages(ctx context.Context, limit int) ([]entities.OutboxMessage, error)
+	ClaimOutboxMessage(ctx context.Context, id string, leaseUntil time.Time) (bool, error)
+	MarkOutboxMessageDelivered(ctx context.Context, id string) error
+	MarkOutboxMessageFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error
+	MarkOutboxMessageDead(ctx context.Context, id string, lastError string) error
+	PurgeOutbox(ctx context.Context, before time.Time, limit int) (int, error)
 }
@@ -1,16 +1,40 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	"github.com/DKhorkov/medods/internal/entities"
 )
 
 type AuthService interface {
-	CreateRefreshToken(guid, value string, ttl time.Time) (int, error)
-	GetRefreshTokenByID(id int) (*entities.RefreshToken, error)
+	CreateRefreshToken(ctx context.Context, data entities.CreateRefreshTokenDTO) (int, error)
+	GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error)
+	GetRotatedRefreshToken(ctx context.Context, id int) (*entities.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, guid string) error
+	RevokeSessionOfRefreshToken(ctx context.Context, guid string, id int) error
+	IsSessionActive(ctx context.Context, guid string, id int) (bool, error)
+	GetRefreshTokensByGUID(
+		ctx context.Context,
+		guid string,
+		pagination entities.Pagination,
+	) ([]entities.RefreshToken, error)
+	GetRefreshTokensByIP(
+		ctx context.Context,
+		ip string,
+		pagination entities.Pagination,
+	) ([]entities.RefreshToken, error)
+	RevokeRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error)
+	RevokeAllRefreshTokens(ctx context.Context, guid string) (int, error)
+	RevokeDisabledUsersRefreshTokens(ctx context.Context) ([]string, error)
+	RevokeTokenFamily(ctx context.Context, guid string, deniedBefore, expiresAt time.Time) (int, error)
+	DenyAccessTokens(ctx context.Context, guid string, deniedBefore, expiresAt time.Time) error
+	IsAccessTokenDenied(ctx context.Context, guid string, issuedAt time.Time) (bool, error)
 }
 
 type UsersService interface {
-	GetUserEmail(guid string) (string, error)
+	GetUserEmail(ctx context.Context, guid string) (string, error)
+	GetUserLocale(ctx context.Context, guid string) (string, error)
+	GetUserStatus(ctx context.Context, guid string) (string, error)
+	GetUsersByStatus(ctx context.Context, status string) ([]entities.User, error)
 }
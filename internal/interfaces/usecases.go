@@ -1,10 +1,28 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/DKhorkov/medods/internal/entities"
 )
 
 type UseCases interface {
-	CreateTokens(data entities.CreateTokensDTO) (*entities.Tokens, error)
-	RefreshTokens(user entities.RefreshTokensDTO) (*entities.Tokens, error)
+	CreateTokens(ctx context.Context, data entities.CreateTokensDTO) (*entities.Tokens, error)
+	RefreshTokens(ctx context.Context, user entities.RefreshTokensDTO) (*entities.Tokens, error)
+	RevokeTokens(ctx context.Context, revokeToken string) error
+	ValidateAccessToken(ctx context.Context, accessToken string) (string, error)
+}
+
+// DisabledUsersUseCases are batch operations over disabled users, which are run by workers and are not exposed
+// by controllers.
+type DisabledUsersUseCases interface {
+	RevokeDisabledUsersSessions(ctx context.Context) (int, error)
+}
+
+type AdminUseCases interface {
+	GetUserSessions(ctx context.Context, guid string, pagination entities.Pagination) (*entities.SessionsPage, error)
+	SearchSessionsByIP(ctx context.Context, ip string, pagination entities.Pagination) (*entities.SessionsPage, error)
+	RevokeSession(ctx context.Context, id int) error
+	RevokeAllUserSessions(ctx context.Context, guid string) (int, error)
+	ExpireUserAccessTokens(ctx context.Context, guid string) error
 }
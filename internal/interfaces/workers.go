@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	"io"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+// Worker is a background process, which lifecycle is managed by application.
+type Worker interface {
+	Run()
+	Stop()
+}
+
+// StatusReporter reports state of background component, which is shown to operators by admin API.
+type StatusReporter interface {
+	Status() any
+}
+
+// MetricsReporter writes counters and gauges of background component in Prometheus text exposition format, so that
+// they are scraped by monitoring instead of being read by operators.
+type MetricsReporter interface {
+	WriteMetrics(writer io.Writer) error
+}
+
+// HealthProbe checks dependency in background and reports result of the last check.
+type HealthProbe interface {
+	Health() entities.HealthStatus
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+// MockedNotifier stores all received notifications instead of sending them. If Err is set, it is returned
+// from Notify and notification is not stored.
+type MockedNotifier struct {
+	Err           error
+	notifications []entities.Notification
+	mutex         sync.Mutex
+}
+
+func (notifier *MockedNotifier) Notify(_ context.Context, notification entities.Notification) error {
+	if notifier.Err != nil {
+		return notifier.Err
+	}
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	notifier.notifications = append(notifier.notifications, notification)
+	return nil
+}
+
+// Notifications returns copy of all stored notifications.
+func (notifier *MockedNotifier) Notifications() []entities.Notification {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	notifications := make([]entities.Notification, len(notifier.notifications))
+	copy(notifications, notifier.notifications)
+	return notifications
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+// MockedEventPublisher stores all published events instead of delivering them.
+type MockedEventPublisher struct {
+	events []entities.SecurityEvent
+	mutex  sync.Mutex
+}
+
+func (publisher *MockedEventPublisher) Publish(_ context.Context, event entities.SecurityEvent) {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	publisher.events = append(publisher.events, event)
+}
+
+// Events returns copy of all published events.
+func (publisher *MockedEventPublisher) Events() []entities.SecurityEvent {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	events := make([]entities.SecurityEvent, len(publisher.events))
+	copy(events, publisher.events)
+	return events
+}
@@ -1,7 +1,9 @@
 package mocks
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/DKhorkov/medods/internal/entities"
@@ -10,40 +12,65 @@ import (
 
 type MockedAuthRepository struct {
 	RefreshTokensStorage map[int]*entities.RefreshToken
+
+	// DenylistStorage maps GUID to time, before which access tokens of user are denied. Lazily initialized.
+	DenylistStorage map[string]time.Time
+
+	// DisabledUsers are GUIDs of blocked and deleted users, since mock does not store users.
+	DisabledUsers map[string]bool
 }
 
-func (repo *MockedAuthRepository) CreateRefreshToken(guid, value string, ttl time.Time) (int, error) {
+func (repo *MockedAuthRepository) CreateRefreshToken(
+	_ context.Context,
+	data entities.CreateRefreshTokenDTO,
+) (int, error) {
 	for _, refreshToken := range repo.RefreshTokensStorage {
-		if refreshToken.Value == value {
+		if refreshToken.Value == data.Value {
 			return 0, errors.New("refresh token already exists")
 		}
 	}
 
 	refreshToken := &entities.RefreshToken{
-		ID:        len(repo.RefreshTokensStorage) + 1,
-		GUID:      guid,
-		Value:     value,
-		TTL:       ttl,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:               len(repo.RefreshTokensStorage) + 1,
+		GUID:             data.GUID,
+		Value:            data.Value,
+		TTL:              data.TTL,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		SessionStartedAt: data.SessionStartedAt,
+		IP:               data.IP,
 	}
 
 	repo.RefreshTokensStorage[refreshToken.ID] = refreshToken
 	return refreshToken.ID, nil
 }
 
-func (repo *MockedAuthRepository) GetRefreshTokenByID(id int) (*entities.RefreshToken, error) {
+func (repo *MockedAuthRepository) GetRefreshTokenByID(_ context.Context, id int) (*entities.RefreshToken, error) {
 	refreshToken := repo.RefreshTokensStorage[id]
-	if refreshToken != nil {
+	if refreshToken != nil && refreshToken.DeletedAt == nil {
 		return refreshToken, nil
 	}
 
 	return nil, customerrors.RefreshTokenNotFoundError{}
 }
 
-func (repo *MockedAuthRepository) GetRefreshTokenByGUID(guid string) (*entities.RefreshToken, error) {
+func (repo *MockedAuthRepository) GetRefreshTokenByIDIncludingDeleted(
+	_ context.Context,
+	id int,
+) (*entities.RefreshToken, error) {
+	if refreshToken := repo.RefreshTokensStorage[id]; refreshToken != nil {
+		return refreshToken, nil
+	}
+
+	return nil, customerrors.RefreshTokenNotFoundError{}
+}
+
+func (repo *MockedAuthRepository) GetRefreshTokenByGUID(
+	_ context.Context,
+	guid string,
+) (*entities.RefreshToken, error) {
 	for _, refreshToken := range repo.RefreshTokensStorage {
-		if refreshToken.GUID == guid {
+		if refreshToken.GUID == guid && refreshToken.DeletedAt == nil {
 			return refreshToken, nil
 		}
 	}
@@ -51,12 +78,139 @@ func (repo *MockedAuthRepository) GetRefreshTokenByGUID(guid string) (*entities.
 	return nil, customerrors.RefreshTokenNotFoundError{}
 }
 
-func (repo *MockedAuthRepository) DeleteRefreshToken(token *entities.RefreshToken) error {
+func (repo *MockedAuthRepository) DeleteRefreshToken(_ context.Context, token *entities.RefreshToken) error {
 	refreshToken := repo.RefreshTokensStorage[token.ID]
-	if refreshToken == nil {
+	if refreshToken == nil || refreshToken.DeletedAt != nil {
 		return customerrors.RefreshTokenNotFoundError{}
 	}
 
-	refreshToken.DeletedAt = time.Now().UTC()
+	deletedAt := time.Now().UTC()
+	refreshToken.DeletedAt = &deletedAt
+	return nil
+}
+
+func (repo *MockedAuthRepository) GetRefreshTokensByGUID(
+	_ context.Context,
+	guid string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	return repo.filterRefreshTokens(
+		func(refreshToken *entities.RefreshToken) bool {
+			return refreshToken.GUID == guid
+		},
+		pagination,
+	), nil
+}
+
+func (repo *MockedAuthRepository) GetRefreshTokensByIP(
+	_ context.Context,
+	ip string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	return repo.filterRefreshTokens(
+		func(refreshToken *entities.RefreshToken) bool {
+			return refreshToken.IP == ip
+		},
+		pagination,
+	), nil
+}
+
+func (repo *MockedAuthRepository) DeleteRefreshTokensByGUID(_ context.Context, guid string) (int, error) {
+	var deleted int
+	for _, refreshToken := range repo.RefreshTokensStorage {
+		if refreshToken.GUID == guid && refreshToken.DeletedAt == nil {
+			deletedAt := time.Now().UTC()
+			refreshToken.DeletedAt = &deletedAt
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (repo *MockedAuthRepository) DeleteRefreshTokensOfDisabledUsers(ctx context.Context) ([]string, error) {
+	var guids []string
+	for guid := range repo.DisabledUsers {
+		deleted, err := repo.DeleteRefreshTokensByGUID(ctx, guid)
+		if err != nil {
+			return nil, err
+		}
+
+		if deleted > 0 {
+			guids = append(guids, guid)
+		}
+	}
+
+	sort.Strings(guids)
+	return guids, nil
+}
+
+func (repo *MockedAuthRepository) DenyAccessTokens(_ context.Context, guid string, deniedBefore, _ time.Time) error {
+	if repo.DenylistStorage == nil {
+		repo.DenylistStorage = make(map[string]time.Time)
+	}
+
+	repo.DenylistStorage[guid] = deniedBefore
 	return nil
 }
+
+func (repo *MockedAuthRepository) GetAccessTokensDeniedBefore(_ context.Context, guid string) (time.Time, error) {
+	return repo.DenylistStorage[guid], nil
+}
+
+// filterRefreshTokens returns page of matching refresh tokens, ordered from the newest to the oldest.
+func (repo *MockedAuthRepository) filterRefreshTokens(
+	match func(refreshToken *entities.RefreshToken) bool,
+	pagination entities.Pagination,
+) []entities.RefreshToken {
+	var refreshTokens []entities.RefreshToken
+	for _, refreshToken := range repo.RefreshTokensStorage {
+		if match(refreshToken) {
+			refreshTokens = append(refreshTokens, *refreshToken)
+		}
+	}
+
+	sort.Slice(
+		refreshTokens,
+		func(i, j int) bool {
+			return refreshTokens[i].ID > refreshTokens[j].ID
+		},
+	)
+
+	if pagination.Offset >= len(refreshTokens) {
+		return nil
+	}
+
+	refreshTokens = refreshTokens[pagination.Offset:]
+	if len(refreshTokens) > pagination.Limit {
+		refreshTokens = refreshTokens[:pagination.Limit]
+	}
+
+	return refreshTokens
+}
+
+func (repo *MockedAuthRepository) PurgeRefreshTokens(
+	_ context.Context,
+	expiredBefore, deletedBefore time.Time,
+	limit int,
+) (int, error) {
+	var purged int
+	for id, refreshToken := range repo.RefreshTokensStorage {
+		if purged == limit {
+			break
+		}
+
+		deleted := refreshToken.DeletedAt != nil && refreshToken.DeletedAt.Before(deletedBefore)
+		if refreshToken.TTL.Before(expiredBefore) || deleted {
+			delete(repo.RefreshTokensStorage, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeAccessTokensDenylist purges nothing, because mock does not store denials expiration.
+func (repo *MockedAuthRepository) PurgeAccessTokensDenylist(_ context.Context, _ time.Time, _ int) (int, error) {
+	return 0, nil
+}
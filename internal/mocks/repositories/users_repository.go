@@ -1,8 +1,90 @@
 package mocks
 
+import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+)
+
+// MockedUsersRepository returns users from UsersStorage. If UsersStorage is nil, every GUID belongs to active
+// user with "example@yandex.ru" email and Locale (or "ru", if Locale is empty).
 type MockedUsersRepository struct {
+	UsersStorage map[string]*entities.User
+	Locale       string
+	Err          error
+}
+
+func (repo *MockedUsersRepository) GetUserEmail(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Email, nil
+}
+
+func (repo *MockedUsersRepository) GetUserLocale(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Locale, nil
+}
+
+func (repo *MockedUsersRepository) GetUserByGUID(_ context.Context, guid string) (*entities.User, error) {
+	if repo.Err != nil {
+		return nil, repo.Err
+	}
+
+	if repo.UsersStorage == nil {
+		locale := repo.Locale
+		if locale == "" {
+			locale = "ru"
+		}
+
+		return &entities.User{
+			GUID:   guid,
+			Email:  "example@yandex.ru",
+			Status: entities.UserStatusActive,
+			Locale: locale,
+		}, nil
+	}
+
+	user := repo.UsersStorage[guid]
+	if user == nil {
+		return nil, customerrors.UserNotFoundError{}
+	}
+
+	return user, nil
+}
+
+func (repo *MockedUsersRepository) GetUserByEmail(_ context.Context, email string) (*entities.User, error) {
+	if repo.Err != nil {
+		return nil, repo.Err
+	}
+
+	for _, user := range repo.UsersStorage {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, customerrors.UserNotFoundError{}
 }
 
-func (repo *MockedUsersRepository) GetUserEmail(guid string) (string, error) {
-	return "example@yandex.ru", nil
+func (repo *MockedUsersRepository) GetUsersByStatus(_ context.Context, status string) ([]entities.User, error) {
+	if repo.Err != nil {
+		return nil, repo.Err
+	}
+
+	var users []entities.User
+	for _, user := range repo.UsersStorage {
+		if user.Status == status {
+			users = append(users, *user)
+		}
+	}
+
+	return users, nil
 }
@@ -0,0 +1,26 @@
+package notifiers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// FanOutNotifier delivers notification through every provided notifier. Failure of one notifier does not
+// prevent delivery through others, all occurred errors are joined.
+type FanOutNotifier struct {
+	Notifiers []interfaces.Notifier
+}
+
+func (notifier *FanOutNotifier) Notify(ctx context.Context, notification entities.Notification) error {
+	var errs []error
+	for _, channel := range notifier.Notifiers {
+		if err := channel.Notify(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
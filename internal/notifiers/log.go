@@ -0,0 +1,24 @@
+package notifiers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/DKhorkov/medods/internal/entities"
+)
+
+// LogNotifier only writes notifications to log. Useful for development and as a fallback channel.
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+func (notifier *LogNotifier) Notify(_ context.Context, notification entities.Notification) error {
+	notifier.Logger.Info(
+		"Notification",
+		"Recipients", notification.Recipients,
+		"Subject", notification.Subject,
+		"Body", notification.Body,
+	)
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package notifiers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/DKhorkov/medods/internal/config"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+)
+
+const (
+	SMTPChannel    = "smtp"
+	WebhookChannel = "webhook"
+	LogChannel     = "log"
+)
+
+// New creates notifier, which fans out notifications to all channels, enabled in configs.
+func New(
+	notificationsConfig config.NotificationsConfig,
+	smtpConfig config.SMTPConfig,
+	logger *slog.Logger,
+) (*FanOutNotifier, error) {
+	notifier := &FanOutNotifier{}
+	for _, channel := range notificationsConfig.Channels {
+		switch channel {
+		case SMTPChannel:
+			notifier.Notifiers = append(notifier.Notifiers, &SMTPNotifier{SMTPConfig: smtpConfig})
+		case WebhookChannel:
+			notifier.Notifiers = append(
+				notifier.Notifiers,
+				&WebhookNotifier{
+					WebhookConfig: notificationsConfig.Webhook,
+					Client:        &http.Client{Timeout: notificationsConfig.Webhook.Timeout},
+				},
+			)
+		case LogChannel:
+			notifier.Notifiers = append(notifier.Notifiers, &LogNotifier{Logger: logger})
+		default:
+			return nil, customerrors.UnknownNotificationChannelError{Channel: channel}
+		}
+	}
+
+	return notifier, nil
+}
@@ -0,0 +1,19 @@
+package notifiers
+
+import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// OutboxNotifier does not deliver notification by itself, but persists it to outbox. Persisted notifications
+// are delivered later by workers.OutboxDispatcher, so they are not lost, if process stops before delivery.
+type OutboxNotifier struct {
+	OutboxRepository interfaces.OutboxRepository
+}
+
+func (notifier *OutboxNotifier) Notify(ctx context.Context, notification entities.Notification) error {
+	_, err := notifier.OutboxRepository.CreateOutboxMessage(ctx, notification)
+	return err
+}
@@ -0,0 +1,36 @@
+package notifiers
+
+import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	gomail "gopkg.in/gomail.v2"
+)
+
+// SMTPNotifier sends notifications as emails via SMTP server.
+type SMTPNotifier struct {
+	SMTPConfig config.SMTPConfig
+}
+
+func (notifier *SMTPNotifier) Notify(_ context.Context, notification entities.Notification) error {
+	message := gomail.NewMessage()
+	message.SetHeader("From", notifier.SMTPConfig.Login)
+	message.SetHeader("To", notification.Recipients...)
+	message.SetHeader("Subject", notification.Subject)
+	if notification.HTMLBody != "" {
+		message.SetBody("text/plain", notification.Body)
+		message.AddAlternative("text/html", notification.HTMLBody) // multipart/alternative
+	} else {
+		message.SetBody("text/html", notification.Body)
+	}
+
+	smtpClient := gomail.NewDialer(
+		notifier.SMTPConfig.Host,
+		notifier.SMTPConfig.Port,
+		notifier.SMTPConfig.Login,
+		notifier.SMTPConfig.Password,
+	)
+
+	return smtpClient.DialAndSend(message)
+}
@@ -0,0 +1,68 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
+)
+
+// WebhookNotifier sends notifications as JSON to configured URL via HTTP POST request.
+type WebhookNotifier struct {
+	WebhookConfig config.WebhookConfig
+	Client        *http.Client
+}
+
+func (notifier *WebhookNotifier) Notify(ctx context.Context, notification entities.Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifier.WebhookConfig.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		notifier.WebhookConfig.URL,
+		bytes.NewReader(body),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		request.Header.Set(requestid.Header, requestID)
+	}
+
+	response, err := notifier.getClient().Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return customerrors.WebhookDeliveryError{URL: notifier.WebhookConfig.URL, StatusCode: response.StatusCode}
+	}
+
+	return nil
+}
+
+func (notifier *WebhookNotifier) getClient() *http.Client {
+	if notifier.Client == nil {
+		return http.DefaultClient
+	}
+
+	return notifier.Client
+}
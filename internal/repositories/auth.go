@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"log/slog"
+
+	"github.com/DKhorkov/medods/internal/config"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/redis/go-redis/v9"
+)
+
+// activeRefreshTokenExistsError is returned on creation of refresh token, if user already has active one. User
+// has only one active refresh token, so that it happens only, if concurrent request has created it first.
+var activeRefreshTokenExistsError = customerrors.RefreshTokenRotatedError{
+	Message: "active refresh token of user has been already created by another request",
+}
+
+// NewAuthRepository creates refresh tokens repository for configured database backend. PostgreSQL and SQLite
+// share the same SQL, while MySQL differs only in few dialect specific statements. Memory backend keeps refresh
+// tokens in in-memory SQLite database, which is not cached. Other repositories are cached in Redis, if cache
+// address is configured.
+// Returned function releases resources of repository and should be called on shutdown.
+func NewAuthRepository(
+	databasesConfig config.DatabasesConfig,
+	cacheConfig config.CacheConfig,
+	dbConnector interfaces.DBConnector,
+	logger *slog.Logger,
+) (interfaces.AuthRepository, func(), error) {
+	var authRepository interfaces.AuthRepository
+	switch databasesConfig.Backend {
+	case config.MySQLDatabaseBackend:
+		authRepository = NewMySQLAuthRepository(dbConnector)
+	case config.MemoryDatabaseBackend:
+		// In-memory database is local to process, so that it gains nothing from cache:
+		return &CommonAuthRepository{DBConnector: dbConnector}, func() {}, nil
+	default:
+		authRepository = &CommonAuthRepository{DBConnector: dbConnector}
+	}
+
+	if cacheConfig.Address == "" {
+		return authRepository, func() {}, nil
+	}
+
+	client := redis.NewClient(
+		&redis.Options{
+			Addr:     cacheConfig.Address,
+			Password: cacheConfig.Password,
+			DB:       cacheConfig.DB,
+			// Otherwise deadline of cache calls is ignored by reads and writes of connection, which wait for
+			// default timeouts of client instead:
+			ContextTimeoutEnabled: true,
+		},
+	)
+
+	closeClient := func() {
+		_ = client.Close()
+	}
+
+	return NewCachedAuthRepository(authRepository, client, cacheConfig, logger), closeClient, nil
+}
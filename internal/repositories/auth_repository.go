@@ -1,6 +1,10 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -10,85 +14,560 @@ import (
 	"github.com/DKhorkov/medods/internal/interfaces"
 )
 
+// CommonAuthRepository stores refresh tokens in PostgreSQL, SQLite or MySQL. Its SQL is deliberately portable
+// between dialects ("$N" placeholders, which are rebound for MySQL by executor), except for few statements, which
+// are kept in authDialect. Zero value of dialect is PostgreSQL and SQLite one.
 type CommonAuthRepository struct {
 	DBConnector interfaces.DBConnector
+	dialect     *authDialect
 }
 
-func (repo *CommonAuthRepository) CreateRefreshToken(guid, value string, ttl time.Time) (int, error) {
-	var refreshTokenID int
-	connection := repo.DBConnector.GetConnection()
-	err := connection.QueryRow(
-		`
-			INSERT INTO refresh_tokens (guid, value, ttl) 
-			VALUES ($1, $2, $3)
-			RETURNING refresh_tokens.id
-		`,
-		guid,
-		value,
-		ttl,
-	).Scan(&refreshTokenID)
+// authDialect keeps statements, which can not be written portably for every supported database.
+type authDialect struct {
+	// lastInsertID tells, that ID of created refresh token is taken from sql.Result instead of RETURNING.
+	lastInsertID bool
+
+	insertRefreshTokenQuery        string
+	denyAccessTokensQuery          string
+	purgeRefreshTokensQuery        string
+	purgeAccessTokensDenylistQuery string
+}
+
+// portableAuthDialect is used by PostgreSQL and SQLite, which both support RETURNING, ON CONFLICT and LIMIT
+// in subqueries.
+var portableAuthDialect = &authDialect{
+	insertRefreshTokenQuery: `
+		INSERT INTO refresh_tokens (guid, value, ttl, session_started_at, ip, created_at) 
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING refresh_tokens.id
+	`,
+	denyAccessTokensQuery: `
+		INSERT INTO access_tokens_denylist (guid, denied_before, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (guid) DO UPDATE
+		SET denied_before = excluded.denied_before,
+		    expires_at = excluded.expires_at
+	`,
+	purgeRefreshTokensQuery: `
+		DELETE FROM refresh_tokens
+		WHERE id IN (
+			SELECT rt.id
+			FROM refresh_tokens AS rt
+			WHERE rt.ttl < $1
+			   OR rt.deleted_at < $2
+			LIMIT $3
+		)
+	`,
+	purgeAccessTokensDenylistQuery: `
+		DELETE FROM access_tokens_denylist
+		WHERE guid IN (
+			SELECT atd.guid
+			FROM access_tokens_denylist AS atd
+			WHERE atd.expires_at < $1
+			LIMIT $2
+		)
+	`,
+}
+
+func (repo *CommonAuthRepository) getDialect() *authDialect {
+	if repo.dialect == nil {
+		return portableAuthDialect
+	}
+
+	return repo.dialect
+}
+
+func (repo *CommonAuthRepository) CreateRefreshToken(
+	ctx context.Context,
+	data entities.CreateRefreshTokenDTO,
+) (int, error) {
+	dialect := repo.getDialect()
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	args := []any{
+		data.GUID,
+		data.Value,
+		data.TTL.UTC(),
+		data.SessionStartedAt.UTC(),
+		data.IP,
+		time.Now().UTC(),
+	}
+
+	var refreshTokenID int64
+	var err error
+	if dialect.lastInsertID {
+		var result sql.Result
+		if result, err = executor.ExecContext(ctx, dialect.insertRefreshTokenQuery, args...); err == nil {
+			refreshTokenID, err = result.LastInsertId()
+		}
+	} else {
+		err = executor.QueryRowContext(ctx, dialect.insertRefreshTokenQuery, args...).Scan(&refreshTokenID)
+	}
+
+	if database.IsUniqueViolation(err) {
+		return 0, activeRefreshTokenExistsError
+	}
 
 	if err != nil {
 		return 0, err
 	}
 
-	return refreshTokenID, nil
+	return int(refreshTokenID), nil
 }
 
-func (repo *CommonAuthRepository) GetRefreshTokenByID(id int) (*entities.RefreshToken, error) {
-	refreshToken := &entities.RefreshToken{}
-	columns := database.GetEntityColumns(refreshToken)
-	connection := repo.DBConnector.GetConnection()
-	err := connection.QueryRow(
+func (repo *CommonAuthRepository) GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	return queryRefreshToken(
+		ctx,
+		repo.DBConnector,
 		`
-			SELECT *
+			SELECT rt.id,
+			       rt.guid,
+			       rt.ttl,
+			       rt.value,
+			       rt.created_at,
+			       rt.updated_at,
+			       rt.session_started_at,
+			       rt.ip,
+			       rt.deleted_at
 			FROM refresh_tokens AS rt
 			WHERE rt.id = $1 
-			  AND rt.ttl > CURRENT_TIMESTAMP
+			  AND rt.ttl > $2
 			  AND rt.deleted_at IS NULL
 		`,
 		id,
-	).Scan(columns...)
+		time.Now().UTC(),
+	)
+}
+
+// GetRefreshTokenByIDIncludingDeleted returns refresh token with provided ID, even if it has been deleted or has
+// expired. It is read on primary database, because deletion may have not been replicated yet.
+func (repo *CommonAuthRepository) GetRefreshTokenByIDIncludingDeleted(
+	ctx context.Context,
+	id int,
+) (*entities.RefreshToken, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	refreshToken, err := scanRefreshToken(
+		executor.QueryRowContext(
+			ctx,
+			`
+				SELECT rt.id,
+				       rt.guid,
+				       rt.ttl,
+				       rt.value,
+				       rt.created_at,
+				       rt.updated_at,
+				       rt.session_started_at,
+				       rt.ip,
+				       rt.deleted_at
+				FROM refresh_tokens AS rt
+				WHERE rt.id = $1
+			`,
+			id,
+		),
+	)
 
-	if err != nil && !strings.Contains(err.Error(), "storing driver.Value type <nil> into type *time.Time") {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, customerrors.RefreshTokenNotFoundError{}
 	}
 
-	return refreshToken, nil
+	return refreshToken, err
 }
 
-func (repo *CommonAuthRepository) GetRefreshTokenByGUID(guid string) (*entities.RefreshToken, error) {
-	refreshToken := &entities.RefreshToken{}
-	columns := database.GetEntityColumns(refreshToken)
-	connection := repo.DBConnector.GetConnection()
-	err := connection.QueryRow(
+func (repo *CommonAuthRepository) GetRefreshTokenByGUID(
+	ctx context.Context,
+	guid string,
+) (*entities.RefreshToken, error) {
+	return queryRefreshToken(
+		ctx,
+		repo.DBConnector,
 		`
-			SELECT *
+			SELECT rt.id,
+			       rt.guid,
+			       rt.ttl,
+			       rt.value,
+			       rt.created_at,
+			       rt.updated_at,
+			       rt.session_started_at,
+			       rt.ip,
+			       rt.deleted_at
 			FROM refresh_tokens AS rt
 			WHERE rt.guid = $1 
-			  AND rt.ttl > CURRENT_TIMESTAMP
+			  AND rt.ttl > $2
 			  AND rt.deleted_at IS NULL
+			ORDER BY rt.created_at DESC
+			LIMIT 1
 		`,
 		guid,
-	).Scan(columns...)
+		time.Now().UTC(),
+	)
+}
 
-	if err != nil && !strings.Contains(err.Error(), "storing driver.Value type <nil> into type *time.Time") {
-		return nil, customerrors.RefreshTokenNotFoundError{}
+// DeleteRefreshToken deletes active refresh token. If refresh token has been already deleted, for example, by
+// concurrent rotation, RefreshTokenNotFoundError is returned.
+func (repo *CommonAuthRepository) DeleteRefreshToken(ctx context.Context, token *entities.RefreshToken) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
+		`
+			UPDATE refresh_tokens
+			SET deleted_at = $1
+			WHERE id = $2
+			  AND deleted_at IS NULL
+		`,
+		time.Now().UTC(),
+		token.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return customerrors.RefreshTokenNotFoundError{}
+	}
+
+	return nil
+}
+
+func (repo *CommonAuthRepository) GetRefreshTokensByGUID(
+	ctx context.Context,
+	guid string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	rows, err := executor.QueryContext(
+		ctx,
+		`
+			SELECT rt.id,
+			       rt.guid,
+			       rt.ttl,
+			       rt.value,
+			       rt.created_at,
+			       rt.updated_at,
+			       rt.session_started_at,
+			       rt.ip,
+			       rt.deleted_at
+			FROM refresh_tokens AS rt
+			WHERE rt.guid = $1
+			ORDER BY rt.created_at DESC, rt.id DESC
+			LIMIT $2 OFFSET $3
+		`,
+		guid,
+		pagination.Limit,
+		pagination.Offset,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRefreshTokens(rows)
+}
+
+func (repo *CommonAuthRepository) GetRefreshTokensByIP(
+	ctx context.Context,
+	ip string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	rows, err := executor.QueryContext(
+		ctx,
+		`
+			SELECT rt.id,
+			       rt.guid,
+			       rt.ttl,
+			       rt.value,
+			       rt.created_at,
+			       rt.updated_at,
+			       rt.session_started_at,
+			       rt.ip,
+			       rt.deleted_at
+			FROM refresh_tokens AS rt
+			WHERE rt.ip = $1
+			ORDER BY rt.created_at DESC, rt.id DESC
+			LIMIT $2 OFFSET $3
+		`,
+		ip,
+		pagination.Limit,
+		pagination.Offset,
+	)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return refreshToken, nil
+	return scanRefreshTokens(rows)
 }
 
-func (repo *CommonAuthRepository) DeleteRefreshToken(token *entities.RefreshToken) error {
-	connection := repo.DBConnector.GetConnection()
-	err := connection.QueryRow(
+// DeleteRefreshTokensByGUID deletes all active refresh tokens of user and returns their number.
+func (repo *CommonAuthRepository) DeleteRefreshTokensByGUID(ctx context.Context, guid string) (int, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
 		`
 			UPDATE refresh_tokens
-			SET deleted_at = CURRENT_TIMESTAMP
-			WHERE id = $1
+			SET deleted_at = $1
+			WHERE guid = $2
+			  AND deleted_at IS NULL
 		`,
-		token.ID,
-	).Err()
+		time.Now().UTC(),
+		guid,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// DeleteRefreshTokensOfDisabledUsers deletes active refresh tokens of blocked and deleted users of users table and
+// returns GUIDs of users, which refresh tokens have been deleted. Users without active refresh tokens, including
+// users, which sessions have been already revoked, are not touched, so that it is cheap to call periodically. It
+// should be called in transaction, so that deleted refresh tokens are the selected ones.
+func (repo *CommonAuthRepository) DeleteRefreshTokensOfDisabledUsers(ctx context.Context) ([]string, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	rows, err := executor.QueryContext(
+		ctx,
+		`
+			SELECT DISTINCT rt.guid
+			FROM refresh_tokens AS rt
+			JOIN users AS u ON u.guid = rt.guid
+			WHERE rt.deleted_at IS NULL
+			  AND u.status IN ($1, $2)
+		`,
+		entities.UserStatusBlocked,
+		entities.UserStatusDeleted,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guids, err := scanStrings(rows)
+	if err != nil || len(guids) == 0 {
+		return nil, err
+	}
+
+	// Placeholders are numbered, so that they are rebound for MySQL as well:
+	args := []any{time.Now().UTC()}
+	placeholders := make([]string, 0, len(guids))
+	for _, guid := range guids {
+		args = append(args, guid)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	_, err = executor.ExecContext(
+		ctx,
+		`
+			UPDATE refresh_tokens
+			SET deleted_at = $1
+			WHERE deleted_at IS NULL
+			  AND guid IN (`+strings.Join(placeholders, ", ")+`)
+		`,
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return guids, nil
+}
+
+// DenyAccessTokens denies access tokens of user, issued before deniedBefore. Denial is kept until expiresAt,
+// after which all denied access tokens are expired anyway.
+func (repo *CommonAuthRepository) DenyAccessTokens(
+	ctx context.Context,
+	guid string,
+	deniedBefore, expiresAt time.Time,
+) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err := executor.ExecContext(
+		ctx,
+		repo.getDialect().denyAccessTokensQuery,
+		guid,
+		deniedBefore.UTC(),
+		expiresAt.UTC(),
+		time.Now().UTC(),
+	)
 
 	return err
 }
+
+// GetAccessTokensDeniedBefore returns time, before which access tokens of user are denied, or zero time,
+// if access tokens of user are not denied.
+func (repo *CommonAuthRepository) GetAccessTokensDeniedBefore(ctx context.Context, guid string) (time.Time, error) {
+	var deniedBefore time.Time
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	err := executor.QueryRowContext(
+		ctx,
+		`
+			SELECT atd.denied_before
+			FROM access_tokens_denylist AS atd
+			WHERE atd.guid = $1
+			  AND atd.expires_at > $2
+		`,
+		guid,
+		time.Now().UTC(),
+	).Scan(&deniedBefore)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+
+	return deniedBefore, err
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+func scanRefreshTokens(rows *sql.Rows) ([]entities.RefreshToken, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var refreshTokens []entities.RefreshToken
+	for rows.Next() {
+		refreshToken, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		refreshTokens = append(refreshTokens, *refreshToken)
+	}
+
+	return refreshTokens, rows.Err()
+}
+
+// queryRefreshToken selects refresh token on read replica, if there is one, and on primary database, if replica
+// fails or has not replicated refresh token yet.
+func queryRefreshToken(
+	ctx context.Context,
+	dbConnector interfaces.DBConnector,
+	query string,
+	args ...any,
+) (*entities.RefreshToken, error) {
+	if replica := database.GetReplicaExecutor(ctx, dbConnector); replica != nil {
+		if refreshToken, err := scanRefreshToken(replica.QueryRowContext(ctx, query, args...)); err == nil {
+			return refreshToken, nil
+		}
+	}
+
+	executor := database.GetExecutor(ctx, dbConnector)
+	refreshToken, err := scanRefreshToken(executor.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, customerrors.RefreshTokenNotFoundError{}
+	}
+
+	return refreshToken, err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRefreshToken scans refresh token, selected with columns in next order: id, guid, ttl, value, created_at,
+// updated_at, session_started_at, ip, deleted_at.
+func scanRefreshToken(row rowScanner) (*entities.RefreshToken, error) {
+	var (
+		refreshToken     entities.RefreshToken
+		sessionStartedAt sql.NullTime
+		deletedAt        sql.NullTime
+	)
+
+	err := row.Scan(
+		&refreshToken.ID,
+		&refreshToken.GUID,
+		&refreshToken.TTL,
+		&refreshToken.Value,
+		&refreshToken.CreatedAt,
+		&refreshToken.UpdatedAt,
+		&sessionStartedAt,
+		&refreshToken.IP,
+		&deletedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens, created before sessions tracking, are considered to start their own session:
+	refreshToken.SessionStartedAt = refreshToken.CreatedAt
+	if sessionStartedAt.Valid {
+		refreshToken.SessionStartedAt = sessionStartedAt.Time
+	}
+
+	if deletedAt.Valid {
+		refreshToken.DeletedAt = &deletedAt.Time
+	}
+
+	return &refreshToken, nil
+}
+
+// PurgeRefreshTokens physically deletes up to limit refresh tokens, which expired before expiredBefore or
+// were deleted before deletedBefore.
+func (repo *CommonAuthRepository) PurgeRefreshTokens(
+	ctx context.Context,
+	expiredBefore, deletedBefore time.Time,
+	limit int,
+) (int, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
+		repo.getDialect().purgeRefreshTokensQuery,
+		expiredBefore.UTC(),
+		deletedBefore.UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// PurgeAccessTokensDenylist deletes up to limit denials, which expired before provided time.
+func (repo *CommonAuthRepository) PurgeAccessTokensDenylist(
+	ctx context.Context,
+	before time.Time,
+	limit int,
+) (int, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
+		repo.getDialect().purgeAccessTokensDenylistQuery,
+		before.UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
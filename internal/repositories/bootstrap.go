@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+)
+
+// BootstrapDatabase prepares database on start of server or command line tool. Migrations are applied, if
+// config.DatabasesConfig.MigrateOnStart is set, and always for memory backend, which in-memory database is either
+// empty or restored from snapshot of older schema. Users of memory backend are seeded, if users file is configured.
+func BootstrapDatabase(
+	ctx context.Context,
+	databasesConfig config.DatabasesConfig,
+	defaultLocale string,
+	dbConnector *database.CommonDBConnector,
+	logger *slog.Logger,
+) error {
+	isMemoryBackend := databasesConfig.Backend == config.MemoryDatabaseBackend
+	if databasesConfig.MigrateOnStart || isMemoryBackend {
+		migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+		if err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Database migrations applied", "Applied", applied)
+	}
+
+	if isMemoryBackend && databasesConfig.Memory.UsersPath != "" {
+		seeded, err := SeedUsers(ctx, dbConnector, databasesConfig.Memory.UsersPath, defaultLocale)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Users seeded", "Seeded", seeded)
+	}
+
+	return nil
+}
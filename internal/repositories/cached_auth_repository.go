@@ -0,0 +1,293 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// invalidatedCacheValue marks entry, which has been changed in database. Such entries are read from database
+	// and are not cached again until the mark expires, so that neither rolled back transaction, nor concurrent
+	// read of the old row are able to put stale entry into cache.
+	invalidatedCacheValue = "invalidated"
+
+	// notDeniedCacheValue marks user, whose access tokens are not denied.
+	notDeniedCacheValue = "none"
+)
+
+// errCacheUnavailable is returned instead of cached value during backoff after failed cache call. Database is used
+// instead and value is not cached.
+var errCacheUnavailable = errors.New("cache is unavailable")
+
+// CachedAuthRepository caches active refresh tokens and access tokens denylist of AuthRepository in Redis with
+// cache-aside semantics. If cache is unavailable, AuthRepository is used directly. After failed cache call cache is
+// not read for config.CacheConfig.FailureBackoff, while invalidations are still sent, so that they are not lost.
+type CachedAuthRepository struct {
+	interfaces.AuthRepository
+	client      redis.UniversalClient
+	cacheConfig config.CacheConfig
+	logger      *slog.Logger
+
+	// unavailableUntil is UnixNano time, until which cache is not read after failed call.
+	unavailableUntil atomic.Int64
+}
+
+func (repo *CachedAuthRepository) GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	refreshToken, cacheErr := repo.getCachedRefreshToken(ctx, id)
+	if cacheErr == nil && refreshToken != nil {
+		return refreshToken, nil
+	}
+
+	refreshToken, err := repo.AuthRepository.GetRefreshTokenByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if errors.Is(cacheErr, redis.Nil) {
+		repo.cacheRefreshToken(ctx, refreshToken)
+	}
+
+	return refreshToken, nil
+}
+
+func (repo *CachedAuthRepository) DeleteRefreshToken(ctx context.Context, token *entities.RefreshToken) error {
+	repo.invalidate(ctx, refreshTokenCacheKey(token.ID))
+	return repo.AuthRepository.DeleteRefreshToken(ctx, token)
+}
+
+// DeleteRefreshTokensByGUID marks all cached refresh tokens of user, created before deletion, as invalidated.
+// Mark is set again after deletion to cover refresh tokens, which have been created concurrently.
+func (repo *CachedAuthRepository) DeleteRefreshTokensByGUID(ctx context.Context, guid string) (int, error) {
+	repo.invalidateRefreshTokensByGUID(ctx, guid)
+	deleted, err := repo.AuthRepository.DeleteRefreshTokensByGUID(ctx, guid)
+	if err != nil {
+		return 0, err
+	}
+
+	repo.invalidateRefreshTokensByGUID(ctx, guid)
+	return deleted, nil
+}
+
+// DeleteRefreshTokensOfDisabledUsers marks cached refresh tokens of users, which refresh tokens have been deleted,
+// as invalidated. Users are not known before deletion, so that they are marked only after it.
+func (repo *CachedAuthRepository) DeleteRefreshTokensOfDisabledUsers(ctx context.Context) ([]string, error) {
+	guids, err := repo.AuthRepository.DeleteRefreshTokensOfDisabledUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, guid := range guids {
+		repo.invalidateRefreshTokensByGUID(ctx, guid)
+	}
+
+	return guids, nil
+}
+
+func (repo *CachedAuthRepository) DenyAccessTokens(
+	ctx context.Context,
+	guid string,
+	deniedBefore, expiresAt time.Time,
+) error {
+	repo.invalidate(ctx, accessTokensDeniedBeforeCacheKey(guid))
+	return repo.AuthRepository.DenyAccessTokens(ctx, guid, deniedBefore, expiresAt)
+}
+
+// GetAccessTokensDeniedBefore is called on every access token validation, so absence of denial is cached too.
+func (repo *CachedAuthRepository) GetAccessTokensDeniedBefore(ctx context.Context, guid string) (time.Time, error) {
+	cached, cacheErr := repo.getCachedValue(ctx, accessTokensDeniedBeforeCacheKey(guid))
+	switch {
+	case cacheErr == nil && cached == notDeniedCacheValue:
+		return time.Time{}, nil
+	case cacheErr == nil && cached != invalidatedCacheValue:
+		if deniedBefore, err := time.Parse(time.RFC3339Nano, cached); err == nil {
+			return deniedBefore, nil
+		}
+	case cacheErr != nil && !errors.Is(cacheErr, redis.Nil) && !errors.Is(cacheErr, errCacheUnavailable):
+		repo.cacheFailed(ctx, "Failed to get cached access tokens denial", cacheErr)
+	}
+
+	deniedBefore, err := repo.AuthRepository.GetAccessTokensDeniedBefore(ctx, guid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if errors.Is(cacheErr, redis.Nil) {
+		value := notDeniedCacheValue
+		if !deniedBefore.IsZero() {
+			value = deniedBefore.UTC().Format(time.RFC3339Nano)
+		}
+
+		repo.cacheValue(ctx, accessTokensDeniedBeforeCacheKey(guid), value)
+	}
+
+	return deniedBefore, nil
+}
+
+// getCachedRefreshToken returns cached refresh token, if it can be trusted, or nil, if database should be
+// used instead. Error is redis.Nil, if refresh token is not cached.
+func (repo *CachedAuthRepository) getCachedRefreshToken(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	cached, err := repo.getCachedValue(ctx, refreshTokenCacheKey(id))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) && !errors.Is(err, errCacheUnavailable) {
+			repo.cacheFailed(ctx, "Failed to get cached refresh token", err)
+		}
+
+		return nil, err
+	}
+
+	if cached == invalidatedCacheValue {
+		return nil, nil
+	}
+
+	var refreshToken entities.RefreshToken
+	if err = json.Unmarshal([]byte(cached), &refreshToken); err != nil {
+		return nil, nil
+	}
+
+	if !refreshToken.TTL.After(time.Now()) {
+		return nil, nil
+	}
+
+	invalidatedAt, err := repo.getCachedValue(ctx, refreshTokensInvalidatedAtCacheKey(refreshToken.GUID))
+	switch {
+	case errors.Is(err, redis.Nil):
+		return &refreshToken, nil
+	case errors.Is(err, errCacheUnavailable):
+		return nil, err
+	case err != nil:
+		repo.cacheFailed(ctx, "Failed to get refresh tokens invalidation", err)
+		return nil, err
+	}
+
+	if invalidatedAtTime, err := time.Parse(time.RFC3339Nano, invalidatedAt); err == nil &&
+		refreshToken.CreatedAt.After(invalidatedAtTime) {
+		return &refreshToken, nil
+	}
+
+	return nil, nil
+}
+
+func (repo *CachedAuthRepository) cacheRefreshToken(ctx context.Context, refreshToken *entities.RefreshToken) {
+	value, err := json.Marshal(refreshToken)
+	if err != nil {
+		return
+	}
+
+	repo.cacheValue(ctx, refreshTokenCacheKey(refreshToken.ID), string(value))
+}
+
+// getCachedValue reads value from cache, unless cache is unavailable after failed call, in which case
+// errCacheUnavailable is returned without calling Redis.
+func (repo *CachedAuthRepository) getCachedValue(ctx context.Context, key string) (string, error) {
+	if !repo.cacheAvailable() {
+		return "", errCacheUnavailable
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, repo.cacheConfig.Timeout)
+	defer cancel()
+
+	return repo.client.Get(cacheCtx, key).Result()
+}
+
+// cacheValue caches value, unless key already exists. Existing key is either up-to-date or invalidated.
+func (repo *CachedAuthRepository) cacheValue(ctx context.Context, key, value string) {
+	if !repo.cacheAvailable() {
+		return
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, repo.cacheConfig.Timeout)
+	defer cancel()
+
+	if err := repo.client.SetNX(cacheCtx, key, value, repo.cacheConfig.TTL).Err(); err != nil {
+		repo.cacheFailed(ctx, "Failed to cache value", err)
+	}
+}
+
+// invalidate marks entry as invalidated before it is changed in database.
+func (repo *CachedAuthRepository) invalidate(ctx context.Context, key string) {
+	cacheCtx, cancel := context.WithTimeout(ctx, repo.cacheConfig.Timeout)
+	defer cancel()
+
+	if err := repo.client.Set(cacheCtx, key, invalidatedCacheValue, repo.invalidationTTL()).Err(); err != nil {
+		repo.cacheFailed(ctx, "Failed to invalidate cached value", err)
+	}
+}
+
+func (repo *CachedAuthRepository) invalidateRefreshTokensByGUID(ctx context.Context, guid string) {
+	cacheCtx, cancel := context.WithTimeout(ctx, repo.cacheConfig.Timeout)
+	defer cancel()
+
+	err := repo.client.Set(
+		cacheCtx,
+		refreshTokensInvalidatedAtCacheKey(guid),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		repo.invalidationTTL(),
+	).Err()
+
+	if err != nil {
+		repo.cacheFailed(ctx, "Failed to invalidate cached refresh tokens", err)
+	}
+}
+
+// invalidationTTL outlives entries, which could have been read from database before invalidation and cached
+// after it.
+func (repo *CachedAuthRepository) invalidationTTL() time.Duration {
+	return repo.cacheConfig.TTL * 2
+}
+
+// cacheAvailable reports, whether backoff after failed cache call is over.
+func (repo *CachedAuthRepository) cacheAvailable() bool {
+	return time.Now().UnixNano() >= repo.unavailableUntil.Load()
+}
+
+// cacheFailed logs failed cache call and starts backoff, unless call has failed, because ctx of request is done.
+func (repo *CachedAuthRepository) cacheFailed(ctx context.Context, message string, err error) {
+	repo.logger.Warn(
+		message,
+		"Traceback",
+		logging.GetLogTraceback(),
+		"Error",
+		err,
+	)
+
+	if ctx.Err() == nil && repo.cacheConfig.FailureBackoff > 0 {
+		repo.unavailableUntil.Store(time.Now().Add(repo.cacheConfig.FailureBackoff).UnixNano())
+	}
+}
+
+func refreshTokenCacheKey(id int) string {
+	return fmt.Sprintf("medods:refresh_token:%d", id)
+}
+
+func refreshTokensInvalidatedAtCacheKey(guid string) string {
+	return "medods:refresh_tokens_invalidated_at:" + guid
+}
+
+func accessTokensDeniedBeforeCacheKey(guid string) string {
+	return "medods:access_tokens_denied_before:" + guid
+}
+
+func NewCachedAuthRepository(
+	authRepository interfaces.AuthRepository,
+	client redis.UniversalClient,
+	cacheConfig config.CacheConfig,
+	logger *slog.Logger,
+) *CachedAuthRepository {
+	return &CachedAuthRepository{
+		AuthRepository: authRepository,
+		client:         client,
+		cacheConfig:    cacheConfig,
+		logger:         logger,
+	}
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// mysqlAuthDialect is used by MySQL and MariaDB, which do not support RETURNING, ON CONFLICT and LIMIT
+// in subqueries. VALUES() is used instead of row alias, because MariaDB does not support the latter. Placeholders
+// are rebound by executor, so that they appear in the order of their numbers.
+var mysqlAuthDialect = &authDialect{
+	lastInsertID: true,
+	insertRefreshTokenQuery: `
+		INSERT INTO refresh_tokens (guid, value, ttl, session_started_at, ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+	denyAccessTokensQuery: `
+		INSERT INTO access_tokens_denylist (guid, denied_before, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON DUPLICATE KEY UPDATE
+		    denied_before = VALUES(denied_before),
+		    expires_at = VALUES(expires_at)
+	`,
+	purgeRefreshTokensQuery: `
+		DELETE FROM refresh_tokens
+		WHERE ttl < $1
+		   OR deleted_at < $2
+		LIMIT $3
+	`,
+	purgeAccessTokensDenylistQuery: `
+		DELETE FROM access_tokens_denylist
+		WHERE expires_at < $1
+		LIMIT $2
+	`,
+}
+
+// NewMySQLAuthRepository creates refresh tokens repository for MySQL or MariaDB. DATETIME columns do not store
+// time zone, so all timestamps are written and compared in UTC, as for other dialects.
+func NewMySQLAuthRepository(dbConnector interfaces.DBConnector) *CommonAuthRepository {
+	return &CommonAuthRepository{
+		DBConnector: dbConnector,
+		dialect:     mysqlAuthDialect,
+	}
+}
@@ -0,0 +1,265 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/security"
+)
+
+// CommonOutboxRepository stores notifications in the same database as refresh tokens. All timestamps are
+// provided by application in UTC for dialect independent comparison.
+type CommonOutboxRepository struct {
+	DBConnector interfaces.DBConnector
+}
+
+func (repo *CommonOutboxRepository) CreateOutboxMessage(
+	ctx context.Context,
+	notification entities.Notification,
+) (string, error) {
+	id, err := security.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err = executor.ExecContext(
+		ctx,
+		`
+			INSERT INTO notifications_outbox (id, payload, status, next_attempt_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		id,
+		string(payload),
+		entities.OutboxMessagePending,
+		now,
+		now,
+		now,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (repo *CommonOutboxRepository) GetPendingOutboxMessages(
+	ctx context.Context,
+	limit int,
+) ([]entities.OutboxMessage, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	rows, err := executor.QueryContext(
+		ctx,
+		`
+			SELECT o.id,
+			       o.payload,
+			       o.status,
+			       o.attempts,
+			       o.next_attempt_at,
+			       o.last_error,
+			       o.created_at,
+			       o.updated_at
+			FROM notifications_outbox AS o
+			WHERE o.status = $1
+			  AND o.next_attempt_at <= $2
+			ORDER BY o.next_attempt_at
+			LIMIT $3
+		`,
+		entities.OutboxMessagePending,
+		time.Now().UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var messages []entities.OutboxMessage
+	for rows.Next() {
+		var (
+			message   entities.OutboxMessage
+			payload   string
+			lastError sql.NullString
+		)
+
+		err = rows.Scan(
+			&message.ID,
+			&payload,
+			&message.Status,
+			&message.Attempts,
+			&message.NextAttemptAt,
+			&lastError,
+			&message.CreatedAt,
+			&message.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal([]byte(payload), &message.Notification); err != nil {
+			return nil, err
+		}
+
+		message.Notification.ID = message.ID
+		message.LastError = lastError.String
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// ClaimOutboxMessage postpones next attempt of pending message till leaseUntil. Only one dispatcher is able
+// to claim due message. If dispatcher dies before marking message, it will be retried after lease expiration.
+func (repo *CommonOutboxRepository) ClaimOutboxMessage(
+	ctx context.Context,
+	id string,
+	leaseUntil time.Time,
+) (bool, error) {
+	now := time.Now().UTC()
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
+		`
+			UPDATE notifications_outbox
+			SET next_attempt_at = $1,
+			    updated_at = $2
+			WHERE id = $3
+			  AND status = $4
+			  AND next_attempt_at <= $5
+		`,
+		leaseUntil.UTC(),
+		now,
+		id,
+		entities.OutboxMessagePending,
+		now,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (repo *CommonOutboxRepository) MarkOutboxMessageDelivered(ctx context.Context, id string) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err := executor.ExecContext(
+		ctx,
+		`
+			UPDATE notifications_outbox
+			SET status = $1,
+			    attempts = attempts + 1,
+			    last_error = NULL,
+			    updated_at = $2
+			WHERE id = $3
+		`,
+		entities.OutboxMessageDelivered,
+		time.Now().UTC(),
+		id,
+	)
+
+	return err
+}
+
+func (repo *CommonOutboxRepository) MarkOutboxMessageFailed(
+	ctx context.Context,
+	id string,
+	lastError string,
+	nextAttemptAt time.Time,
+) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err := executor.ExecContext(
+		ctx,
+		`
+			UPDATE notifications_outbox
+			SET attempts = attempts + 1,
+			    last_error = $1,
+			    next_attempt_at = $2,
+			    updated_at = $3
+			WHERE id = $4
+		`,
+		lastError,
+		nextAttemptAt.UTC(),
+		time.Now().UTC(),
+		id,
+	)
+
+	return err
+}
+
+func (repo *CommonOutboxRepository) MarkOutboxMessageDead(ctx context.Context, id string, lastError string) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err := executor.ExecContext(
+		ctx,
+		`
+			UPDATE notifications_outbox
+			SET status = $1,
+			    attempts = attempts + 1,
+			    last_error = $2,
+			    updated_at = $3
+			WHERE id = $4
+		`,
+		entities.OutboxMessageDead,
+		lastError,
+		time.Now().UTC(),
+		id,
+	)
+
+	return err
+}
+
+// PurgeOutbox deletes up to limit delivered and dead messages, which were last updated before provided time.
+// Limited subquery is wrapped into derived table, since MySQL does not support LIMIT in IN subqueries, so that
+// statement is the same for every dialect.
+func (repo *CommonOutboxRepository) PurgeOutbox(ctx context.Context, before time.Time, limit int) (int, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	result, err := executor.ExecContext(
+		ctx,
+		`
+			DELETE FROM notifications_outbox
+			WHERE id IN (
+			    SELECT purged.id
+			    FROM (
+			        SELECT o.id
+			        FROM notifications_outbox AS o
+			        WHERE o.status IN ($1, $2)
+			          AND o.updated_at < $3
+			        LIMIT $4
+			    ) AS purged
+			)
+		`,
+		entities.OutboxMessageDelivered,
+		entities.OutboxMessageDead,
+		before.UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/protobuf/generated/go/sso"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// defaultSSOCacheSize replaces non-positive config.SSOConfig.CacheSize, with which nothing would be cached.
+const defaultSSOCacheSize = 10000
+
+type cachedUser struct {
+	user      entities.User
+	expiresAt time.Time
+}
+
+// SSOUsersRepository fetches users from hmtm-sso service, where users are identified by numeric IDs, which are
+// used as GUIDs. SSO does not know anything about users statuses and locales, so every existing user is active
+// and has no locale, which leads to usage of default one.
+//
+// Users are cached for config.SSOConfig.CacheTTL. At most config.SSOConfig.CacheSize users are cached by GUID:
+// all of them have the same TTL, so that they are kept in order of expiration and the first to expire are
+// evicted, when cache is full. SSO does not paginate list of users, so the whole list, which is used to find
+// users by email, is cached as well.
+type SSOUsersRepository struct {
+	client         sso.UsersServiceClient
+	ssoConfig      config.SSOConfig
+	mutex          sync.RWMutex
+	cache          map[string]*list.Element
+	expirations    *list.List
+	users          []entities.User
+	usersExpiresAt time.Time
+}
+
+func NewSSOUsersRepository(client sso.UsersServiceClient, ssoConfig config.SSOConfig) *SSOUsersRepository {
+	if ssoConfig.CacheSize <= 0 {
+		ssoConfig.CacheSize = defaultSSOCacheSize
+	}
+
+	return &SSOUsersRepository{
+		client:      client,
+		ssoConfig:   ssoConfig,
+		cache:       make(map[string]*list.Element),
+		expirations: list.New(),
+	}
+}
+
+func (repo *SSOUsersRepository) GetUserEmail(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Email, nil
+}
+
+func (repo *SSOUsersRepository) GetUserLocale(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Locale, nil
+}
+
+func (repo *SSOUsersRepository) GetUserByGUID(ctx context.Context, guid string) (*entities.User, error) {
+	if user, ok := repo.getCachedUser(guid); ok {
+		return user, nil
+	}
+
+	userID, err := strconv.ParseInt(strings.TrimSpace(guid), 10, 64)
+	if err != nil {
+		return nil, customerrors.UserNotFoundError{}
+	}
+
+	var response *sso.GetUserResponse
+	err = repo.withRetries(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			response, err = repo.client.GetUser(ctx, &sso.GetUserRequest{UserID: userID})
+			return err
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user := mapSSOUser(response)
+	repo.cacheUser(user)
+	return user, nil
+}
+
+func (repo *SSOUsersRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	users, err := repo.getUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			repo.cacheUser(&user)
+			return &user, nil
+		}
+	}
+
+	return nil, customerrors.UserNotFoundError{}
+}
+
+func (repo *SSOUsersRepository) GetUsersByStatus(ctx context.Context, status string) ([]entities.User, error) {
+	if status != entities.UserStatusActive {
+		return nil, nil
+	}
+
+	return repo.getUsers(ctx)
+}
+
+func (repo *SSOUsersRepository) getUsers(ctx context.Context) ([]entities.User, error) {
+	if users, ok := repo.getCachedUsers(); ok {
+		return users, nil
+	}
+
+	var response *sso.GetUsersResponse
+	err := repo.withRetries(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			response, err = repo.client.GetUsers(ctx, &emptypb.Empty{})
+			return err
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]entities.User, 0, len(response.GetUsers()))
+	for _, ssoUser := range response.GetUsers() {
+		users = append(users, *mapSSOUser(ssoUser))
+	}
+
+	repo.cacheUsers(users)
+	return users, nil
+}
+
+// withRetries calls SSO with timeout for every attempt and retries only transient failures, until ctx is done.
+// Request ID of ctx is forwarded in metadata of every call.
+func (repo *SSOUsersRepository) withRetries(ctx context.Context, call func(ctx context.Context) error) error {
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestid.MetadataKey, requestID)
+	}
+
+	attempts := max(repo.ssoConfig.MaxAttempts, 1)
+	backoff := repo.ssoConfig.RetryBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, repo.ssoConfig.Timeout)
+		err = call(attemptCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		switch status.Code(err) {
+		case codes.NotFound:
+			return customerrors.UserNotFoundError{}
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			if attempt == attempts {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+				backoff *= 2
+			}
+		default:
+			return err
+		}
+	}
+
+	// SSO is still rate limiting after all attempts, so that client should retry later:
+	if status.Code(err) == codes.ResourceExhausted {
+		return customerrors.TooManyRequestsError{Err: err}
+	}
+
+	return err
+}
+
+func (repo *SSOUsersRepository) getCachedUser(guid string) (*entities.User, bool) {
+	if repo.ssoConfig.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	element, ok := repo.cache[guid]
+	if !ok {
+		return nil, false
+	}
+
+	cached := element.Value.(cachedUser)
+	if time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+
+	user := cached.user
+	return &user, true
+}
+
+// cacheUser caches user and evicts expired users and, if cache is full, users, which expire first.
+func (repo *SSOUsersRepository) cacheUser(user *entities.User) {
+	if repo.ssoConfig.CacheTTL <= 0 {
+		return
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if element, ok := repo.cache[user.GUID]; ok {
+		repo.expirations.Remove(element)
+	}
+
+	now := time.Now()
+	repo.cache[user.GUID] = repo.expirations.PushBack(
+		cachedUser{
+			user:      *user,
+			expiresAt: now.Add(repo.ssoConfig.CacheTTL),
+		},
+	)
+
+	for element := repo.expirations.Front(); element != nil; element = repo.expirations.Front() {
+		cached := element.Value.(cachedUser)
+		if len(repo.cache) <= repo.ssoConfig.CacheSize && now.Before(cached.expiresAt) {
+			break
+		}
+
+		repo.expirations.Remove(element)
+		delete(repo.cache, cached.user.GUID)
+	}
+}
+
+// getCachedUsers returns copy of cached list of users, so that it is not changed by caller.
+func (repo *SSOUsersRepository) getCachedUsers() ([]entities.User, bool) {
+	if repo.ssoConfig.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if repo.users == nil || time.Now().After(repo.usersExpiresAt) {
+		return nil, false
+	}
+
+	return slices.Clone(repo.users), true
+}
+
+func (repo *SSOUsersRepository) cacheUsers(users []entities.User) {
+	if repo.ssoConfig.CacheTTL <= 0 {
+		return
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.users = slices.Clone(users)
+	repo.usersExpiresAt = time.Now().Add(repo.ssoConfig.CacheTTL)
+}
+
+func mapSSOUser(ssoUser *sso.GetUserResponse) *entities.User {
+	return &entities.User{
+		GUID:      strconv.FormatInt(ssoUser.GetUserID(), 10),
+		Email:     ssoUser.GetEmail(),
+		Status:    entities.UserStatusActive,
+		CreatedAt: ssoUser.GetCreatedAt().AsTime(),
+		UpdatedAt: ssoUser.GetUpdatedAt().AsTime(),
+	}
+}
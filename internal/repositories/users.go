@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/DKhorkov/hmtm-sso/protobuf/generated/go/sso"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NewUsersRepository creates users repository for configured users source. IDs of SSO users are numbers, while
+// PostgreSQL stores GUIDs as UUID, so that SSO can not be used with PostgreSQL backend. In-memory database of
+// memory backend is empty on every start, so that database source can be used with it only, if users are seeded.
+// Returned function releases connections, opened for repository, and should be called on shutdown.
+func NewUsersRepository(
+	usersConfig config.UsersConfig,
+	databasesConfig config.DatabasesConfig,
+	dbConnector interfaces.DBConnector,
+) (interfaces.UsersRepository, func(), error) {
+	if usersConfig.Source != config.SSOUsersSource {
+		if databasesConfig.Backend == config.MemoryDatabaseBackend && databasesConfig.Memory.UsersPath == "" {
+			return nil, nil, customerrors.UnsupportedUsersSourceError{
+				Source:  usersConfig.Source,
+				Backend: databasesConfig.Backend,
+				Message: "users source database can be used with database backend memory only with seeded users, " +
+					"so that MEMORY_USERS_PATH should be set",
+			}
+		}
+
+		return &CommonUsersRepository{DBConnector: dbConnector}, func() {}, nil
+	}
+
+	if databasesConfig.Backend == config.PostgreSQLDatabaseBackend {
+		return nil, nil, customerrors.UnsupportedUsersSourceError{
+			Source:  usersConfig.Source,
+			Backend: databasesConfig.Backend,
+			Message: "users source sso can not be used with database backend postgres, which stores GUIDs as UUID",
+		}
+	}
+
+	ssoConnection, err := grpc.NewClient(
+		fmt.Sprintf("%s:%d", usersConfig.SSO.Host, usersConfig.SSO.Port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closeConnection := func() {
+		_ = ssoConnection.Close()
+	}
+
+	return NewSSOUsersRepository(sso.NewUsersServiceClient(ssoConnection), usersConfig.SSO), closeConnection, nil
+}
+
+// SeedUsers inserts users from JSON file at path, which contains array of users, into database and returns number
+// of inserted users. Users without status are active and users without locale get defaultLocale. Users, which
+// already exist, for example, restored from snapshot, are kept as they are.
+func SeedUsers(
+	ctx context.Context,
+	dbConnector interfaces.DBConnector,
+	path string,
+	defaultLocale string,
+) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var users []entities.User
+	if err = json.Unmarshal(data, &users); err != nil {
+		return 0, err
+	}
+
+	usersRepository := &CommonUsersRepository{DBConnector: dbConnector}
+	unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+	var seeded int
+	err = unitOfWork.Do(
+		ctx,
+		func(ctx context.Context) error {
+			for _, user := range users {
+				_, err := usersRepository.GetUserByGUID(ctx, user.GUID)
+				if err == nil {
+					continue
+				}
+
+				var userNotFoundError customerrors.UserNotFoundError
+				if !errors.As(err, &userNotFoundError) {
+					return err
+				}
+
+				if user.Status == "" {
+					user.Status = entities.UserStatusActive
+				}
+
+				if user.Locale == "" {
+					user.Locale = defaultLocale
+				}
+
+				if err = usersRepository.CreateUser(ctx, user); err != nil {
+					return err
+				}
+
+				seeded++
+			}
+
+			return nil
+		},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return seeded, nil
+}
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+type CommonUsersRepository struct {
+	DBConnector interfaces.DBConnector
+}
+
+// CreateUser inserts user, which is used to seed users of in-memory database.
+func (repo *CommonUsersRepository) CreateUser(ctx context.Context, user entities.User) error {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	_, err := executor.ExecContext(
+		ctx,
+		`
+			INSERT INTO users (guid, email, status, locale, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+		`,
+		user.GUID,
+		user.Email,
+		user.Status,
+		user.Locale,
+		time.Now().UTC(),
+	)
+
+	return err
+}
+
+func (repo *CommonUsersRepository) GetUserEmail(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Email, nil
+}
+
+func (repo *CommonUsersRepository) GetUserLocale(ctx context.Context, guid string) (string, error) {
+	user, err := repo.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Locale, nil
+}
+
+func (repo *CommonUsersRepository) GetUserByGUID(ctx context.Context, guid string) (*entities.User, error) {
+	user := &entities.User{}
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	err := executor.QueryRowContext(
+		ctx,
+		`
+			SELECT u.guid,
+			       u.email,
+			       u.status,
+			       u.locale,
+			       u.created_at,
+			       u.updated_at
+			FROM users AS u
+			WHERE u.guid = $1
+		`,
+		guid,
+	).Scan(
+		&user.GUID,
+		&user.Email,
+		&user.Status,
+		&user.Locale,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, customerrors.UserNotFoundError{}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (repo *CommonUsersRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	user := &entities.User{}
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	err := executor.QueryRowContext(
+		ctx,
+		`
+			SELECT u.guid,
+			       u.email,
+			       u.status,
+			       u.locale,
+			       u.created_at,
+			       u.updated_at
+			FROM users AS u
+			WHERE u.email = $1
+		`,
+		email,
+	).Scan(
+		&user.GUID,
+		&user.Email,
+		&user.Status,
+		&user.Locale,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, customerrors.UserNotFoundError{}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (repo *CommonUsersRepository) GetUsersByStatus(ctx context.Context, status string) ([]entities.User, error) {
+	executor := database.GetExecutor(ctx, repo.DBConnector)
+	rows, err := executor.QueryContext(
+		ctx,
+		`
+			SELECT u.guid,
+			       u.email,
+			       u.status,
+			       u.locale,
+			       u.created_at,
+			       u.updated_at
+			FROM users AS u
+			WHERE u.status = $1
+			ORDER BY u.created_at
+		`,
+		status,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var users []entities.User
+	for rows.Next() {
+		var user entities.User
+		err = rows.Scan(
+			&user.GUID,
+			&user.Email,
+			&user.Status,
+			&user.Locale,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
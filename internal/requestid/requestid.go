@@ -0,0 +1,36 @@
+package requestid
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Header carries request ID in HTTP requests and responses, including calls to other services.
+const Header = "X-Request-ID"
+
+// MetadataKey carries request ID in gRPC calls to other services.
+const MetadataKey = "x-request-id"
+
+type contextKey struct{}
+
+// NewContext returns copy of ctx, which carries request ID.
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// FromContext returns ID of request, which is handled with ctx, or empty string, if there is no one.
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// Logger returns logger, which adds ID of request, handled with ctx, to every record. If there is no request ID,
+// logger is returned as is.
+func Logger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	requestID := FromContext(ctx)
+	if requestID == "" || logger == nil {
+		return logger
+	}
+
+	return logger.With("RequestID", requestID)
+}
@@ -0,0 +1,24 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// SignPayload returns hex encoded HMAC-SHA256 of "<timestamp>.<payload>". Timestamp is signed together with
+// payload to prevent replay of old signed payloads.
+func SignPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidatePayloadSignature compares signature with expected one in constant time.
+func ValidatePayloadSignature(secret string, timestamp int64, payload []byte, signature string) bool {
+	expected := SignPayload(secret, timestamp, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -14,6 +14,11 @@ type JWTData struct {
 	SecretKey string
 	Algorithm string
 	TTL       time.Duration
+
+	// IssuedAt and ExpiresAt are filled only by ParseJWT. Tokens, issued before introduction of IssuedAt,
+	// have zero IssuedAt.
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
 func GenerateJWT(data JWTData) (string, error) {
@@ -26,6 +31,7 @@ func GenerateJWT(data JWTData) (string, error) {
 	claims["GUID"] = data.GUID
 	claims["IP"] = data.IP
 	claims["Value"] = data.Value
+	claims["iat"] = time.Now().Unix()
 	claims["exp"] = time.Now().Add(data.TTL).Unix()
 	return token.SignedString([]byte(data.SecretKey))
 }
@@ -50,5 +56,13 @@ func ParseJWT(tokenString, secretKey string) (*JWTData, error) {
 		Value: claims["Value"].(string),
 	}
 
+	if issuedAt, ok := claims["iat"].(float64); ok {
+		data.IssuedAt = time.Unix(int64(issuedAt), 0)
+	}
+
+	if expiresAt, ok := claims["exp"].(float64); ok {
+		data.ExpiresAt = time.Unix(int64(expiresAt), 0)
+	}
+
 	return data, nil
 }
@@ -0,0 +1,15 @@
+package security
+
+import (
+	"crypto/rand"
+)
+
+// GenerateSecretKey generates random key of provided size in bytes, suitable for signing JWT and webhooks.
+func GenerateSecretKey(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return Encode(bytes), nil
+}
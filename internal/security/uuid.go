@@ -0,0 +1,26 @@
+package security
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// GenerateUUID generates random (version 4) UUID according to RFC 4122.
+func GenerateUUID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	bytes[6] = (bytes[6] & 0x0f) | 0x40 // Version 4
+	bytes[8] = (bytes[8] & 0x3f) | 0x80 // Variant is 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), nil
+}
+
+// IsUUID checks, whether value is UUID in canonical "8-4-4-4-12" hexadecimal representation of any version.
+func IsUUID(value string) bool {
+	return uuidPattern.MatchString(value)
+}
@@ -1,26 +1,286 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
 	"github.com/DKhorkov/medods/internal/interfaces"
 )
 
 type CommonAuthService struct {
 	AuthRepository interfaces.AuthRepository
+
+	// UnitOfWork is optional. If it is not provided, for example, for in-memory repositories, operations, which
+	// consist of several repository calls, are not atomic.
+	UnitOfWork interfaces.UnitOfWork
+}
+
+// CreateRefreshToken rotates refresh token of user: previous refresh token is deleted and new one is created
+// atomically, so that user has only one active refresh token. On login all undeleted refresh tokens of user are
+// deleted, including expired one, which has not been purged yet. On refresh previous refresh token is the rotated
+// one, which is checked on primary database, because it could have been read from stale replica. If concurrent
+// request has already deleted it or has created refresh token of user first, nothing is created and
+// RefreshTokenRotatedError is returned.
+func (service *CommonAuthService) CreateRefreshToken(
+	ctx context.Context,
+	data entities.CreateRefreshTokenDTO,
+) (int, error) {
+	var refreshTokenID int
+	err := service.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			if data.RotatedRefreshTokenID == 0 {
+				_, err = service.AuthRepository.DeleteRefreshTokensByGUID(ctx, data.GUID)
+			} else {
+				err = service.deleteRotatedRefreshToken(ctx, data.RotatedRefreshTokenID)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			refreshTokenID, err = service.AuthRepository.CreateRefreshToken(ctx, data)
+			return err
+		},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return refreshTokenID, nil
+}
+
+func (service *CommonAuthService) GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	return service.AuthRepository.GetRefreshTokenByID(ctx, id)
+}
+
+// GetRotatedRefreshToken returns refresh token with provided ID, if it has been rotated on refresh and its session
+// is still active, so that presenting it again means reuse. Refresh tokens, which have expired, have been revoked
+// or have been replaced by login on another device, have no active successor in their session, so that
+// RefreshTokenNotFoundError is returned for them. Both refresh tokens are read on primary database.
+func (service *CommonAuthService) GetRotatedRefreshToken(
+	ctx context.Context,
+	id int,
+) (*entities.RefreshToken, error) {
+	var rotatedRefreshToken *entities.RefreshToken
+	err := service.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			refreshToken, err := service.AuthRepository.GetRefreshTokenByIDIncludingDeleted(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if refreshToken.DeletedAt == nil || !refreshToken.TTL.After(time.Now()) {
+				return customerrors.RefreshTokenNotFoundError{}
+			}
+
+			if _, err = service.getSessionRefreshToken(ctx, refreshToken); err != nil {
+				return err
+			}
+
+			rotatedRefreshToken = refreshToken
+			return nil
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rotatedRefreshToken, nil
+}
+
+// RevokeRefreshToken deletes active refresh token of user, which ends user session.
+func (service *CommonAuthService) RevokeRefreshToken(ctx context.Context, guid string) error {
+	refreshToken, err := service.AuthRepository.GetRefreshTokenByGUID(ctx, guid)
+	if err != nil {
+		return err
+	}
+
+	return service.AuthRepository.DeleteRefreshToken(ctx, refreshToken)
 }
 
-func (service *CommonAuthService) CreateRefreshToken(guid, value string, ttl time.Time) (int, error) {
-	if oldRefreshToken, err := service.AuthRepository.GetRefreshTokenByGUID(guid); err == nil {
-		if err = service.AuthRepository.DeleteRefreshToken(oldRefreshToken); err != nil {
-			return 0, err
+// RevokeSessionOfRefreshToken deletes active refresh token of session, to which refresh token with provided ID
+// belongs, even if the latter has been rotated since. If session has already ended or refresh token belongs to
+// another user, RefreshTokenNotFoundError is returned.
+func (service *CommonAuthService) RevokeSessionOfRefreshToken(ctx context.Context, guid string, id int) error {
+	return service.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			refreshToken, err := service.AuthRepository.GetRefreshTokenByIDIncludingDeleted(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if refreshToken.GUID != guid {
+				return customerrors.RefreshTokenNotFoundError{}
+			}
+
+			activeRefreshToken, err := service.getSessionRefreshToken(ctx, refreshToken)
+			if err != nil {
+				return err
+			}
+
+			return service.AuthRepository.DeleteRefreshToken(ctx, activeRefreshToken)
+		},
+	)
+}
+
+// IsSessionActive checks, whether session, to which refresh token with provided ID belongs, has not ended yet,
+// even if the refresh token has been rotated since. Refresh token of another user does not belong to its session.
+func (service *CommonAuthService) IsSessionActive(ctx context.Context, guid string, id int) (bool, error) {
+	refreshToken, err := service.AuthRepository.GetRefreshTokenByIDIncludingDeleted(ctx, id)
+	if err == nil {
+		if refreshToken.GUID != guid {
+			return false, nil
 		}
+
+		_, err = service.getSessionRefreshToken(ctx, refreshToken)
+	}
+
+	var refreshTokenNotFoundError customerrors.RefreshTokenNotFoundError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &refreshTokenNotFoundError):
+		return false, nil
+	default:
+		return false, err
 	}
+}
+
+func (service *CommonAuthService) GetRefreshTokensByGUID(
+	ctx context.Context,
+	guid string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	return service.AuthRepository.GetRefreshTokensByGUID(ctx, guid, pagination)
+}
 
-	return service.AuthRepository.CreateRefreshToken(guid, value, ttl)
+func (service *CommonAuthService) GetRefreshTokensByIP(
+	ctx context.Context,
+	ip string,
+	pagination entities.Pagination,
+) ([]entities.RefreshToken, error) {
+	return service.AuthRepository.GetRefreshTokensByIP(ctx, ip, pagination)
 }
 
-func (service *CommonAuthService) GetRefreshTokenByID(id int) (*entities.RefreshToken, error) {
-	return service.AuthRepository.GetRefreshTokenByID(id)
+// RevokeRefreshTokenByID deletes active refresh token with provided ID.
+func (service *CommonAuthService) RevokeRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	refreshToken, err := service.AuthRepository.GetRefreshTokenByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshToken, service.AuthRepository.DeleteRefreshToken(ctx, refreshToken)
+}
+
+// RevokeAllRefreshTokens deletes all active refresh tokens of user and returns their number.
+func (service *CommonAuthService) RevokeAllRefreshTokens(ctx context.Context, guid string) (int, error) {
+	return service.AuthRepository.DeleteRefreshTokensByGUID(ctx, guid)
+}
+
+// RevokeDisabledUsersRefreshTokens deletes active refresh tokens of blocked and deleted users and returns GUIDs of
+// users, which refresh tokens have been deleted.
+func (service *CommonAuthService) RevokeDisabledUsersRefreshTokens(ctx context.Context) ([]string, error) {
+	return service.AuthRepository.DeleteRefreshTokensOfDisabledUsers(ctx)
+}
+
+// RevokeTokenFamily atomically deletes all refresh tokens of user and denies access tokens of user, issued
+// before deniedBefore, until expiresAt. Returns number of revoked refresh tokens.
+func (service *CommonAuthService) RevokeTokenFamily(
+	ctx context.Context,
+	guid string,
+	deniedBefore, expiresAt time.Time,
+) (int, error) {
+	var revoked int
+	err := service.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			if revoked, err = service.AuthRepository.DeleteRefreshTokensByGUID(ctx, guid); err != nil {
+				return err
+			}
+
+			return service.AuthRepository.DenyAccessTokens(ctx, guid, deniedBefore, expiresAt)
+		},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+func (service *CommonAuthService) DenyAccessTokens(
+	ctx context.Context,
+	guid string,
+	deniedBefore, expiresAt time.Time,
+) error {
+	return service.AuthRepository.DenyAccessTokens(ctx, guid, deniedBefore, expiresAt)
+}
+
+// IsAccessTokenDenied checks, whether access token of user, issued at issuedAt, was force-expired. Issue time of
+// access token has second precision, so that access tokens, issued within the second of denial, are not denied.
+// Otherwise tokens, issued right after denial, for example, on the next login, would be rejected.
+func (service *CommonAuthService) IsAccessTokenDenied(
+	ctx context.Context,
+	guid string,
+	issuedAt time.Time,
+) (bool, error) {
+	deniedBefore, err := service.AuthRepository.GetAccessTokensDeniedBefore(ctx, guid)
+	if err != nil {
+		return false, err
+	}
+
+	return !deniedBefore.IsZero() && issuedAt.Before(deniedBefore.Truncate(time.Second)), nil
+}
+
+// deleteRotatedRefreshToken deletes refresh token, which is replaced on refresh. If concurrent rotation has
+// already deleted it, either before it is read or before it is deleted, RefreshTokenRotatedError is returned.
+func (service *CommonAuthService) deleteRotatedRefreshToken(ctx context.Context, id int) error {
+	rotatedRefreshToken, err := service.AuthRepository.GetRefreshTokenByID(ctx, id)
+	if err == nil {
+		err = service.AuthRepository.DeleteRefreshToken(ctx, rotatedRefreshToken)
+	}
+
+	var refreshTokenNotFoundError customerrors.RefreshTokenNotFoundError
+	if errors.As(err, &refreshTokenNotFoundError) {
+		return customerrors.RefreshTokenRotatedError{}
+	}
+
+	return err
+}
+
+// getSessionRefreshToken returns active refresh token of session, to which provided refresh token belongs. Only
+// rotation creates refresh token, which inherits start of the session, while login starts new session.
+func (service *CommonAuthService) getSessionRefreshToken(
+	ctx context.Context,
+	refreshToken *entities.RefreshToken,
+) (*entities.RefreshToken, error) {
+	activeRefreshToken, err := service.AuthRepository.GetRefreshTokenByGUID(ctx, refreshToken.GUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !activeRefreshToken.SessionStartedAt.Equal(refreshToken.SessionStartedAt) {
+		return nil, customerrors.RefreshTokenNotFoundError{}
+	}
+
+	return activeRefreshToken, nil
+}
+
+func (service *CommonAuthService) atomically(ctx context.Context, work func(ctx context.Context) error) error {
+	if service.UnitOfWork == nil {
+		return work(ctx)
+	}
+
+	return service.UnitOfWork.Do(ctx, work)
 }
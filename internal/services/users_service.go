@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+
+	"github.com/DKhorkov/medods/internal/entities"
 	"github.com/DKhorkov/medods/internal/interfaces"
 )
 
@@ -8,6 +11,23 @@ type CommonUsersService struct {
 	UsersRepository interfaces.UsersRepository
 }
 
-func (service *CommonUsersService) GetUserEmail(guid string) (string, error) {
-	return service.UsersRepository.GetUserEmail(guid)
+func (service *CommonUsersService) GetUserEmail(ctx context.Context, guid string) (string, error) {
+	return service.UsersRepository.GetUserEmail(ctx, guid)
+}
+
+func (service *CommonUsersService) GetUserLocale(ctx context.Context, guid string) (string, error) {
+	return service.UsersRepository.GetUserLocale(ctx, guid)
+}
+
+func (service *CommonUsersService) GetUserStatus(ctx context.Context, guid string) (string, error) {
+	user, err := service.UsersRepository.GetUserByGUID(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Status, nil
+}
+
+func (service *CommonUsersService) GetUsersByStatus(ctx context.Context, status string) ([]entities.User, error) {
+	return service.UsersRepository.GetUsersByStatus(ctx, status)
 }
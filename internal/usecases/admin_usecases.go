@@ -0,0 +1,128 @@
+package usecases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// CommonAdminUseCases allows support team to manage user sessions.
+type CommonAdminUseCases struct {
+	AuthService interfaces.AuthService
+	JWTConfig   config.JWTConfig
+
+	// EventPublisher is optional. If it is not provided, security events are not published.
+	EventPublisher interfaces.EventPublisher
+	Logger         *slog.Logger
+}
+
+func (useCases *CommonAdminUseCases) GetUserSessions(
+	ctx context.Context,
+	guid string,
+	pagination entities.Pagination,
+) (*entities.SessionsPage, error) {
+	// One more refresh token is requested to find out, whether there is next page:
+	refreshTokens, err := useCases.AuthService.GetRefreshTokensByGUID(
+		ctx,
+		guid,
+		entities.Pagination{Limit: pagination.Limit + 1, Offset: pagination.Offset},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newSessionsPage(refreshTokens, pagination), nil
+}
+
+func (useCases *CommonAdminUseCases) SearchSessionsByIP(
+	ctx context.Context,
+	ip string,
+	pagination entities.Pagination,
+) (*entities.SessionsPage, error) {
+	refreshTokens, err := useCases.AuthService.GetRefreshTokensByIP(
+		ctx,
+		ip,
+		entities.Pagination{Limit: pagination.Limit + 1, Offset: pagination.Offset},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newSessionsPage(refreshTokens, pagination), nil
+}
+
+func (useCases *CommonAdminUseCases) RevokeSession(ctx context.Context, id int) error {
+	refreshToken, err := useCases.AuthService.RevokeRefreshTokenByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	publishSecurityEvent(
+		ctx,
+		useCases.EventPublisher,
+		useCases.Logger,
+		entities.SessionRevokedEvent,
+		refreshToken.GUID,
+		refreshToken.IP,
+		"",
+	)
+
+	return nil
+}
+
+func (useCases *CommonAdminUseCases) RevokeAllUserSessions(ctx context.Context, guid string) (int, error) {
+	revoked, err := useCases.AuthService.RevokeAllRefreshTokens(ctx, guid)
+	if err != nil {
+		return 0, err
+	}
+
+	if revoked > 0 {
+		publishSecurityEvent(ctx, useCases.EventPublisher, useCases.Logger, entities.SessionRevokedEvent, guid, "", "")
+	}
+
+	return revoked, nil
+}
+
+// ExpireUserAccessTokens denies all access tokens of user, issued until now. Denial is kept until the last of
+// them expires. Sessions are kept, so user is able to get new access tokens by refreshing.
+func (useCases *CommonAdminUseCases) ExpireUserAccessTokens(ctx context.Context, guid string) error {
+	now := time.Now()
+	return useCases.AuthService.DenyAccessTokens(ctx, guid, now, now.Add(useCases.JWTConfig.AccessTokenTTL))
+}
+
+func newSessionsPage(refreshTokens []entities.RefreshToken, pagination entities.Pagination) *entities.SessionsPage {
+	page := &entities.SessionsPage{
+		Sessions: make([]entities.Session, 0, len(refreshTokens)),
+		Limit:    pagination.Limit,
+		Offset:   pagination.Offset,
+		HasMore:  len(refreshTokens) > pagination.Limit,
+	}
+
+	if page.HasMore {
+		refreshTokens = refreshTokens[:pagination.Limit]
+	}
+
+	now := time.Now()
+	for _, refreshToken := range refreshTokens {
+		session := entities.Session{
+			ID:              refreshToken.ID,
+			GUID:            refreshToken.GUID,
+			IP:              refreshToken.IP,
+			StartedAt:       refreshToken.SessionStartedAt,
+			LastRefreshedAt: refreshToken.CreatedAt,
+			ExpiresAt:       refreshToken.TTL,
+			RevokedAt:       refreshToken.DeletedAt,
+			Active:          refreshToken.DeletedAt == nil && refreshToken.TTL.After(now),
+		}
+
+		page.Sessions = append(page.Sessions, session)
+	}
+
+	return page
+}
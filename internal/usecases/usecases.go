@@ -1,30 +1,68 @@
 package usecases
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
 
 	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/emails"
 	"github.com/DKhorkov/medods/internal/entities"
 	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/requestid"
 	"github.com/DKhorkov/medods/internal/security"
 )
 
+// revokeTokenValuePrefix is followed in revoke token by ID of refresh token, session of which is revoked.
+const revokeTokenValuePrefix = "revoke:"
+
 type CommonUseCases struct {
-	AuthService  interfaces.AuthService
-	UsersService interfaces.UsersService
-	HashCost     int
-	JWTConfig    config.JWTConfig
-	SMTPConfig   config.SMTPConfig
-	Logger       *slog.Logger
+	AuthService   interfaces.AuthService
+	UsersService  interfaces.UsersService
+	HashCost      int
+	JWTConfig     config.JWTConfig
+	SessionConfig config.SessionConfig
+	Notifier      interfaces.Notifier
+	EmailRenderer interfaces.EmailRenderer
+	EmailsConfig  config.EmailsConfig
+
+	// EventPublisher is optional. If it is not provided, security events are not published.
+	EventPublisher interfaces.EventPublisher
+
+	// UnitOfWork is optional. If it is provided, notifications are written to outbox in the same transaction as
+	// changes of sessions, which they are about, so that neither of them is lost without the other. Sessions of
+	// disabled users are revoked in the same transaction as denial of their access tokens.
+	UnitOfWork interfaces.UnitOfWork
+	Logger     *slog.Logger
 }
 
-func (useCases *CommonUseCases) CreateTokens(data entities.CreateTokensDTO) (*entities.Tokens, error) {
+func (useCases *CommonUseCases) CreateTokens(
+	ctx context.Context,
+	data entities.CreateTokensDTO,
+) (*entities.Tokens, error) {
+	if err := useCases.checkUserStatus(ctx, data.GUID, data.IP, ""); err != nil {
+		return nil, err
+	}
+
+	return useCases.createTokens(ctx, data, time.Now(), 0)
+}
+
+// createTokens issues new pair of tokens, which belongs to session, started at sessionStartedAt. On refresh
+// rotatedRefreshTokenID is ID of refresh token, which is replaced, and zero otherwise.
+func (useCases *CommonUseCases) createTokens(
+	ctx context.Context,
+	data entities.CreateTokensDTO,
+	sessionStartedAt time.Time,
+	rotatedRefreshTokenID int,
+) (*entities.Tokens, error) {
 	randomSeedLength := 10
 	refreshTokenValue := fmt.Sprintf(
 		"%s:%s",
@@ -38,9 +76,15 @@ func (useCases *CommonUseCases) CreateTokens(data entities.CreateTokensDTO) (*en
 	}
 
 	refreshTokenID, err := useCases.AuthService.CreateRefreshToken(
-		data.GUID,
-		hashedRefreshTokenValue,
-		time.Now().Add(useCases.JWTConfig.RefreshTokenTTL),
+		ctx,
+		entities.CreateRefreshTokenDTO{
+			GUID:                  data.GUID,
+			IP:                    data.IP,
+			Value:                 hashedRefreshTokenValue,
+			TTL:                   time.Now().Add(useCases.JWTConfig.RefreshTokenTTL),
+			SessionStartedAt:      sessionStartedAt,
+			RotatedRefreshTokenID: rotatedRefreshTokenID,
+		},
 	)
 
 	if err != nil {
@@ -77,13 +121,17 @@ func (useCases *CommonUseCases) CreateTokens(data entities.CreateTokensDTO) (*en
 		return nil, err
 	}
 
+	useCases.publishEvent(ctx, entities.TokenIssuedEvent, data.GUID, data.IP, "")
 	return &entities.Tokens{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
 
-func (useCases *CommonUseCases) RefreshTokens(data entities.RefreshTokensDTO) (*entities.Tokens, error) {
+func (useCases *CommonUseCases) RefreshTokens(
+	ctx context.Context,
+	data entities.RefreshTokensDTO,
+) (*entities.Tokens, error) {
 	accessTokenPayload, err := security.ParseJWT(data.Tokens.AccessToken, useCases.JWTConfig.SecretKey)
 	if err != nil {
 		return nil, err
@@ -94,38 +142,34 @@ func (useCases *CommonUseCases) RefreshTokens(data entities.RefreshTokensDTO) (*
 		return nil, err
 	}
 
-	if accessTokenPayload.IP != data.IP || refreshTokenPayload.IP != data.IP {
-		go func() {
-			email, err := useCases.UsersService.GetUserEmail(refreshTokenPayload.GUID)
-			if err != nil {
-				useCases.Logger.Error(
-					"Failed to get user email",
-					"Traceback",
-					logging.GetLogTraceback(),
-					"Error",
-					err,
-				)
-			}
-
-			sendEmail(
-				"MEDODS warning\n",
-				fmt.Sprintf("Someone tried to refresh your tokens from next IP - %s", data.IP),
-				[]string{email},
-				useCases.SMTPConfig,
-				useCases.Logger,
-			)
-		}()
+	refreshTokenID, err := strconv.Atoi(accessTokenPayload.Value)
+	if err != nil {
+		return nil, customerrors.InvalidJWTError{}
+	}
 
+	if accessTokenPayload.IP != data.IP || refreshTokenPayload.IP != data.IP {
+		useCases.notifyAboutSuspiciousIP(ctx, refreshTokenPayload.GUID, refreshTokenID, data)
+		useCases.publishEvent(ctx, entities.RefreshFromNewIPEvent, refreshTokenPayload.GUID, data.IP, data.UserAgent)
 		return nil, customerrors.IPAddressDoesNotMatchWithTokensIPError{}
 	}
 
-	refreshTokenID, err := strconv.Atoi(accessTokenPayload.Value)
+	// Force-expired access token can not be exchanged for new pair of tokens:
+	denied, err := useCases.AuthService.IsAccessTokenDenied(ctx, accessTokenPayload.GUID, accessTokenPayload.IssuedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	dbRefreshToken, err := useCases.AuthService.GetRefreshTokenByID(refreshTokenID)
+	if denied {
+		return nil, customerrors.AccessTokenRevokedError{}
+	}
+
+	dbRefreshToken, err := useCases.AuthService.GetRefreshTokenByID(ctx, refreshTokenID)
 	if err != nil {
+		var refreshTokenNotFoundError customerrors.RefreshTokenNotFoundError
+		if errors.As(err, &refreshTokenNotFoundError) {
+			return nil, useCases.checkRefreshTokenReuse(ctx, refreshTokenID, refreshTokenPayload.Value, data)
+		}
+
 		return nil, err
 	}
 
@@ -133,10 +177,414 @@ func (useCases *CommonUseCases) RefreshTokens(data entities.RefreshTokensDTO) (*
 		return nil, customerrors.AccessTokenDoesNotBelongToRefreshTokenError{}
 	}
 
-	return useCases.CreateTokens(
+	if err = useCases.validateSession(dbRefreshToken); err != nil {
+		return nil, err
+	}
+
+	if err = useCases.checkUserStatus(ctx, dbRefreshToken.GUID, data.IP, data.UserAgent); err != nil {
+		return nil, err
+	}
+
+	return useCases.createTokens(
+		ctx,
 		entities.CreateTokensDTO{
 			GUID: dbRefreshToken.GUID,
 			IP:   data.IP,
 		},
+		dbRefreshToken.SessionStartedAt,
+		dbRefreshToken.ID,
+	)
+}
+
+// validateSession checks, that session, to which refresh token belongs, has not reached its maximum lifetime
+// and has not been idle for too long. Each refresh issues new refresh token, so its creation time is the
+// time of the last session activity.
+func (useCases *CommonUseCases) validateSession(refreshToken *entities.RefreshToken) error {
+	now := time.Now()
+	if useCases.SessionConfig.MaxAge > 0 &&
+		now.After(refreshToken.SessionStartedAt.Add(useCases.SessionConfig.MaxAge)) {
+		return customerrors.SessionExpiredError{}
+	}
+
+	if useCases.SessionConfig.IdleTimeout > 0 &&
+		now.After(refreshToken.CreatedAt.Add(useCases.SessionConfig.IdleTimeout)) {
+		return customerrors.SessionIdleTimeoutError{}
+	}
+
+	return nil
+}
+
+// ValidateAccessToken checks access token for services, which trust tokens of this one, and returns GUID of
+// its owner. Access tokens, force-expired by administrator, are rejected.
+func (useCases *CommonUseCases) ValidateAccessToken(ctx context.Context, accessToken string) (string, error) {
+	accessTokenPayload, err := security.ParseJWT(accessToken, useCases.JWTConfig.SecretKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Refresh and revoke tokens are signed with the same key, but only access tokens store refresh token ID:
+	if _, err = strconv.Atoi(accessTokenPayload.Value); err != nil {
+		return "", customerrors.InvalidJWTError{}
+	}
+
+	denied, err := useCases.AuthService.IsAccessTokenDenied(ctx, accessTokenPayload.GUID, accessTokenPayload.IssuedAt)
+	if err != nil {
+		return "", err
+	}
+
+	if denied {
+		return "", customerrors.AccessTokenRevokedError{}
+	}
+
+	return accessTokenPayload.GUID, nil
+}
+
+// checkUserStatus allows tokens only for existing active users. Sessions of disabled users are revoked and their
+// access tokens are denied, unless it has been already done by RevokeDisabledUsersSessions, while sessions of
+// locked users are kept until the lock is lifted.
+func (useCases *CommonUseCases) checkUserStatus(ctx context.Context, guid, ip, userAgent string) error {
+	status, err := useCases.UsersService.GetUserStatus(ctx, guid)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case entities.UserStatusActive:
+		return nil
+	case entities.UserStatusLocked:
+		return customerrors.UserLockedError{}
+	}
+
+	revoked, err := useCases.revokeUserTokens(ctx, guid)
+	switch {
+	case err != nil:
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to revoke session of disabled user",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+	case revoked > 0:
+		useCases.publishEvent(ctx, entities.SessionRevokedEvent, guid, ip, userAgent)
+	}
+
+	return customerrors.UserDisabledError{Status: status}
+}
+
+// RevokeDisabledUsersSessions ends sessions of disabled users and denies their access tokens. Status of users is
+// changed outside of the service, so that it is called periodically. Sessions are revoked by one set-based
+// statement, which touches only disabled users with active sessions, and number of such users is returned.
+func (useCases *CommonUseCases) RevokeDisabledUsersSessions(ctx context.Context) (int, error) {
+	var guids []string
+	err := useCases.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			if guids, err = useCases.AuthService.RevokeDisabledUsersRefreshTokens(ctx); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			for _, guid := range guids {
+				err = useCases.AuthService.DenyAccessTokens(ctx, guid, now, now.Add(useCases.JWTConfig.AccessTokenTTL))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, guid := range guids {
+		useCases.publishEvent(ctx, entities.SessionRevokedEvent, guid, "", "")
+	}
+
+	return len(guids), nil
+}
+
+// revokeUserTokens ends all sessions of user and, if there were any, denies access tokens of user, issued within
+// them. Returns number of revoked sessions.
+func (useCases *CommonUseCases) revokeUserTokens(ctx context.Context, guid string) (int, error) {
+	var revoked int
+	err := useCases.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			if revoked, err = useCases.AuthService.RevokeAllRefreshTokens(ctx, guid); err != nil || revoked == 0 {
+				return err
+			}
+
+			now := time.Now()
+			return useCases.AuthService.DenyAccessTokens(ctx, guid, now, now.Add(useCases.JWTConfig.AccessTokenTTL))
+		},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+// RevokeTokens ends user session by revoke token, which is sent to user in security emails. Revoke token is bound
+// to refresh token, which was used from unknown IP address, so that only its session is ended, even if it has
+// been refreshed since, and not the session, which user may have started later.
+func (useCases *CommonUseCases) RevokeTokens(ctx context.Context, revokeToken string) error {
+	revokeTokenPayload, err := security.ParseJWT(revokeToken, useCases.JWTConfig.SecretKey)
+	if err != nil {
+		return err
+	}
+
+	// Access and refresh tokens are signed with the same key, so they should not be accepted as revoke token:
+	value, found := strings.CutPrefix(revokeTokenPayload.Value, revokeTokenValuePrefix)
+	refreshTokenID, err := strconv.Atoi(value)
+	if !found || err != nil {
+		return customerrors.InvalidJWTError{}
+	}
+
+	err = useCases.AuthService.RevokeSessionOfRefreshToken(ctx, revokeTokenPayload.GUID, refreshTokenID)
+	if err != nil {
+		return err
+	}
+
+	useCases.publishEvent(ctx, entities.SessionRevokedEvent, revokeTokenPayload.GUID, revokeTokenPayload.IP, "")
+	return nil
+}
+
+// checkRefreshTokenReuse is called, when tokens refer to refresh token, which is not active, and returns error for
+// the refresh. Presenting refresh token, which has already been rotated, while its session is still active, is
+// reuse, unless it happens within grace period after rotation, which covers retries and concurrent refreshes of
+// the client. Expired refresh tokens and refresh tokens, which have been revoked or replaced by login on another
+// device, are just invalid.
+func (useCases *CommonUseCases) checkRefreshTokenReuse(
+	ctx context.Context,
+	refreshTokenID int,
+	refreshTokenValue string,
+	data entities.RefreshTokensDTO,
+) error {
+	rotatedRefreshToken, err := useCases.AuthService.GetRotatedRefreshToken(ctx, refreshTokenID)
+	if err != nil {
+		return err
+	}
+
+	if !security.ValidateRefreshToken(refreshTokenValue, rotatedRefreshToken.Value) {
+		return customerrors.AccessTokenDoesNotBelongToRefreshTokenError{}
+	}
+
+	if time.Since(*rotatedRefreshToken.DeletedAt) < useCases.SessionConfig.ReuseGracePeriod {
+		return customerrors.RefreshTokenRotatedError{}
+	}
+
+	useCases.handleRefreshTokenReuse(ctx, rotatedRefreshToken.GUID, data)
+	return customerrors.RefreshTokenNotFoundError{}
+}
+
+// handleRefreshTokenReuse is called, when validly signed tokens refer to refresh token, which was already
+// rotated. Such tokens could be used only by someone, who has stolen them, or by the legitimate user after theft,
+// so the whole token family of user is revoked: all refresh tokens and issued access tokens. User is notified
+// about ended sessions in the same transaction.
+func (useCases *CommonUseCases) handleRefreshTokenReuse(
+	ctx context.Context,
+	guid string,
+	data entities.RefreshTokensDTO,
+) {
+	requestid.Logger(ctx, useCases.Logger).Warn("Refresh token reuse detected", "GUID", guid, "IP", data.IP)
+	useCases.publishEvent(ctx, entities.RefreshTokenReusedEvent, guid, data.IP, data.UserAgent)
+
+	// Token family is revoked, even if user can not be notified:
+	notification := useCases.prepareNotification(
+		ctx,
+		guid,
+		emails.SessionsRevokedTemplate,
+		entities.SessionsRevokedEmailData{
+			Time:     time.Now(),
+			IP:       data.IP,
+			Location: data.Location,
+			Device:   data.UserAgent,
+		},
+	)
+
+	var revoked int
+	err := useCases.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			var err error
+			now := time.Now()
+			revoked, err = useCases.AuthService.RevokeTokenFamily(
+				ctx,
+				guid,
+				now,
+				now.Add(useCases.JWTConfig.AccessTokenTTL),
+			)
+
+			if err != nil || revoked == 0 || notification == nil {
+				return err
+			}
+
+			return useCases.Notifier.Notify(ctx, *notification)
+		},
+	)
+
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to revoke token family after refresh token reuse",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return
+	}
+
+	if revoked > 0 {
+		useCases.publishEvent(ctx, entities.SessionRevokedEvent, guid, data.IP, data.UserAgent)
+	}
+}
+
+func (useCases *CommonUseCases) publishEvent(ctx context.Context, eventType, guid, ip, userAgent string) {
+	publishSecurityEvent(ctx, useCases.EventPublisher, useCases.Logger, eventType, guid, ip, userAgent)
+}
+
+// notifyAboutSuspiciousIP warns user, that someone tried to refresh tokens from unknown IP address.
+// Email contains link, following which user can end the session of refresh token with provided ID. Session is
+// checked in the same transaction, in which notification is written, so that link is not sent for ended session.
+func (useCases *CommonUseCases) notifyAboutSuspiciousIP(
+	ctx context.Context,
+	guid string,
+	refreshTokenID int,
+	data entities.RefreshTokensDTO,
+) {
+	revokeToken, err := security.GenerateJWT(
+		security.JWTData{
+			IP:        data.IP,
+			GUID:      guid,
+			Value:     revokeTokenValuePrefix + strconv.Itoa(refreshTokenID),
+			SecretKey: useCases.JWTConfig.SecretKey,
+			Algorithm: useCases.JWTConfig.Algorithm,
+			TTL:       useCases.EmailsConfig.RevokeLinkTTL,
+		},
+	)
+
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to generate revoke token",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return
+	}
+
+	notification := useCases.prepareNotification(
+		ctx,
+		guid,
+		emails.SuspiciousIPTemplate,
+		entities.SuspiciousIPEmailData{
+			Time:       time.Now(),
+			IP:         data.IP,
+			Location:   data.Location,
+			Device:     data.UserAgent,
+			RevokeLink: useCases.EmailsConfig.RevokeLinkURL + "?token=" + url.QueryEscape(revokeToken),
+		},
+	)
+
+	if notification == nil {
+		return
+	}
+
+	err = useCases.atomically(
+		ctx,
+		func(ctx context.Context) error {
+			active, err := useCases.AuthService.IsSessionActive(ctx, guid, refreshTokenID)
+			if err != nil {
+				return err
+			}
+
+			if !active {
+				requestid.Logger(ctx, useCases.Logger).Info(
+					"Session has already ended, user is not warned about suspicious IP",
+					"GUID",
+					guid,
+				)
+
+				return nil
+			}
+
+			return useCases.Notifier.Notify(ctx, *notification)
+		},
 	)
+
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to send notification",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+	}
+}
+
+// prepareNotification renders email from template in locale of user and addresses it to user. If email can not be
+// prepared, error is logged and nil is returned.
+func (useCases *CommonUseCases) prepareNotification(
+	ctx context.Context,
+	guid, template string,
+	data any,
+) *entities.Notification {
+	email, err := useCases.UsersService.GetUserEmail(ctx, guid)
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to get user email",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return nil
+	}
+
+	// Default locale will be used by renderer, if user locale is unknown:
+	locale, err := useCases.UsersService.GetUserLocale(ctx, guid)
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Warn("Failed to get user locale", "Error", err)
+	}
+
+	renderedEmail, err := useCases.EmailRenderer.Render(template, locale, data)
+	if err != nil {
+		requestid.Logger(ctx, useCases.Logger).Error(
+			"Failed to render email",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return nil
+	}
+
+	return &entities.Notification{
+		Recipients: []string{email},
+		Subject:    renderedEmail.Subject,
+		Body:       renderedEmail.Text,
+		HTMLBody:   renderedEmail.HTML,
+	}
+}
+
+func (useCases *CommonUseCases) atomically(ctx context.Context, work func(ctx context.Context) error) error {
+	if useCases.UnitOfWork == nil {
+		return work(ctx)
+	}
+
+	return useCases.UnitOfWork.Do(ctx, work)
 }
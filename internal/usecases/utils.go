@@ -1,13 +1,16 @@
 package usecases
 
 import (
+	"context"
 	"log/slog"
 	"math/rand"
 	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
-	"github.com/DKhorkov/medods/internal/config"
-	gomail "gopkg.in/gomail.v2"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/DKhorkov/medods/internal/security"
 )
 
 func generateRandomString(length int) string {
@@ -26,33 +29,39 @@ func generateRandomString(length int) string {
 	return string(bytes)
 }
 
-func sendEmail(
-	subject string,
-	body string,
-	emailsTo []string,
-	smtpConfig config.SMTPConfig,
+// publishSecurityEvent publishes security event, if publisher is provided.
+func publishSecurityEvent(
+	ctx context.Context,
+	publisher interfaces.EventPublisher,
 	logger *slog.Logger,
+	eventType, guid, ip, userAgent string,
 ) {
-	message := gomail.NewMessage()
-	message.SetHeader("From", smtpConfig.Login)
-	message.SetHeader("To", emailsTo...)
-	message.SetHeader("Subject", subject)
-	message.SetBody("text/html", body)
-
-	smtpClient := gomail.NewDialer(
-		smtpConfig.Host,
-		smtpConfig.Port,
-		smtpConfig.Login,
-		smtpConfig.Password,
-	)
+	if publisher == nil {
+		return
+	}
 
-	if err := smtpClient.DialAndSend(message); err != nil {
-		logger.Error(
-			"Failed to send email",
+	id, err := security.GenerateUUID()
+	if err != nil {
+		requestid.Logger(ctx, logger).Error(
+			"Failed to generate security event ID",
 			"Traceback",
 			logging.GetLogTraceback(),
 			"Error",
 			err,
 		)
+
+		return
 	}
+
+	publisher.Publish(
+		ctx,
+		entities.SecurityEvent{
+			ID:         id,
+			Type:       eventType,
+			GUID:       guid,
+			IP:         ip,
+			UserAgent:  userAgent,
+			OccurredAt: time.Now().UTC(),
+		},
+	)
 }
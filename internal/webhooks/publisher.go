@@ -0,0 +1,247 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/DKhorkov/medods/internal/security"
+)
+
+const (
+	EventHeader     = "X-Medods-Event"
+	DeliveryHeader  = "X-Medods-Delivery"
+	TimestampHeader = "X-Medods-Timestamp"
+
+	// SignatureHeader contains HMAC-SHA256 of "<X-Medods-Timestamp>.<body>", signed with subscription secret.
+	SignatureHeader = "X-Medods-Signature"
+)
+
+// EndpointStatus tracks deliveries to one webhook endpoint.
+type EndpointStatus struct {
+	URL                 string    `json:"url"`
+	Delivered           int       `json:"delivered"`
+	Failed              int       `json:"failed"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastSuccessAt       time.Time `json:"lastSuccessAt"`
+	LastFailureAt       time.Time `json:"lastFailureAt"`
+}
+
+// Publisher POSTs security events as JSON to subscribed endpoints. Every event is delivered in its own
+// goroutine and retried with exponential backoff up to config.WebhooksConfig.MaxAttempts times.
+type Publisher struct {
+	webhooksConfig config.WebhooksConfig
+	client         *http.Client
+	logger         *slog.Logger
+	statuses       map[string]*EndpointStatus
+	deliveries     sync.WaitGroup
+	mutex          sync.Mutex
+	stopped        bool
+	stopChannel    chan struct{}
+}
+
+// Publish starts delivery of event to subscribed endpoints. Request ID of ctx is forwarded in X-Request-ID header,
+// while delivery outlives ctx.
+func (publisher *Publisher) Publish(ctx context.Context, event entities.SecurityEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		requestid.Logger(ctx, publisher.logger).Error(
+			"Failed to marshal security event",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return
+	}
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	if publisher.stopped {
+		requestid.Logger(ctx, publisher.logger).Warn(
+			"Security event is not published due to shutdown",
+			"EventID",
+			event.ID,
+		)
+
+		return
+	}
+
+	requestID := requestid.FromContext(ctx)
+
+	for _, subscription := range publisher.webhooksConfig.Subscriptions {
+		if !isSubscribed(subscription, event.Type) {
+			continue
+		}
+
+		publisher.deliveries.Add(1)
+		go func() {
+			defer publisher.deliveries.Done()
+			publisher.deliver(subscription, event, payload, requestID)
+		}()
+	}
+}
+
+// Run does nothing except waiting for Stop, because deliveries are started by Publish.
+func (publisher *Publisher) Run() {
+	<-publisher.stopChannel
+}
+
+// Stop rejects new events and waits for deliveries in progress. Pending retries are canceled.
+func (publisher *Publisher) Stop() {
+	publisher.mutex.Lock()
+	if !publisher.stopped {
+		publisher.stopped = true
+		close(publisher.stopChannel)
+	}
+
+	publisher.mutex.Unlock()
+	publisher.deliveries.Wait()
+}
+
+// Statuses returns copy of delivery statuses of all endpoints, to which events were sent.
+func (publisher *Publisher) Statuses() []EndpointStatus {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(publisher.statuses))
+	for _, status := range publisher.statuses {
+		statuses = append(statuses, *status)
+	}
+
+	return statuses
+}
+
+// Status reports delivery statuses of endpoints to admin API.
+func (publisher *Publisher) Status() any {
+	return publisher.Statuses()
+}
+
+func (publisher *Publisher) deliver(
+	subscription config.WebhookSubscription,
+	event entities.SecurityEvent,
+	payload []byte,
+	requestID string,
+) {
+	delay := publisher.webhooksConfig.BaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := publisher.send(subscription, event, payload, requestID)
+		publisher.track(subscription.URL, err)
+		if err == nil {
+			return
+		}
+
+		publisher.logger.Warn(
+			"Failed to deliver security event",
+			"RequestID", requestID,
+			"URL", subscription.URL,
+			"EventID", event.ID,
+			"Attempt", attempt,
+			"Error", err,
+		)
+
+		if attempt >= publisher.webhooksConfig.MaxAttempts {
+			return
+		}
+
+		select {
+		case <-publisher.stopChannel:
+			return
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+func (publisher *Publisher) send(
+	subscription config.WebhookSubscription,
+	event entities.SecurityEvent,
+	payload []byte,
+	requestID string,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publisher.webhooksConfig.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, event.Type)
+	request.Header.Set(DeliveryHeader, event.ID)
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, security.SignPayload(subscription.Secret, timestamp, payload))
+	if requestID != "" {
+		request.Header.Set(requestid.Header, requestID)
+	}
+
+	response, err := publisher.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return customerrors.WebhookDeliveryError{URL: subscription.URL, StatusCode: response.StatusCode}
+	}
+
+	return nil
+}
+
+func (publisher *Publisher) track(url string, err error) {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	status, ok := publisher.statuses[url]
+	if !ok {
+		status = &EndpointStatus{URL: url}
+		publisher.statuses[url] = status
+	}
+
+	if err == nil {
+		status.Delivered++
+		status.ConsecutiveFailures = 0
+		status.LastSuccessAt = time.Now()
+		return
+	}
+
+	status.Failed++
+	status.ConsecutiveFailures++
+	status.LastError = err.Error()
+	status.LastFailureAt = time.Now()
+}
+
+func isSubscribed(subscription config.WebhookSubscription, eventType string) bool {
+	return slices.Contains(subscription.Events, entities.AllSecurityEventsWildcard) ||
+		slices.Contains(subscription.Events, eventType)
+}
+
+// NewPublisher creates an instance of Publisher.
+func NewPublisher(webhooksConfig config.WebhooksConfig, logger *slog.Logger) *Publisher {
+	return &Publisher{
+		webhooksConfig: webhooksConfig,
+		client:         &http.Client{Timeout: webhooksConfig.Timeout},
+		logger:         logger,
+		statuses:       make(map[string]*EndpointStatus),
+		stopChannel:    make(chan struct{}),
+	}
+}
@@ -0,0 +1,125 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// defaultHealthProbeInterval replaces non-positive config.DatabaseHealthProbeConfig.Interval, because readiness
+// would never change after the first ping without periodic probes.
+const defaultHealthProbeInterval = time.Second * 10
+
+// DatabaseHealthProbe periodically pings database. Database is considered unhealthy until the first successful
+// ping, so that service is not ready before it is checked.
+type DatabaseHealthProbe struct {
+	dbConnector       interfaces.DBConnector
+	healthProbeConfig config.DatabaseHealthProbeConfig
+	logger            *slog.Logger
+	lifecycle         *lifecycle
+	mutex             sync.RWMutex
+	status            entities.HealthStatus
+}
+
+// Run pings database immediately and then every config.DatabaseHealthProbeConfig.Interval until Stop is called.
+func (probe *DatabaseHealthProbe) Run() {
+	if !probe.lifecycle.start() {
+		return
+	}
+
+	defer probe.lifecycle.finish()
+
+	ticker := time.NewTicker(probe.healthProbeConfig.Interval)
+	defer ticker.Stop()
+
+	ctx, cancel := probe.lifecycle.context()
+	defer cancel()
+
+	for {
+		_ = probe.Probe(ctx)
+
+		select {
+		case <-probe.lifecycle.stopChannel:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop probing and wait for Run to return. Ping, which is in progress, is cancelled.
+func (probe *DatabaseHealthProbe) Stop() {
+	probe.lifecycle.stop()
+}
+
+// Probe pings database and saves result. Change of health is logged.
+func (probe *DatabaseHealthProbe) Probe(ctx context.Context) error {
+	if probe.healthProbeConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, probe.healthProbeConfig.Timeout)
+		defer cancel()
+	}
+
+	var err error
+	if connection := probe.dbConnector.GetConnection(); connection != nil {
+		err = connection.PingContext(ctx)
+	} else {
+		err = customerrors.NilDBConnectionError{}
+	}
+
+	status := entities.HealthStatus{Healthy: err == nil, CheckedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	probe.mutex.Lock()
+	previous := probe.status
+	probe.status = status
+	probe.mutex.Unlock()
+
+	switch {
+	case err != nil && (previous.Healthy || previous.CheckedAt.IsZero()):
+		probe.logger.Error(
+			"Database health probe failed",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+	case err == nil && !previous.Healthy:
+		probe.logger.Info("Database is healthy")
+	}
+
+	return err
+}
+
+// Health returns result of the last ping.
+func (probe *DatabaseHealthProbe) Health() entities.HealthStatus {
+	probe.mutex.RLock()
+	defer probe.mutex.RUnlock()
+
+	return probe.status
+}
+
+func NewDatabaseHealthProbe(
+	dbConnector interfaces.DBConnector,
+	healthProbeConfig config.DatabaseHealthProbeConfig,
+	logger *slog.Logger,
+) *DatabaseHealthProbe {
+	if healthProbeConfig.Interval <= 0 {
+		healthProbeConfig.Interval = defaultHealthProbeInterval
+	}
+
+	return &DatabaseHealthProbe{
+		dbConnector:       dbConnector,
+		healthProbeConfig: healthProbeConfig,
+		logger:            logger,
+		lifecycle:         newLifecycle(),
+	}
+}
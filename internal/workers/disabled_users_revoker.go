@@ -0,0 +1,87 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// DisabledUsersRevoker periodically revokes sessions of disabled users and denies their access tokens. Status of
+// users is changed outside of the service, so that it is not known, when user becomes disabled.
+type DisabledUsersRevoker struct {
+	useCases  interfaces.DisabledUsersUseCases
+	interval  time.Duration
+	logger    *slog.Logger
+	lifecycle *lifecycle
+}
+
+// Run revokes sessions every interval until Stop is called. Non-positive interval disables periodic revocation.
+func (revoker *DisabledUsersRevoker) Run() {
+	if !revoker.lifecycle.start() {
+		return
+	}
+
+	defer revoker.lifecycle.finish()
+
+	var tickerChannel <-chan time.Time
+	if revoker.interval > 0 {
+		ticker := time.NewTicker(revoker.interval)
+		defer ticker.Stop()
+		tickerChannel = ticker.C
+	}
+
+	ctx, cancel := revoker.lifecycle.context()
+	defer cancel()
+
+	for {
+		select {
+		case <-revoker.lifecycle.stopChannel:
+			return
+		case <-tickerChannel:
+			_, _ = revoker.Revoke(ctx)
+		}
+	}
+}
+
+// Stop revoking and wait for Run to return. Revocation, which is in progress, is cancelled.
+func (revoker *DisabledUsersRevoker) Stop() {
+	revoker.lifecycle.stop()
+}
+
+// Revoke revokes sessions of disabled users and returns number of users, which sessions have been revoked.
+func (revoker *DisabledUsersRevoker) Revoke(ctx context.Context) (int, error) {
+	revoked, err := revoker.useCases.RevokeDisabledUsersSessions(ctx)
+	if err != nil {
+		revoker.logger.Error(
+			"Failed to revoke sessions of disabled users",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return revoked, err
+	}
+
+	if revoked > 0 {
+		revoker.logger.Info("Sessions of disabled users revoked", "Users", revoked)
+	}
+
+	return revoked, nil
+}
+
+func NewDisabledUsersRevoker(
+	useCases interfaces.DisabledUsersUseCases,
+	interval time.Duration,
+	logger *slog.Logger,
+) *DisabledUsersRevoker {
+	return &DisabledUsersRevoker{
+		useCases:  useCases,
+		interval:  interval,
+		logger:    logger,
+		lifecycle: newLifecycle(),
+	}
+}
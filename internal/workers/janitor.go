@@ -0,0 +1,247 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// defaultJanitorBatchSize replaces non-positive config.JanitorConfig.BatchSize, with which nothing would be purged.
+const defaultJanitorBatchSize = 1000
+
+// JanitorStats describes work of Janitor since its creation.
+type JanitorStats struct {
+	Runs                int           `json:"runs"`
+	Failures            int           `json:"failures"`
+	PurgedRefreshTokens int           `json:"purgedRefreshTokens"`
+	PurgedDenials       int           `json:"purgedDenials"`
+	PurgedOutbox        int           `json:"purgedOutbox"`
+	LastRunAt           time.Time     `json:"lastRunAt"`
+	LastRunDuration     time.Duration `json:"lastRunDuration"`
+	LastError           string        `json:"lastError,omitempty"`
+}
+
+// JanitorPurged is number of rows of every kind, deleted by one purge.
+type JanitorPurged struct {
+	RefreshTokens int
+	Denials       int
+	Outbox        int
+}
+
+// Janitor periodically deletes expired refresh tokens, refresh tokens, revoked longer than retention window
+// ago, expired access tokens denials and delivered and dead notifications, last updated longer than retention
+// window ago. Rows are deleted in bounded batches to keep transactions short.
+type Janitor struct {
+	authRepository   interfaces.AuthRepository
+	outboxRepository interfaces.OutboxRepository
+	janitorConfig    config.JanitorConfig
+	logger           *slog.Logger
+	lifecycle        *lifecycle
+	mutex            sync.Mutex
+	stats            JanitorStats
+}
+
+// Run purges rows every config.JanitorConfig.Interval until Stop is called. Non-positive interval disables
+// periodic purge, so that rows are purged only on demand.
+func (janitor *Janitor) Run() {
+	if !janitor.lifecycle.start() {
+		return
+	}
+
+	defer janitor.lifecycle.finish()
+
+	var tickerChannel <-chan time.Time
+	if janitor.janitorConfig.Interval > 0 {
+		ticker := time.NewTicker(janitor.janitorConfig.Interval)
+		defer ticker.Stop()
+		tickerChannel = ticker.C
+	}
+
+	// Purge, which is in progress, is cancelled on stop:
+	ctx, cancel := janitor.lifecycle.context()
+	defer cancel()
+
+	for {
+		select {
+		case <-janitor.lifecycle.stopChannel:
+			return
+		case <-tickerChannel:
+			_, _ = janitor.Purge(ctx)
+		}
+	}
+}
+
+// Stop purging and wait for Run to return. Batch, which is being deleted, is cancelled.
+func (janitor *Janitor) Stop() {
+	janitor.lifecycle.stop()
+}
+
+// Purge deletes rows in batches until ctx is done and returns number of purged rows of every kind.
+func (janitor *Janitor) Purge(ctx context.Context) (JanitorPurged, error) {
+	startedAt := time.Now()
+	deletedBefore := startedAt.Add(-janitor.janitorConfig.Retention)
+
+	var purged JanitorPurged
+	var err error
+	purged.RefreshTokens, err = janitor.purgeInBatches(
+		ctx,
+		func(limit int) (int, error) {
+			return janitor.authRepository.PurgeRefreshTokens(ctx, startedAt, deletedBefore, limit)
+		},
+	)
+
+	if err == nil {
+		purged.Denials, err = janitor.purgeInBatches(
+			ctx,
+			func(limit int) (int, error) {
+				return janitor.authRepository.PurgeAccessTokensDenylist(ctx, startedAt, limit)
+			},
+		)
+	}
+
+	if err == nil {
+		purged.Outbox, err = janitor.purgeInBatches(
+			ctx,
+			func(limit int) (int, error) {
+				return janitor.outboxRepository.PurgeOutbox(ctx, deletedBefore, limit)
+			},
+		)
+	}
+
+	janitor.mutex.Lock()
+	janitor.stats.Runs++
+	janitor.stats.PurgedRefreshTokens += purged.RefreshTokens
+	janitor.stats.PurgedDenials += purged.Denials
+	janitor.stats.PurgedOutbox += purged.Outbox
+	janitor.stats.LastRunAt = startedAt
+	janitor.stats.LastRunDuration = time.Since(startedAt)
+	janitor.stats.LastError = ""
+	if err != nil {
+		janitor.stats.Failures++
+		janitor.stats.LastError = err.Error()
+	}
+
+	janitor.mutex.Unlock()
+
+	if err != nil {
+		janitor.logger.Error(
+			"Failed to purge expired rows",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return purged, err
+	}
+
+	janitor.logger.Info(
+		"Expired rows purged",
+		"RefreshTokens",
+		purged.RefreshTokens,
+		"Denials",
+		purged.Denials,
+		"Outbox",
+		purged.Outbox,
+		"Duration",
+		time.Since(startedAt),
+	)
+
+	return purged, nil
+}
+
+// Stats returns copy of janitor statistics.
+func (janitor *Janitor) Stats() JanitorStats {
+	janitor.mutex.Lock()
+	defer janitor.mutex.Unlock()
+
+	return janitor.stats
+}
+
+// Status reports statistics of janitor to admin API.
+func (janitor *Janitor) Status() any {
+	return janitor.Stats()
+}
+
+// WriteMetrics writes statistics of janitor in Prometheus text exposition format. Purged rows are counted by table.
+func (janitor *Janitor) WriteMetrics(writer io.Writer) error {
+	stats := janitor.Stats()
+
+	var lastRunAt float64
+	if !stats.LastRunAt.IsZero() {
+		lastRunAt = float64(stats.LastRunAt.UnixNano()) / float64(time.Second)
+	}
+
+	_, err := fmt.Fprintf(
+		writer,
+		`# HELP medods_janitor_runs_total Number of purges, run by janitor.
+# TYPE medods_janitor_runs_total counter
+medods_janitor_runs_total %d
+# HELP medods_janitor_failures_total Number of purges, which have failed.
+# TYPE medods_janitor_failures_total counter
+medods_janitor_failures_total %d
+# HELP medods_janitor_purged_rows_total Number of rows, purged by janitor.
+# TYPE medods_janitor_purged_rows_total counter
+medods_janitor_purged_rows_total{table="refresh_tokens"} %d
+medods_janitor_purged_rows_total{table="access_tokens_denylist"} %d
+medods_janitor_purged_rows_total{table="notifications_outbox"} %d
+# HELP medods_janitor_last_run_timestamp_seconds Start time of the last purge, zero before the first one.
+# TYPE medods_janitor_last_run_timestamp_seconds gauge
+medods_janitor_last_run_timestamp_seconds %g
+# HELP medods_janitor_last_run_duration_seconds Duration of the last purge.
+# TYPE medods_janitor_last_run_duration_seconds gauge
+medods_janitor_last_run_duration_seconds %g
+`,
+		stats.Runs,
+		stats.Failures,
+		stats.PurgedRefreshTokens,
+		stats.PurgedDenials,
+		stats.PurgedOutbox,
+		lastRunAt,
+		stats.LastRunDuration.Seconds(),
+	)
+
+	return err
+}
+
+// purgeInBatches calls purge until it deletes less than batch size rows or ctx is done.
+func (janitor *Janitor) purgeInBatches(ctx context.Context, purge func(limit int) (int, error)) (int, error) {
+	var total int
+	for {
+		purged, err := purge(janitor.janitorConfig.BatchSize)
+		total += purged
+		if err != nil || purged < janitor.janitorConfig.BatchSize {
+			return total, err
+		}
+
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
+
+func NewJanitor(
+	authRepository interfaces.AuthRepository,
+	outboxRepository interfaces.OutboxRepository,
+	janitorConfig config.JanitorConfig,
+	logger *slog.Logger,
+) *Janitor {
+	if janitorConfig.BatchSize <= 0 {
+		janitorConfig.BatchSize = defaultJanitorBatchSize
+	}
+
+	return &Janitor{
+		authRepository:   authRepository,
+		outboxRepository: outboxRepository,
+		janitorConfig:    janitorConfig,
+		logger:           logger,
+		lifecycle:        newLifecycle(),
+	}
+}
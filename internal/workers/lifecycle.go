@@ -0,0 +1,63 @@
+package workers
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle lets Stop of worker wait until Run returns, so that resources, used by worker, can be released
+// after Stop. Run, which is called after Stop, returns immediately.
+type lifecycle struct {
+	stopChannel chan struct{}
+	mutex       sync.Mutex
+	stopped     bool
+	running     sync.WaitGroup
+}
+
+// start registers running Run and returns false, if worker has been already stopped.
+func (lifecycle *lifecycle) start() bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	if lifecycle.stopped {
+		return false
+	}
+
+	lifecycle.running.Add(1)
+	return true
+}
+
+// finish is called, when Run returns.
+func (lifecycle *lifecycle) finish() {
+	lifecycle.running.Done()
+}
+
+// context returns context, which is cancelled on stop.
+func (lifecycle *lifecycle) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-lifecycle.stopChannel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// stop signals Run to return and waits for it.
+func (lifecycle *lifecycle) stop() {
+	lifecycle.mutex.Lock()
+	if !lifecycle.stopped {
+		lifecycle.stopped = true
+		close(lifecycle.stopChannel)
+	}
+
+	lifecycle.mutex.Unlock()
+	lifecycle.running.Wait()
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopChannel: make(chan struct{})}
+}
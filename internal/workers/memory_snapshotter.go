@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// MemorySnapshotter periodically saves in-memory database of memory backend to config.MemoryStoreConfig.SnapshotPath
+// and saves the final snapshot on stop, so that refresh tokens, users and outbox survive restart together. It should
+// be stopped after other workers, which may write to database, while they are stopped.
+type MemorySnapshotter struct {
+	dbConnector  interfaces.DBConnector
+	memoryConfig config.MemoryStoreConfig
+	logger       *slog.Logger
+	lifecycle    *lifecycle
+}
+
+// Run saves snapshot every config.MemoryStoreConfig.SnapshotInterval until Stop is called. Non-positive interval
+// disables periodic snapshots, so that snapshot is saved only on stop.
+func (snapshotter *MemorySnapshotter) Run() {
+	if !snapshotter.lifecycle.start() {
+		return
+	}
+
+	defer snapshotter.lifecycle.finish()
+
+	var tickerChannel <-chan time.Time
+	if snapshotter.memoryConfig.SnapshotInterval > 0 {
+		ticker := time.NewTicker(snapshotter.memoryConfig.SnapshotInterval)
+		defer ticker.Stop()
+		tickerChannel = ticker.C
+	}
+
+	ctx, cancel := snapshotter.lifecycle.context()
+	defer cancel()
+
+	for {
+		select {
+		case <-snapshotter.lifecycle.stopChannel:
+			return
+		case <-tickerChannel:
+			_ = snapshotter.Snapshot(ctx)
+		}
+	}
+}
+
+// Stop saving snapshots periodically, wait for Run to return and save the final snapshot.
+func (snapshotter *MemorySnapshotter) Stop() {
+	snapshotter.lifecycle.stop()
+	_ = snapshotter.Snapshot(context.Background())
+}
+
+// Snapshot saves in-memory database, if snapshot path is configured.
+func (snapshotter *MemorySnapshotter) Snapshot(ctx context.Context) error {
+	if snapshotter.memoryConfig.SnapshotPath == "" {
+		return nil
+	}
+
+	err := database.SaveSnapshot(ctx, snapshotter.dbConnector, snapshotter.memoryConfig.SnapshotPath)
+	if err != nil {
+		snapshotter.logger.Error(
+			"Failed to save memory database snapshot",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+	}
+
+	return err
+}
+
+func NewMemorySnapshotter(
+	dbConnector interfaces.DBConnector,
+	memoryConfig config.MemoryStoreConfig,
+	logger *slog.Logger,
+) *MemorySnapshotter {
+	return &MemorySnapshotter{
+		dbConnector:  dbConnector,
+		memoryConfig: memoryConfig,
+		logger:       logger,
+		lifecycle:    newLifecycle(),
+	}
+}
@@ -0,0 +1,185 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+)
+
+// Defaults replace non-positive settings of dispatcher, with which messages would never be delivered.
+const (
+	defaultOutboxPollInterval = time.Second * 5
+	defaultOutboxBatchSize    = 100
+)
+
+// OutboxDispatcher periodically delivers pending outbox messages through Notifier. Failed deliveries are
+// retried with exponential backoff, and after config.OutboxConfig.MaxAttempts messages are dead-lettered.
+// Delivery semantics is at-least-once.
+type OutboxDispatcher struct {
+	outboxRepository interfaces.OutboxRepository
+	notifier         interfaces.Notifier
+	outboxConfig     config.OutboxConfig
+	logger           *slog.Logger
+	lifecycle        *lifecycle
+}
+
+// Run dispatches pending messages until Stop is called.
+func (dispatcher *OutboxDispatcher) Run() {
+	if !dispatcher.lifecycle.start() {
+		return
+	}
+
+	defer dispatcher.lifecycle.finish()
+
+	ticker := time.NewTicker(dispatcher.outboxConfig.PollInterval)
+	defer ticker.Stop()
+
+	// Batch, which is being dispatched, is interrupted on stop:
+	ctx, cancel := dispatcher.lifecycle.context()
+	defer cancel()
+
+	for {
+		select {
+		case <-dispatcher.lifecycle.stopChannel:
+			return
+		case <-ticker.C:
+			dispatcher.DispatchPending(ctx)
+		}
+	}
+}
+
+// Stop dispatching and wait for delivery, which is in progress. Claimed messages of interrupted batch will be
+// retried after lease expiration.
+func (dispatcher *OutboxDispatcher) Stop() {
+	dispatcher.lifecycle.stop()
+}
+
+// DispatchPending delivers one batch of pending messages and returns count of successfully delivered ones.
+// When ctx is done, the rest of batch is skipped, while delivery, which has been already started, is completed.
+func (dispatcher *OutboxDispatcher) DispatchPending(ctx context.Context) int {
+	messages, err := dispatcher.outboxRepository.GetPendingOutboxMessages(ctx, dispatcher.outboxConfig.BatchSize)
+	if err != nil {
+		dispatcher.logger.Error(
+			"Failed to get pending outbox messages",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+
+		return 0
+	}
+
+	var delivered int
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if dispatcher.dispatch(context.WithoutCancel(ctx), message) {
+			delivered++
+		}
+	}
+
+	return delivered
+}
+
+func (dispatcher *OutboxDispatcher) dispatch(ctx context.Context, message entities.OutboxMessage) bool {
+	claimed, err := dispatcher.outboxRepository.ClaimOutboxMessage(
+		ctx,
+		message.ID,
+		time.Now().Add(dispatcher.outboxConfig.Lease),
+	)
+
+	if err != nil || !claimed {
+		// Message is already claimed by another dispatcher or will be retried during next iteration:
+		return false
+	}
+
+	if err = dispatcher.notifier.Notify(ctx, message.Notification); err == nil {
+		if err = dispatcher.outboxRepository.MarkOutboxMessageDelivered(ctx, message.ID); err != nil {
+			dispatcher.logger.Error(
+				"Failed to mark outbox message as delivered",
+				"Traceback",
+				logging.GetLogTraceback(),
+				"Error",
+				err,
+			)
+		}
+
+		return true
+	}
+
+	attempt := message.Attempts + 1
+	dispatcher.logger.Warn(
+		"Failed to deliver outbox message",
+		"MessageID", message.ID,
+		"Attempt", attempt,
+		"Error", err,
+	)
+
+	if attempt >= dispatcher.outboxConfig.MaxAttempts {
+		err = dispatcher.outboxRepository.MarkOutboxMessageDead(ctx, message.ID, err.Error())
+	} else {
+		err = dispatcher.outboxRepository.MarkOutboxMessageFailed(
+			ctx,
+			message.ID,
+			err.Error(),
+			time.Now().Add(dispatcher.backoff(attempt)),
+		)
+	}
+
+	if err != nil {
+		dispatcher.logger.Error(
+			"Failed to mark outbox message as failed",
+			"Traceback",
+			logging.GetLogTraceback(),
+			"Error",
+			err,
+		)
+	}
+
+	return false
+}
+
+// backoff returns delay before next attempt, which grows exponentially with each attempt up to MaxBackoff.
+func (dispatcher *OutboxDispatcher) backoff(attempt int) time.Duration {
+	delay := dispatcher.outboxConfig.BaseBackoff
+	for range attempt - 1 {
+		delay *= 2
+		if delay >= dispatcher.outboxConfig.MaxBackoff {
+			return dispatcher.outboxConfig.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// NewOutboxDispatcher creates an instance of OutboxDispatcher.
+func NewOutboxDispatcher(
+	outboxRepository interfaces.OutboxRepository,
+	notifier interfaces.Notifier,
+	outboxConfig config.OutboxConfig,
+	logger *slog.Logger,
+) *OutboxDispatcher {
+	if outboxConfig.PollInterval <= 0 {
+		outboxConfig.PollInterval = defaultOutboxPollInterval
+	}
+
+	if outboxConfig.BatchSize <= 0 {
+		outboxConfig.BatchSize = defaultOutboxBatchSize
+	}
+
+	return &OutboxDispatcher{
+		outboxRepository: outboxRepository,
+		notifier:         notifier,
+		outboxConfig:     outboxConfig,
+		logger:           logger,
+		lifecycle:        newLifecycle(),
+	}
+}
@@ -23,7 +23,11 @@ type TestConfig struct {
 	Database     TestDatabaseConfig
 	RefreshToken TestRefreshTokenConfig
 	SMTP         config.SMTPConfig
+	Outbox       config.OutboxConfig
+	Emails       config.EmailsConfig
+	Webhooks     config.WebhooksConfig
 	JWT          config.JWTConfig
+	Session      config.SessionConfig
 	Logging      config.LoggingConfig
 	IP           string
 	HashCost     int
@@ -34,10 +38,10 @@ func New() *TestConfig {
 		Database: TestDatabaseConfig{
 			Driver:        "sqlite3",
 			DSN:           "file::memory:?cache=shared", // "test.db" can be also used
-			MigrationsDir: "/internal/database/migrations",
+			MigrationsDir: "/internal/database/migrations/sqlite",
 		},
 		RefreshToken: TestRefreshTokenConfig{
-			GUID:  "42385b4f-d5cd-4543-acef-229fb60fe35g",
+			GUID:  "42385b4f-d5cd-4543-acef-229fb60fe35f",
 			Value: "testValue",
 		},
 		SMTP: config.SMTPConfig{
@@ -46,12 +50,34 @@ func New() *TestConfig {
 			Login:    "smtp",
 			Password: "smtp",
 		},
+		Emails: config.EmailsConfig{
+			DefaultLocale: "ru",
+			RevokeLinkURL: "http://localhost:8070/sessions/revoke",
+			RevokeLinkTTL: time.Hour,
+		},
+		Webhooks: config.WebhooksConfig{
+			Timeout:     time.Second,
+			MaxAttempts: 3,
+			BaseBackoff: time.Millisecond * 10,
+		},
+		Outbox: config.OutboxConfig{
+			PollInterval: time.Millisecond * 10,
+			BatchSize:    10,
+			MaxAttempts:  3,
+			BaseBackoff:  time.Minute,
+			MaxBackoff:   time.Hour,
+			Lease:        time.Minute,
+		},
 		JWT: config.JWTConfig{
 			Algorithm:       "HS256",
 			SecretKey:       "testSecret",
 			RefreshTokenTTL: time.Minute * 5,
 			AccessTokenTTL:  time.Minute * 1,
 		},
+		Session: config.SessionConfig{
+			MaxAge:      time.Hour * 24,
+			IdleTimeout: time.Hour,
+		},
 		IP:       "127.0.0.1",
 		HashCost: 4,
 		Logging: config.LoggingConfig{
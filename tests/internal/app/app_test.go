@@ -0,0 +1,71 @@
+package app__test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/app"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// stopRecorder records names of components in order they are stopped.
+type stopRecorder struct {
+	mutex   sync.Mutex
+	stopped []string
+}
+
+func (recorder *stopRecorder) record(name string) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.stopped = append(recorder.stopped, name)
+}
+
+type recordingComponent struct {
+	name     string
+	recorder *stopRecorder
+}
+
+func (component recordingComponent) Run() {}
+
+func (component recordingComponent) Stop() {
+	component.recorder.record(component.name)
+}
+
+type recordingDBConnector struct {
+	interfaces.DBConnector
+	recorder *stopRecorder
+}
+
+func (connector recordingDBConnector) CloseConnection() {
+	connector.recorder.record("database")
+}
+
+func TestAppRun(t *testing.T) {
+	t.Run("application is stopped in order", func(t *testing.T) {
+		recorder := &stopRecorder{}
+		application := app.New(
+			recordingComponent{name: "controller", recorder: recorder},
+			recordingDBConnector{recorder: recorder},
+			recordingComponent{name: "outboxDispatcher", recorder: recorder},
+			recordingComponent{name: "janitor", recorder: recorder},
+		)
+
+		finished := make(chan struct{})
+		go func() {
+			application.Run()
+			close(finished)
+		}()
+
+		application.Stop()
+		select {
+		case <-finished:
+		case <-time.After(time.Second):
+			t.Fatal("application was not stopped")
+		}
+
+		assert.Equal(t, []string{"controller", "outboxDispatcher", "janitor", "database"}, recorder.stopped)
+	})
+}
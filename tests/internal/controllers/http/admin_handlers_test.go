@@ -0,0 +1,270 @@
+package controllers__test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/services"
+	"github.com/DKhorkov/medods/internal/usecases"
+	"github.com/DKhorkov/medods/internal/webhooks"
+	"github.com/DKhorkov/medods/internal/workers"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "adminToken"
+
+func newTestAdminHandler() (httpcontroller.AdminHandler, *mocks.MockedAuthRepository) {
+	authRepository := &mocks.MockedAuthRepository{
+		RefreshTokensStorage: map[int]*entities.RefreshToken{
+			1: {ID: 1, GUID: testsConfig.RefreshToken.GUID, IP: testsConfig.IP, TTL: time.Now().Add(time.Hour)},
+			2: {ID: 2, GUID: testsConfig.RefreshToken.GUID, IP: testsConfig.IP, TTL: time.Now().Add(time.Hour)},
+		},
+	}
+
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+	handler := httpcontroller.AdminHandler{
+		UseCases: &usecases.CommonAdminUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			JWTConfig:   testsConfig.JWT,
+			Logger:      logger,
+		},
+		Config: config.AdminConfig{Token: testAdminToken, DefaultPageSize: 1, MaxPageSize: 10},
+		Logger: logger,
+	}
+
+	return handler, authRepository
+}
+
+func TestControllersHTTPAdminHandlerAuthenticate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		token      string
+		statusCode int
+	}{
+		{name: "valid admin token", token: testAdminToken, statusCode: http.StatusOK},
+		{name: "invalid admin token", token: "invalidToken", statusCode: http.StatusUnauthorized},
+		{name: "missing admin token", token: "", statusCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, _ := newTestAdminHandler()
+			request := httptest.NewRequest(http.MethodGet, "/admin/sessions?guid="+testsConfig.RefreshToken.GUID, nil)
+			request.Header.Set("X-Admin-Token", tc.token)
+
+			writer := httptest.NewRecorder()
+			handler.Authenticate(handler.GetSessionsHandleFunc())(writer, request)
+
+			result := writer.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tc.statusCode, result.StatusCode)
+		})
+	}
+}
+
+func TestControllersHTTPAdminHandlerSessions(t *testing.T) {
+	t.Run("list sessions with default page size", func(t *testing.T) {
+		handler, _ := newTestAdminHandler()
+		request := httptest.NewRequest(http.MethodGet, "/admin/sessions?guid="+testsConfig.RefreshToken.GUID, nil)
+
+		writer := httptest.NewRecorder()
+		handler.GetSessionsHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		var page entities.SessionsPage
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&page))
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, 2, page.Sessions[0].ID)
+		assert.True(t, page.HasMore)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		handler, _ := newTestAdminHandler()
+		request := httptest.NewRequest(http.MethodGet, "/admin/sessions?ip=127.0.0.1&limit=-1", nil)
+
+		writer := httptest.NewRecorder()
+		handler.GetSessionsHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+
+	t.Run("revoke session", func(t *testing.T) {
+		handler, authRepository := newTestAdminHandler()
+		request := httptest.NewRequest(http.MethodDelete, "/admin/sessions/1", nil)
+		request.SetPathValue("id", "1")
+
+		writer := httptest.NewRecorder()
+		handler.GetRevokeSessionHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.NotNil(t, authRepository.RefreshTokensStorage[1].DeletedAt)
+	})
+
+	t.Run("revoke non existing session", func(t *testing.T) {
+		handler, _ := newTestAdminHandler()
+		request := httptest.NewRequest(http.MethodDelete, "/admin/sessions/3", nil)
+		request.SetPathValue("id", "3")
+
+		writer := httptest.NewRecorder()
+		handler.GetRevokeSessionHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+
+	t.Run("revoke all sessions of user", func(t *testing.T) {
+		handler, _ := newTestAdminHandler()
+		request := httptest.NewRequest(http.MethodDelete, "/admin/users/"+testsConfig.RefreshToken.GUID+"/sessions", nil)
+		request.SetPathValue("guid", testsConfig.RefreshToken.GUID)
+
+		writer := httptest.NewRecorder()
+		handler.GetRevokeUserSessionsHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		var response map[string]int
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+		assert.Equal(t, 2, response["revoked"])
+	})
+
+	t.Run("expire access tokens of user", func(t *testing.T) {
+		handler, authRepository := newTestAdminHandler()
+		request := httptest.NewRequest(
+			http.MethodPost,
+			"/admin/users/"+testsConfig.RefreshToken.GUID+"/access-tokens/expire",
+			nil,
+		)
+
+		request.SetPathValue("guid", testsConfig.RefreshToken.GUID)
+
+		writer := httptest.NewRecorder()
+		handler.GetExpireAccessTokensHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.False(t, authRepository.DenylistStorage[testsConfig.RefreshToken.GUID].IsZero())
+	})
+
+	t.Run("malformed GUID of user", func(t *testing.T) {
+		handler, _ := newTestAdminHandler()
+		handleFuncs := map[string]func(http.ResponseWriter, *http.Request){
+			"revoke sessions":      handler.GetRevokeUserSessionsHandleFunc(),
+			"expire access tokens": handler.GetExpireAccessTokensHandleFunc(),
+			"list sessions": func(writer http.ResponseWriter, request *http.Request) {
+				request.URL.RawQuery = "guid=" + request.PathValue("guid")
+				handler.GetSessionsHandleFunc()(writer, request)
+			},
+		}
+
+		for name, handleFunc := range handleFuncs {
+			request := httptest.NewRequest(http.MethodPost, "/admin/users/someGUID", nil)
+			request.SetPathValue("guid", "someGUID")
+
+			writer := httptest.NewRecorder()
+			handleFunc(writer, request)
+
+			result := writer.Result()
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode, name)
+			assert.Equal(t, "invalid_parameter", decodeProblem(t, result).Code, name)
+			_ = result.Body.Close()
+		}
+	})
+}
+
+func TestControllersHTTPAdminHandlerStatus(t *testing.T) {
+	t.Run("statistics of janitor are reported", func(t *testing.T) {
+		handler, authRepository := newTestAdminHandler()
+		authRepository.RefreshTokensStorage[1].TTL = time.Now().Add(-time.Hour)
+
+		janitor := workers.NewJanitor(
+			authRepository,
+			&repositories.CommonOutboxRepository{DBConnector: testlifespan.StartUpMemory(t)},
+			config.JanitorConfig{},
+			handler.Logger,
+		)
+
+		_, err := janitor.Purge(context.Background())
+		require.NoError(t, err)
+
+		handler.StatusReporters = map[string]interfaces.StatusReporter{"janitor": janitor}
+		request := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		writer := httptest.NewRecorder()
+		handler.GetStatusHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		var response map[string]workers.JanitorStats
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+		assert.Equal(t, 1, response["janitor"].Runs)
+		assert.Equal(t, 1, response["janitor"].PurgedRefreshTokens)
+	})
+}
+
+func TestControllersHTTPAdminHandlerMetrics(t *testing.T) {
+	t.Run("counters of janitor are exposed in Prometheus text format", func(t *testing.T) {
+		handler, authRepository := newTestAdminHandler()
+		authRepository.RefreshTokensStorage[1].TTL = time.Now().Add(-time.Hour)
+
+		janitor := workers.NewJanitor(
+			authRepository,
+			&repositories.CommonOutboxRepository{DBConnector: testlifespan.StartUpMemory(t)},
+			config.JanitorConfig{},
+			handler.Logger,
+		)
+
+		_, err := janitor.Purge(context.Background())
+		require.NoError(t, err)
+
+		// Reporters without metrics are skipped:
+		handler.StatusReporters = map[string]interfaces.StatusReporter{"janitor": janitor, "webhooks": &webhooks.Publisher{}}
+		request := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+		writer := httptest.NewRecorder()
+		handler.GetMetricsHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", result.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "# TYPE medods_janitor_runs_total counter\nmedods_janitor_runs_total 1\n")
+		assert.Contains(t, string(body), "medods_janitor_failures_total 0\n")
+		assert.Contains(t, string(body), `medods_janitor_purged_rows_total{table="refresh_tokens"} 1`)
+		assert.Contains(t, string(body), `medods_janitor_purged_rows_total{table="notifications_outbox"} 0`)
+		assert.NotContains(t, string(body), "medods_janitor_last_run_timestamp_seconds 0\n")
+	})
+}
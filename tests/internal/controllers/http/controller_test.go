@@ -0,0 +1,107 @@
+package controllers__test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowHealthProbe makes readiness request last until release is closed.
+type slowHealthProbe struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (probe slowHealthProbe) Health() entities.HealthStatus {
+	close(probe.started)
+	<-probe.release
+	return entities.HealthStatus{Healthy: true, CheckedAt: time.Now()}
+}
+
+func getFreePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestControllersHTTPControllerStop(t *testing.T) {
+	t.Run("requests in progress are completed on stop", func(t *testing.T) {
+		port := getFreePort(t)
+		probe := slowHealthProbe{started: make(chan struct{}), release: make(chan struct{})}
+		controller := httpcontroller.New(
+			config.HTTPConfig{Host: "127.0.0.1", Port: port, DrainTimeout: time.Second * 5},
+			config.UsersConfig{},
+			nil,
+			nil,
+			config.AdminConfig{},
+			map[string]interfaces.HealthProbe{"database": probe},
+			nil,
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		go controller.Run()
+		url := fmt.Sprintf("http://127.0.0.1:%d/readyz", port)
+		require.Eventually(
+			t,
+			func() bool {
+				connection, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+				if err == nil {
+					_ = connection.Close()
+				}
+
+				return err == nil
+			},
+			time.Second,
+			time.Millisecond*10,
+		)
+
+		responses := make(chan *http.Response, 1)
+		go func() {
+			response, err := http.Get(url)
+			assert.NoError(t, err)
+			responses <- response
+		}()
+
+		<-probe.started
+		stopped := make(chan struct{})
+		go func() {
+			controller.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			t.Fatal("controller was stopped before request completed")
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		close(probe.release)
+		response := <-responses
+		require.NotNil(t, response)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("controller was not stopped")
+		}
+
+		_, err := http.Get(url)
+		assert.Error(t, err)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
 	"github.com/DKhorkov/medods/internal/entities"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
+	notifiermocks "github.com/DKhorkov/medods/internal/mocks/notifiers"
 	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
 	"github.com/DKhorkov/medods/internal/security"
 	"github.com/DKhorkov/medods/internal/services"
@@ -58,7 +60,7 @@ func TestControllersHTTPTokensHandlerCreateTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logger,
 		}
 
@@ -118,14 +120,14 @@ func TestControllersHTTPTokensHandlerCreateTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logger,
 		}
 
 		request := httptest.NewRequest(
 			http.MethodPost,
 			"/tokens",
-			nil,
+			strings.NewReader("{}"),
 		)
 
 		writer := httptest.NewRecorder()
@@ -136,13 +138,54 @@ func TestControllersHTTPTokensHandlerCreateTokens(t *testing.T) {
 		defer result.Body.Close()
 
 		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
-		responseBodyData, err := io.ReadAll(result.Body)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "parameter_required", problem.Code)
+		assert.Equal(t, customerrors.ParameterRequiredError{Parameter: "GUID"}.Error(), problem.Detail)
+	})
+
+	t.Run("blocked user is forbidden", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: map[int]*entities.RefreshToken{}}
+		usersRepository := &mocks.MockedUsersRepository{
+			UsersStorage: map[string]*entities.User{
+				testsConfig.RefreshToken.GUID: {
+					GUID:   testsConfig.RefreshToken.GUID,
+					Status: entities.UserStatusBlocked,
+				},
+			},
+		}
+
+		authService := &services.CommonAuthService{AuthRepository: authRepository}
+		usersService := &services.CommonUsersService{UsersRepository: usersRepository}
+		logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+		useCases := &usecases.CommonUseCases{
+			AuthService:  authService,
+			UsersService: usersService,
+			HashCost:     testsConfig.HashCost,
+			JWTConfig:    testsConfig.JWT,
+			Notifier:     &notifiermocks.MockedNotifier{},
+			Logger:       logger,
+		}
+
+		body, err := json.Marshal(map[string]interface{}{"GUID": testsConfig.RefreshToken.GUID})
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		errorMessage := strings.Split(string(responseBodyData), "\n")[1]
-		assert.Equal(t, customerrors.ParameterRequiredError{Parameter: "GUID"}.Error(), errorMessage)
+		request := httptest.NewRequest(
+			http.MethodPost,
+			"/tokens",
+			strings.NewReader(string(body)),
+		)
+
+		writer := httptest.NewRecorder()
+		handleFunc := httpcontroller.TokensHandler{UseCases: useCases, Logger: logger}.GetHandleFunc()
+		handleFunc(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		assert.Empty(t, result.Header.Get("Authorization"))
 	})
 }
 
@@ -175,7 +218,7 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logger,
 		}
 
@@ -275,14 +318,10 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 		result := writer.Result()
 		defer result.Body.Close()
 
-		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
-		responseBodyData, err := io.ReadAll(result.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		errorMessage := strings.Split(string(responseBodyData), "\n")[1]
-		assert.Equal(t, customerrors.HeaderError{Header: "Authorization"}.Error(), errorMessage)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "invalid_header", problem.Code)
+		assert.Equal(t, customerrors.HeaderError{Header: "Authorization"}.Error(), problem.Detail)
 	})
 
 	t.Run("Authorization header Transport error", func(t *testing.T) {
@@ -318,14 +357,10 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 		result := writer.Result()
 		defer result.Body.Close()
 
-		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
-		responseBodyData, err := io.ReadAll(result.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		errorMessage := strings.Split(string(responseBodyData), "\n")[1]
-		assert.Equal(t, customerrors.HeaderError{Header: "Authorization"}.Error(), errorMessage)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "invalid_header", problem.Code)
+		assert.Equal(t, customerrors.HeaderError{Header: "Authorization"}.Error(), problem.Detail)
 	})
 
 	t.Run("refreshToken parameter required", func(t *testing.T) {
@@ -350,7 +385,7 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 		request := httptest.NewRequest(
 			http.MethodPut,
 			"/tokens",
-			nil,
+			strings.NewReader("{}"),
 		)
 
 		request.Header.Set("Authorization", "Bearer "+accessToken)
@@ -362,13 +397,9 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 		defer result.Body.Close()
 
 		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
-		responseBodyData, err := io.ReadAll(result.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		errorMessage := strings.Split(string(responseBodyData), "\n")[1]
-		assert.Equal(t, customerrors.ParameterRequiredError{Parameter: "refreshToken"}.Error(), errorMessage)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "parameter_required", problem.Code)
+		assert.Equal(t, customerrors.ParameterRequiredError{Parameter: "refreshToken"}.Error(), problem.Detail)
 	})
 
 	t.Run("refreshToken parameter required", func(t *testing.T) {
@@ -410,13 +441,126 @@ func TestControllersHTTPTokensHandlerRefreshTokens(t *testing.T) {
 		result := writer.Result()
 		defer result.Body.Close()
 
-		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
-		responseBodyData, err := io.ReadAll(result.Body)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "invalid_token", problem.Code)
+		assert.Equal(t, customerrors.InvalidJWTError{}.Error(), problem.Detail)
+	})
+}
+
+func TestControllersHTTPSessionsHandlerRevoke(t *testing.T) {
+	t.Run("successfully revoke session", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{
+			RefreshTokensStorage: map[int]*entities.RefreshToken{
+				1: {
+					ID:    1,
+					Value: testsConfig.RefreshToken.Value,
+					TTL:   time.Now().Add(time.Hour),
+					GUID:  testsConfig.RefreshToken.GUID,
+				},
+			},
+		}
+
+		logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+		useCases := &usecases.CommonUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			JWTConfig:   testsConfig.JWT,
+			Logger:      logger,
+		}
+
+		revokeToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.Emails.RevokeLinkTTL,
+				IP:        testsConfig.IP,
+				Value:     "revoke:1",
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handleFunc := httpcontroller.SessionsHandler{UseCases: useCases, Logger: logger}.GetRevokeHandleFunc()
+
+		// Following the link only asks to confirm revocation:
+		request := httptest.NewRequest(
+			http.MethodGet,
+			"/sessions/revoke?token="+revokeToken,
+			nil,
+		)
+
+		writer := httptest.NewRecorder()
+		handleFunc(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		body, err := io.ReadAll(result.Body)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		errorMessage := strings.Split(string(responseBodyData), "\n")[0]
-		assert.Equal(t, customerrors.InvalidJWTError{}.Error(), errorMessage)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", result.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), `<form method="post">`)
+		assert.Contains(t, string(body), revokeToken)
+		assert.Nil(t, authRepository.RefreshTokensStorage[1].DeletedAt)
+
+		request = httptest.NewRequest(
+			http.MethodPost,
+			"/sessions/revoke?token="+revokeToken,
+			strings.NewReader(url.Values{"token": {revokeToken}}.Encode()),
+		)
+
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		writer = httptest.NewRecorder()
+		handleFunc(writer, request)
+
+		result = writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.NotNil(t, authRepository.RefreshTokensStorage[1].DeletedAt)
+	})
+
+	t.Run("token parameter required", func(t *testing.T) {
+		logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+		useCases := &usecases.CommonUseCases{Logger: logger}
+
+		request := httptest.NewRequest(
+			http.MethodGet,
+			"/sessions/revoke",
+			nil,
+		)
+
+		writer := httptest.NewRecorder()
+		handleFunc := httpcontroller.SessionsHandler{UseCases: useCases, Logger: logger}.GetRevokeHandleFunc()
+		handleFunc(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "parameter_required", problem.Code)
+		assert.Equal(t, customerrors.ParameterRequiredError{Parameter: "token"}.Error(), problem.Detail)
 	})
 }
+
+func decodeProblem(t *testing.T, result *http.Response) httpcontroller.Problem {
+	t.Helper()
+
+	assert.Equal(t, httpcontroller.ProblemContentType, result.Header.Get("Content-Type"))
+
+	var problem httpcontroller.Problem
+	if err := json.NewDecoder(result.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, result.StatusCode, problem.Status)
+	assert.Equal(t, "urn:medods:problem:"+problem.Code, problem.Type)
+	return problem
+}
@@ -0,0 +1,82 @@
+package controllers__test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubHealthProbe entities.HealthStatus
+
+func (probe stubHealthProbe) Health() entities.HealthStatus {
+	return entities.HealthStatus(probe)
+}
+
+func TestControllersHTTPReadinessHandler(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+	testCases := []struct {
+		name               string
+		probes             map[string]interfaces.HealthProbe
+		expectedStatusCode int
+		expectedReady      bool
+		expectedCheck      string
+	}{
+		{
+			name: "ready, when every dependency is healthy",
+			probes: map[string]interfaces.HealthProbe{
+				"database": stubHealthProbe{Healthy: true, CheckedAt: time.Now()},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedReady:      true,
+			expectedCheck:      "healthy",
+		},
+		{
+			name: "not ready, when database is unhealthy",
+			probes: map[string]interfaces.HealthProbe{
+				"database": stubHealthProbe{Healthy: false, CheckedAt: time.Now(), Error: "connection refused"},
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedReady:      false,
+			expectedCheck:      "unhealthy",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			writer := httptest.NewRecorder()
+			httpcontroller.ReadinessHandler{Probes: testCase.probes, Logger: logger}.GetHandleFunc()(writer, request)
+
+			result := writer.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, testCase.expectedStatusCode, result.StatusCode)
+			assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+
+			var response struct {
+				Ready  bool              `json:"ready"`
+				Checks map[string]string `json:"checks"`
+			}
+
+			require.NoError(t, json.Unmarshal(body, &response))
+			assert.Equal(t, testCase.expectedReady, response.Ready)
+			assert.Equal(t, testCase.expectedCheck, response.Checks["database"])
+
+			// Errors of dependencies are only logged:
+			assert.NotContains(t, string(body), "connection refused")
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package controllers__test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(handler http.Handler, request *http.Request) *http.Response {
+	writer := httptest.NewRecorder()
+	handler.ServeHTTP(writer, request)
+	return writer.Result()
+}
+
+func TestControllersHTTPChain(t *testing.T) {
+	t.Run("the first middleware is the outermost one", func(t *testing.T) {
+		var order []string
+		middleware := func(name string) httpcontroller.Middleware {
+			return func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(
+					func(writer http.ResponseWriter, request *http.Request) {
+						order = append(order, name)
+						handler.ServeHTTP(writer, request)
+					},
+				)
+			}
+		}
+
+		handler := httpcontroller.Chain(
+			http.HandlerFunc(func(http.ResponseWriter, *http.Request) { order = append(order, "handler") }),
+			middleware("first"),
+			middleware("second"),
+		)
+
+		result := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		defer result.Body.Close()
+		assert.Equal(t, []string{"first", "second", "handler"}, order)
+	})
+}
+
+func TestControllersHTTPRequestID(t *testing.T) {
+	testCases := []struct {
+		name              string
+		requestID         string
+		expectedRequestID string
+	}{
+		{name: "request ID of client is propagated", requestID: "client-id.42", expectedRequestID: "client-id.42"},
+		{name: "missing request ID is generated", requestID: ""},
+		{name: "invalid request ID is replaced", requestID: "bad id\r\n"},
+		{name: "too long request ID is replaced", requestID: strings.Repeat("a", 129)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var handledRequestID string
+			handler := httpcontroller.RequestID()(
+				http.HandlerFunc(
+					func(_ http.ResponseWriter, request *http.Request) {
+						handledRequestID = httpcontroller.GetRequestID(request.Context())
+					},
+				),
+			)
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(httpcontroller.RequestIDHeader, testCase.requestID)
+			result := serve(handler, request)
+			defer result.Body.Close()
+
+			responseRequestID := result.Header.Get(httpcontroller.RequestIDHeader)
+			assert.Equal(t, handledRequestID, responseRequestID)
+			if testCase.expectedRequestID != "" {
+				assert.Equal(t, testCase.expectedRequestID, responseRequestID)
+			} else {
+				assert.Len(t, responseRequestID, 36)
+			}
+		})
+	}
+}
+
+func TestControllersHTTPAccessLog(t *testing.T) {
+	t.Run("request is logged with status and request ID", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buffer, nil))
+		handler := httpcontroller.Chain(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, _ *http.Request) {
+					writer.WriteHeader(http.StatusCreated)
+					_, _ = writer.Write([]byte("created"))
+				},
+			),
+			httpcontroller.RequestID(),
+			httpcontroller.AccessLog(logger),
+		)
+
+		request := httptest.NewRequest(http.MethodPost, "/tokens", nil)
+		request.Header.Set(httpcontroller.RequestIDHeader, "someRequestID")
+		result := serve(handler, request)
+		defer result.Body.Close()
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+		assert.Equal(t, "someRequestID", record["RequestID"])
+		assert.Equal(t, http.MethodPost, record["Method"])
+		assert.Equal(t, "/tokens", record["Path"])
+		assert.InDelta(t, http.StatusCreated, record["Status"], 0)
+		assert.InDelta(t, len("created"), record["Bytes"], 0)
+		assert.Contains(t, record, "Latency")
+	})
+}
+
+func TestControllersHTTPRecovery(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("panic is turned into problem 500", func(t *testing.T) {
+		handler := httpcontroller.Chain(
+			http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("malformed claim") }),
+			httpcontroller.RequestID(),
+			httpcontroller.Recovery(logger),
+		)
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set(httpcontroller.RequestIDHeader, "someRequestID")
+		result := serve(handler, request)
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+
+		problem := decodeProblem(t, result)
+		assert.Equal(t, "internal_error", problem.Code)
+		assert.Equal(t, "someRequestID", problem.RequestID)
+
+		// Panic value is logged, but not disclosed to client:
+		assert.Empty(t, problem.Detail)
+	})
+
+	t.Run("panic is logged by access log with status of recovery", func(t *testing.T) {
+		var buffer bytes.Buffer
+		handler := httpcontroller.Chain(
+			http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("malformed claim") }),
+			httpcontroller.RequestID(),
+			httpcontroller.Recovery(logger),
+			httpcontroller.AccessLog(slog.New(slog.NewJSONHandler(&buffer, nil))),
+		)
+
+		result := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+		assert.InDelta(t, http.StatusInternalServerError, record["Status"], 0)
+	})
+
+	t.Run("started response is aborted", func(t *testing.T) {
+		handler := httpcontroller.Recovery(logger)(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, _ *http.Request) {
+					_, _ = writer.Write([]byte("partial"))
+					panic("failure after write")
+				},
+			),
+		)
+
+		assert.PanicsWithValue(
+			t,
+			http.ErrAbortHandler,
+			func() { serve(handler, httptest.NewRequest(http.MethodGet, "/", nil)) },
+		)
+	})
+}
+
+func TestControllersHTTPSecurityHeaders(t *testing.T) {
+	t.Run("security headers are set", func(t *testing.T) {
+		handler := httpcontroller.SecurityHeaders()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		result := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		defer result.Body.Close()
+
+		assert.Equal(t, "nosniff", result.Header.Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", result.Header.Get("X-Frame-Options"))
+		assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+		assert.NotEmpty(t, result.Header.Get("Content-Security-Policy"))
+	})
+}
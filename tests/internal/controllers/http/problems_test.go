@@ -0,0 +1,270 @@
+package controllers__test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	httpcontroller "github.com/DKhorkov/medods/internal/controllers/http"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingUseCases fails every call with err.
+type failingUseCases struct {
+	err error
+}
+
+func (useCases failingUseCases) CreateTokens(context.Context, entities.CreateTokensDTO) (*entities.Tokens, error) {
+	return nil, useCases.err
+}
+
+func (useCases failingUseCases) RefreshTokens(context.Context, entities.RefreshTokensDTO) (*entities.Tokens, error) {
+	return nil, useCases.err
+}
+
+func (useCases failingUseCases) RevokeTokens(context.Context, string) error {
+	return useCases.err
+}
+
+func (useCases failingUseCases) ValidateAccessToken(context.Context, string) (string, error) {
+	return "", useCases.err
+}
+
+func TestControllersHTTPProblems(t *testing.T) {
+	createTokensBody := `{"GUID": "` + testsConfig.RefreshToken.GUID + `"}`
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+	testCases := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+	}{
+		{
+			name:       "invalid token",
+			err:        customerrors.InvalidJWTError{},
+			statusCode: http.StatusUnauthorized,
+			code:       "invalid_token",
+		},
+		{
+			name:       "token claims",
+			err:        customerrors.JWTClaimsError{},
+			statusCode: http.StatusUnauthorized,
+			code:       "invalid_token_claims",
+		},
+		{
+			name:       "session expired",
+			err:        customerrors.SessionExpiredError{},
+			statusCode: http.StatusUnauthorized,
+			code:       "session_expired",
+		},
+		{
+			name:       "IP address mismatch",
+			err:        customerrors.IPAddressDoesNotMatchWithTokensIPError{},
+			statusCode: http.StatusForbidden,
+			code:       "ip_address_mismatch",
+		},
+		{
+			name:       "disabled user",
+			err:        customerrors.UserDisabledError{Status: "blocked"},
+			statusCode: http.StatusForbidden,
+			code:       "user_disabled",
+		},
+		{
+			name:       "concurrent rotation",
+			err:        customerrors.RefreshTokenRotatedError{},
+			statusCode: http.StatusConflict,
+			code:       "refresh_token_rotated",
+		},
+		{
+			name:       "wrapped error",
+			err:        fmt.Errorf("creating tokens: %w", customerrors.UserLockedError{}),
+			statusCode: http.StatusForbidden,
+			code:       "user_locked",
+		},
+		{
+			name:       "database outage",
+			err:        customerrors.DatabaseUnavailableError{Attempts: 3, Err: errors.New("connection refused")},
+			statusCode: http.StatusServiceUnavailable,
+			code:       "database_unavailable",
+		},
+		{
+			name:       "rate limited",
+			err:        customerrors.TooManyRequestsError{Err: errors.New("sso is rate limiting")},
+			statusCode: http.StatusTooManyRequests,
+			code:       "too_many_requests",
+		},
+		{
+			name:       "request timed out",
+			err:        fmt.Errorf("getting user: %w", context.DeadlineExceeded),
+			statusCode: http.StatusGatewayTimeout,
+			code:       "request_timeout",
+		},
+		{
+			name:       "unsupported users source",
+			err:        customerrors.UnsupportedUsersSourceError{Source: "sso", Backend: "postgres"},
+			statusCode: http.StatusInternalServerError,
+			code:       "unsupported_users_source",
+		},
+		{
+			name:       "request cancelled by client",
+			err:        fmt.Errorf("refreshing tokens: %w", context.Canceled),
+			statusCode: 499,
+			code:       "request_cancelled",
+		},
+		{
+			name:       "unknown error",
+			err:        errors.New("pq: relation does not exist"),
+			statusCode: http.StatusInternalServerError,
+			code:       "internal_error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := httpcontroller.Chain(
+				http.HandlerFunc(
+					httpcontroller.TokensHandler{UseCases: failingUseCases{err: tc.err}, Logger: logger}.GetHandleFunc(),
+				),
+				httpcontroller.RequestID(),
+			)
+
+			request := httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(createTokensBody))
+			request.Header.Set(httpcontroller.RequestIDHeader, "someRequestID")
+
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, request)
+
+			result := writer.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tc.statusCode, result.StatusCode)
+
+			problem := decodeProblem(t, result)
+			assert.Equal(t, tc.code, problem.Code)
+			assert.Equal(t, "/tokens", problem.Instance)
+			assert.Equal(t, "someRequestID", problem.RequestID)
+			assert.NotEmpty(t, problem.Title)
+
+			// Details of server errors are not disclosed:
+			if tc.statusCode >= http.StatusInternalServerError {
+				assert.Empty(t, problem.Detail)
+			} else {
+				assert.Equal(t, tc.err.Error(), problem.Detail)
+			}
+		})
+	}
+
+	t.Run("error is logged with request ID", func(t *testing.T) {
+		var buffer bytes.Buffer
+		handler := httpcontroller.Chain(
+			http.HandlerFunc(
+				httpcontroller.TokensHandler{
+					UseCases: failingUseCases{err: customerrors.UserLockedError{}},
+					Logger:   slog.New(slog.NewJSONHandler(&buffer, nil)),
+				}.GetHandleFunc(),
+			),
+			httpcontroller.RequestID(),
+		)
+
+		request := httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(createTokensBody))
+		request.Header.Set(httpcontroller.RequestIDHeader, "someRequestID")
+
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, request)
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+		assert.Equal(t, "Creating tokens error", record["msg"])
+		assert.Equal(t, "someRequestID", record["RequestID"])
+	})
+
+	t.Run("malformed GUID", func(t *testing.T) {
+		testCases := []struct {
+			name        string
+			usersSource string
+			guid        string
+			statusCode  int
+		}{
+			{"UUID of database user", config.DatabaseUsersSource, testsConfig.RefreshToken.GUID, http.StatusForbidden},
+			{"not UUID of database user", config.DatabaseUsersSource, "42", http.StatusBadRequest},
+			{"ID of SSO user", config.SSOUsersSource, "42", http.StatusForbidden},
+			{"not ID of SSO user", config.SSOUsersSource, testsConfig.RefreshToken.GUID, http.StatusBadRequest},
+		}
+
+		for _, tc := range testCases {
+			body := strings.NewReader(`{"GUID": "` + tc.guid + `"}`)
+			request := httptest.NewRequest(http.MethodPost, "/tokens", body)
+			writer := httptest.NewRecorder()
+			httpcontroller.TokensHandler{
+				UseCases:    failingUseCases{err: customerrors.UserLockedError{}},
+				UsersSource: tc.usersSource,
+				Logger:      logger,
+			}.GetHandleFunc()(writer, request)
+
+			result := writer.Result()
+			assert.Equal(t, tc.statusCode, result.StatusCode, tc.name)
+			if tc.statusCode == http.StatusBadRequest {
+				assert.Equal(t, "invalid_parameter", decodeProblem(t, result).Code, tc.name)
+			}
+
+			_ = result.Body.Close()
+		}
+	})
+
+	t.Run("invalid request body", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader("{"))
+		writer := httptest.NewRecorder()
+		httpcontroller.TokensHandler{UseCases: failingUseCases{}, Logger: logger}.GetHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, "invalid_request_body", decodeProblem(t, result).Code)
+	})
+
+	t.Run("reused refresh token is reported as invalid token", func(t *testing.T) {
+		request := httptest.NewRequest(
+			http.MethodPut,
+			"/tokens",
+			strings.NewReader(`{"refreshToken": "c29tZVJlZnJlc2hUb2tlbg=="}`),
+		)
+
+		request.Header.Set("Authorization", "Bearer someAccessToken")
+		writer := httptest.NewRecorder()
+		httpcontroller.TokensHandler{
+			UseCases: failingUseCases{err: customerrors.RefreshTokenNotFoundError{}},
+			Logger:   logger,
+		}.GetHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.Equal(t, "invalid_token", decodeProblem(t, result).Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodDelete, "/tokens", nil)
+		writer := httptest.NewRecorder()
+		httpcontroller.TokensHandler{UseCases: failingUseCases{}, Logger: logger}.GetHandleFunc()(writer, request)
+
+		result := writer.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, result.StatusCode)
+		assert.Equal(t, "method_not_allowed", decodeProblem(t, result).Code)
+	})
+}
@@ -1,12 +1,24 @@
 package database__test
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
 	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/repositories"
 	testconfig "github.com/DKhorkov/medods/tests/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -76,6 +88,51 @@ func TestDatabaseGetTransaction(t *testing.T) {
 	})
 }
 
+func TestDatabaseBeginTransaction(t *testing.T) {
+	testsConfig := testconfig.New()
+
+	t.Run("successfully begin transaction", func(t *testing.T) {
+		connector := &database.CommonDBConnector{
+			DSN:    testsConfig.Database.DSN,
+			Driver: testsConfig.Database.Driver,
+		}
+
+		if err := connector.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		transaction, err := connector.BeginTransaction(context.Background(), &sql.TxOptions{ReadOnly: true})
+		require.NoError(t, err)
+		assert.NoError(t, transaction.Rollback())
+	})
+
+	t.Run("begin transaction with cancelled context", func(t *testing.T) {
+		connector := &database.CommonDBConnector{
+			DSN:    testsConfig.Database.DSN,
+			Driver: testsConfig.Database.Driver,
+		}
+
+		if err := connector.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		transaction, err := connector.BeginTransaction(ctx, nil)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, transaction)
+	})
+
+	t.Run("begin transaction on nil connection", func(t *testing.T) {
+		connector := &database.CommonDBConnector{}
+
+		transaction, err := connector.BeginTransaction(context.Background(), nil)
+		assert.IsType(t, customerrors.NilDBConnectionError{}, err)
+		assert.Nil(t, transaction)
+	})
+}
+
 func TestDatabaseGetConnection(t *testing.T) {
 	testsConfig := testconfig.New()
 
@@ -123,3 +180,205 @@ func TestDatabaseGetConnection(t *testing.T) {
 		assert.Nil(t, connection)
 	})
 }
+
+func TestDatabaseGetReplicaConnection(t *testing.T) {
+	t.Run("there are no replicas", func(t *testing.T) {
+		connector := &database.CommonDBConnector{}
+		assert.Nil(t, connector.GetReplicaConnection())
+	})
+
+	t.Run("replicas are returned in turn", func(t *testing.T) {
+		firstReplica, secondReplica := &sql.DB{}, &sql.DB{}
+		connector := &database.CommonDBConnector{Replicas: []*sql.DB{firstReplica, secondReplica}}
+
+		returned := map[*sql.DB]int{}
+		for range 4 {
+			returned[connector.GetReplicaConnection()]++
+		}
+
+		assert.Equal(t, map[*sql.DB]int{firstReplica: 2, secondReplica: 2}, returned)
+	})
+}
+
+func TestDatabaseNew(t *testing.T) {
+	t.Run("SQLite backend runs migrated service storage", func(t *testing.T) {
+		dbConnector, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend: config.SQLiteDatabaseBackend,
+				SQLite: config.DatabaseConfig{
+					DatabaseName: filepath.Join(t.TempDir(), "medods.db"),
+					Driver:       "sqlite3",
+				},
+			},
+			nil,
+		)
+
+		require.NoError(t, err)
+		defer dbConnector.CloseConnection()
+
+		migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+		require.NoError(t, err)
+
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+
+		authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		err = unitOfWork.Do(
+			context.Background(),
+			func(ctx context.Context) error {
+				_, err := authRepository.CreateRefreshToken(
+					ctx,
+					entities.CreateRefreshTokenDTO{
+						GUID:             "someGUID",
+						Value:            "someValue",
+						TTL:              time.Now().Add(time.Hour),
+						SessionStartedAt: time.Now(),
+					},
+				)
+
+				return err
+			},
+		)
+
+		require.NoError(t, err)
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, "someValue", refreshToken.Value)
+	})
+
+	t.Run("pool is configured", func(t *testing.T) {
+		dbConnector, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend: config.SQLiteDatabaseBackend,
+				SQLite: config.DatabaseConfig{
+					DatabaseName: filepath.Join(t.TempDir(), "medods.db"),
+					Driver:       "sqlite3",
+				},
+				Pool: config.DatabasePoolConfig{MaxOpenConnections: 3, MaxIdleConnections: 2},
+			},
+			nil,
+		)
+
+		require.NoError(t, err)
+		defer dbConnector.CloseConnection()
+		assert.Equal(t, 3, dbConnector.GetConnection().Stats().MaxOpenConnections)
+	})
+
+	t.Run("unavailable database is reported after retries", func(t *testing.T) {
+		testsConfig := testconfig.New()
+		dbConnector, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend: config.MySQLDatabaseBackend,
+				MySQL: config.DatabaseConfig{
+					Host:         "127.0.0.1",
+					Port:         1,
+					DatabaseName: "medods",
+					Driver:       "mysql",
+				},
+				Connect: config.DatabaseConnectConfig{
+					Timeout:     time.Second,
+					MaxAttempts: 3,
+					BaseBackoff: time.Millisecond * 10,
+				},
+			},
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		var databaseUnavailableError customerrors.DatabaseUnavailableError
+		require.ErrorAs(t, err, &databaseUnavailableError)
+		assert.Equal(t, 3, databaseUnavailableError.Attempts)
+		assert.Nil(t, dbConnector)
+	})
+
+	t.Run("rejected credentials are not retried", func(t *testing.T) {
+		testsConfig := testconfig.New()
+		opens := registerFailingDriver(t, &pq.Error{Code: "28P01", Message: "password authentication failed"})
+		dbConnector, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend:    config.PostgreSQLDatabaseBackend,
+				PostgreSQL: config.DatabaseConfig{Driver: t.Name()},
+				Connect: config.DatabaseConnectConfig{
+					Timeout:     time.Second,
+					MaxAttempts: 3,
+					BaseBackoff: time.Millisecond * 10,
+				},
+			},
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		var databaseUnavailableError customerrors.DatabaseUnavailableError
+		require.ErrorAs(t, err, &databaseUnavailableError)
+		assert.Equal(t, 1, databaseUnavailableError.Attempts)
+		assert.Equal(t, int32(1), opens.Load())
+		assert.Nil(t, dbConnector)
+	})
+
+	t.Run("waiting for database is interrupted by context", func(t *testing.T) {
+		testsConfig := testconfig.New()
+		registerFailingDriver(t, &mysql.MySQLError{Number: 2003, Message: "can not connect"})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		startedAt := time.Now()
+		dbConnector, err := database.New(
+			ctx,
+			config.DatabasesConfig{
+				Backend: config.MySQLDatabaseBackend,
+				MySQL:   config.DatabaseConfig{Driver: t.Name()},
+				Connect: config.DatabaseConnectConfig{
+					Timeout:     time.Second,
+					MaxAttempts: 3,
+					BaseBackoff: time.Hour,
+				},
+			},
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(startedAt), time.Second)
+		assert.Nil(t, dbConnector)
+	})
+
+	t.Run("unsupported backend", func(t *testing.T) {
+		dbConnector, err := database.New(context.Background(), config.DatabasesConfig{Backend: "oracle"}, nil)
+		assert.IsType(t, customerrors.UnsupportedDatabaseBackendError{}, err)
+		assert.EqualError(t, err, "database backend oracle is not supported")
+		assert.Nil(t, dbConnector)
+	})
+}
+
+// failingDriver fails to open every connection with err.
+type failingDriver struct {
+	err   atomic.Pointer[error]
+	opens atomic.Int32
+}
+
+func (failingDriver *failingDriver) Open(string) (driver.Conn, error) {
+	failingDriver.opens.Add(1)
+	return nil, *failingDriver.err.Load()
+}
+
+// failingDrivers holds drivers, registered by tests, since database/sql does not allow to register driver twice,
+// when test is run several times.
+var failingDrivers sync.Map
+
+// registerFailingDriver registers driver, named after test, which fails with err, and returns number of its opens.
+func registerFailingDriver(t *testing.T, err error) *atomic.Int32 {
+	t.Helper()
+
+	registeredDriver, registered := failingDrivers.LoadOrStore(t.Name(), &failingDriver{})
+	testDriver := registeredDriver.(*failingDriver)
+	if !registered {
+		sql.Register(t.Name(), testDriver)
+	}
+
+	testDriver.err.Store(&err)
+	testDriver.opens.Store(0)
+	return &testDriver.opens
+}
@@ -0,0 +1,114 @@
+package database__test
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path"
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/database/migrations"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDatabaseMigrator(t *testing.T) {
+	t.Run("apply embedded migrations", func(t *testing.T) {
+		// Separate in-memory database is used, so that other tests are not affected:
+		connection, err := sql.Open("sqlite3", "file:migrator?mode=memory&cache=shared")
+		require.NoError(t, err)
+		defer connection.Close()
+
+		migrationFiles, err := fs.Glob(migrations.FS, migrations.SQLiteDirectory+"/*.sql")
+		require.NoError(t, err)
+		require.NotEmpty(t, migrationFiles)
+
+		migrator, err := database.NewMigrator(connection, "sqlite3")
+		require.NoError(t, err)
+
+		applied, err := migrator.Up(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, len(migrationFiles), applied)
+
+		// Migrations are applied only once:
+		applied, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, applied)
+
+		statuses, err := migrator.Status(context.Background())
+		require.NoError(t, err)
+		require.Len(t, statuses, len(migrationFiles))
+		for _, status := range statuses {
+			assert.Equal(t, goose.StateApplied, status.State)
+		}
+
+		require.NoError(t, migrator.Down(context.Background()))
+		statuses, err = migrator.Status(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, goose.StatePending, statuses[len(statuses)-1].State)
+	})
+
+	t.Run("refresh tokens ids are generated by SQLite after rollback", func(t *testing.T) {
+		connection, err := sql.Open("sqlite3", "file:migrator_ids?mode=memory&cache=shared")
+		require.NoError(t, err)
+		defer connection.Close()
+
+		migrator, err := database.NewMigrator(connection, "sqlite3")
+		require.NoError(t, err)
+
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+
+		// Rebuilt table of the previous schema should keep id as alias of rowid:
+		for range 2 {
+			require.NoError(t, migrator.Down(context.Background()))
+		}
+
+		for _, value := range []string{"first", "second"} {
+			_, err = connection.Exec(
+				"INSERT INTO refresh_tokens (guid, value, ttl) VALUES ('guid', $1, CURRENT_TIMESTAMP)",
+				value,
+			)
+
+			require.NoError(t, err)
+		}
+
+		var ids []int
+		rows, err := connection.Query("SELECT id FROM refresh_tokens ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		for rows.Next() {
+			var id sql.NullInt64
+			require.NoError(t, rows.Scan(&id))
+			require.True(t, id.Valid)
+			ids = append(ids, int(id.Int64))
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []int{1, 2}, ids)
+	})
+
+	t.Run("every dialect has the same migrations", func(t *testing.T) {
+		postgresFiles, err := fs.Glob(migrations.FS, migrations.PostgresDirectory+"/*.sql")
+		require.NoError(t, err)
+
+		for _, directory := range []string{migrations.SQLiteDirectory, migrations.MySQLDirectory} {
+			files, err := fs.Glob(migrations.FS, directory+"/*.sql")
+			require.NoError(t, err)
+			require.Len(t, files, len(postgresFiles))
+			for i, file := range files {
+				assert.Equal(t, path.Base(postgresFiles[i]), path.Base(file))
+			}
+		}
+	})
+
+	t.Run("unsupported driver", func(t *testing.T) {
+		_, err := database.NewMigrator(nil, "oracle")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,96 @@
+package database__test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const namedLocksDriver = "sqlite3_named_locks"
+
+// namedLocks emulates MySQL named locks by GET_LOCK and RELEASE_LOCK functions, registered for SQLite connections.
+var namedLocks = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: make(map[string]bool)}
+
+func init() {
+	sql.Register(
+		namedLocksDriver,
+		&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				err := conn.RegisterFunc(
+					"GET_LOCK",
+					func(name string, _ int64) int64 {
+						namedLocks.Lock()
+						defer namedLocks.Unlock()
+
+						if namedLocks.held[name] {
+							return 0
+						}
+
+						namedLocks.held[name] = true
+						return 1
+					},
+					false,
+				)
+
+				if err != nil {
+					return err
+				}
+
+				return conn.RegisterFunc(
+					"RELEASE_LOCK",
+					func(name string) int64 {
+						namedLocks.Lock()
+						defer namedLocks.Unlock()
+
+						if !namedLocks.held[name] {
+							return 0
+						}
+
+						delete(namedLocks.held, name)
+						return 1
+					},
+					false,
+				)
+			},
+		},
+	)
+}
+
+func TestDatabaseMySQLSessionLocker(t *testing.T) {
+	connection, err := sql.Open(namedLocksDriver, ":memory:")
+	require.NoError(t, err)
+	defer connection.Close()
+
+	ctx := context.Background()
+	firstConn, err := connection.Conn(ctx)
+	require.NoError(t, err)
+	defer firstConn.Close()
+
+	secondConn, err := connection.Conn(ctx)
+	require.NoError(t, err)
+	defer secondConn.Close()
+
+	locker := database.NewMySQLSessionLocker()
+
+	t.Run("lock is obtained only once", func(t *testing.T) {
+		require.NoError(t, locker.SessionLock(ctx, firstConn))
+		assert.Error(t, locker.SessionLock(ctx, secondConn))
+	})
+
+	t.Run("released lock can be obtained again", func(t *testing.T) {
+		require.NoError(t, locker.SessionUnlock(ctx, firstConn))
+		assert.Error(t, locker.SessionUnlock(ctx, firstConn))
+
+		require.NoError(t, locker.SessionLock(ctx, secondConn))
+		require.NoError(t, locker.SessionUnlock(ctx, secondConn))
+	})
+}
@@ -0,0 +1,19 @@
+package database__test
+
+import (
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseRebind(t *testing.T) {
+	t.Run("numbered placeholders are replaced with positional ones", func(t *testing.T) {
+		query := database.Rebind("UPDATE t SET a = $1, b = $2 WHERE id = $10")
+		assert.Equal(t, "UPDATE t SET a = ?, b = ? WHERE id = ?", query)
+	})
+
+	t.Run("query without placeholders is not changed", func(t *testing.T) {
+		assert.Equal(t, "SELECT 1", database.Rebind("SELECT 1"))
+	})
+}
@@ -0,0 +1,100 @@
+package database__test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	testconfig "github.com/DKhorkov/medods/tests/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseMemorySnapshot(t *testing.T) {
+	testsConfig := testconfig.New()
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	openMemoryDatabase := func(t *testing.T, snapshotPath string) *database.CommonDBConnector {
+		t.Helper()
+
+		dbConnector, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend: config.MemoryDatabaseBackend,
+				Memory:  config.MemoryStoreConfig{SnapshotPath: snapshotPath},
+			},
+			logger,
+		)
+
+		require.NoError(t, err)
+		t.Cleanup(dbConnector.CloseConnection)
+
+		migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+		require.NoError(t, err)
+
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+		return dbConnector
+	}
+
+	t.Run("database is restored from saved snapshot", func(t *testing.T) {
+		snapshotPath := filepath.Join(t.TempDir(), "medods.snapshot")
+		dbConnector := openMemoryDatabase(t, snapshotPath)
+
+		_, err := dbConnector.GetConnection().Exec(
+			"INSERT INTO users (guid, email) VALUES ($1, $2)",
+			testsConfig.RefreshToken.GUID,
+			"user@example.com",
+		)
+
+		require.NoError(t, err)
+
+		// The second snapshot replaces the first one:
+		for range 2 {
+			require.NoError(t, database.SaveSnapshot(context.Background(), dbConnector, snapshotPath))
+		}
+
+		dbConnector.CloseConnection()
+
+		var email string
+		restoredDBConnector := openMemoryDatabase(t, snapshotPath)
+		err = restoredDBConnector.GetConnection().QueryRow(
+			"SELECT email FROM users WHERE guid = $1",
+			testsConfig.RefreshToken.GUID,
+		).Scan(&email)
+
+		require.NoError(t, err)
+		assert.Equal(t, "user@example.com", email)
+
+		_, err = os.Stat(snapshotPath + ".tmp")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("database is empty without snapshot", func(t *testing.T) {
+		dbConnector := openMemoryDatabase(t, filepath.Join(t.TempDir(), "medods.snapshot"))
+
+		var users int
+		require.NoError(t, dbConnector.GetConnection().QueryRow("SELECT COUNT(*) FROM users").Scan(&users))
+		assert.Zero(t, users)
+	})
+
+	t.Run("malformed snapshot is not restored", func(t *testing.T) {
+		snapshotPath := filepath.Join(t.TempDir(), "medods.snapshot")
+		require.NoError(t, os.WriteFile(snapshotPath, []byte("not a database"), 0o600))
+
+		_, err := database.New(
+			context.Background(),
+			config.DatabasesConfig{
+				Backend: config.MemoryDatabaseBackend,
+				Memory:  config.MemoryStoreConfig{SnapshotPath: snapshotPath},
+			},
+			logger,
+		)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,195 @@
+package database__test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDatabaseUnitOfWork(t *testing.T) {
+	createRefreshToken := func(ctx context.Context, authRepository *repositories.CommonAuthRepository) error {
+		_, err := authRepository.CreateRefreshToken(
+			ctx,
+			entities.CreateRefreshTokenDTO{
+				GUID:             "someGUID",
+				Value:            "someValue",
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
+		)
+
+		return err
+	}
+
+	countRefreshTokens := func(t *testing.T, dbConnector *database.CommonDBConnector) int {
+		var count int
+		err := dbConnector.Connection.QueryRow(`SELECT COUNT(*) FROM refresh_tokens`).Scan(&count)
+		require.NoError(t, err)
+		return count
+	}
+
+	t.Run("work is committed", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+
+		err := unitOfWork.Do(
+			context.Background(),
+			func(ctx context.Context) error {
+				return createRefreshToken(ctx, authRepository)
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, countRefreshTokens(t, dbConnector))
+	})
+
+	t.Run("work of several repositories is rolled back on error", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+		outboxRepository := &repositories.CommonOutboxRepository{DBConnector: dbConnector}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		workErr := errors.New("work failed")
+
+		err := unitOfWork.Do(
+			context.Background(),
+			func(ctx context.Context) error {
+				if err := createRefreshToken(ctx, authRepository); err != nil {
+					return err
+				}
+
+				_, err := outboxRepository.CreateOutboxMessage(
+					ctx,
+					entities.Notification{Recipients: []string{"example@yandex.ru"}, Subject: "Subject", Body: "Body"},
+				)
+
+				if err != nil {
+					return err
+				}
+
+				return workErr
+			},
+		)
+
+		require.ErrorIs(t, err, workErr)
+		assert.Equal(t, 0, countRefreshTokens(t, dbConnector))
+
+		var outboxMessagesCount int
+		err = connection.QueryRow(`SELECT COUNT(*) FROM notifications_outbox`).Scan(&outboxMessagesCount)
+		require.NoError(t, err)
+		assert.Equal(t, 0, outboxMessagesCount)
+	})
+
+	t.Run("nested work participates in outer transaction", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		workErr := errors.New("work failed")
+
+		err := unitOfWork.Do(
+			context.Background(),
+			func(ctx context.Context) error {
+				err := unitOfWork.Do(
+					ctx,
+					func(ctx context.Context) error {
+						return createRefreshToken(ctx, authRepository)
+					},
+				)
+
+				if err != nil {
+					return err
+				}
+
+				return workErr
+			},
+		)
+
+		require.ErrorIs(t, err, workErr)
+		assert.Equal(t, 0, countRefreshTokens(t, dbConnector))
+	})
+
+	t.Run("work is not run with cancelled context", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var called bool
+		err := unitOfWork.Do(
+			ctx,
+			func(ctx context.Context) error {
+				called = true
+				return nil
+			},
+		)
+
+		require.ErrorIs(t, err, context.Canceled)
+		assert.False(t, called)
+	})
+
+	t.Run("work is rolled back, when context is cancelled before commit", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+
+		// Connection of cancelled transaction is discarded, so that another one keeps in-memory database alive:
+		observer, err := connection.Conn(context.Background())
+		require.NoError(t, err)
+		defer observer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		err = unitOfWork.Do(
+			ctx,
+			func(ctx context.Context) error {
+				if err := createRefreshToken(ctx, authRepository); err != nil {
+					return err
+				}
+
+				cancel()
+				return nil
+			},
+		)
+
+		require.ErrorIs(t, err, context.Canceled)
+
+		// Transaction is rolled back by database/sql in background, once its context is done:
+		require.Eventually(
+			t,
+			func() bool {
+				var count int
+				err := observer.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM refresh_tokens`).Scan(&count)
+				return err == nil && count == 0
+			},
+			time.Second,
+			time.Millisecond*10,
+		)
+	})
+}
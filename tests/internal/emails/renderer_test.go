@@ -0,0 +1,104 @@
+package emails__test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/emails"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testData = entities.SuspiciousIPEmailData{
+	Time:       time.Date(2024, time.October, 10, 12, 30, 0, 0, time.UTC),
+	IP:         "192.168.0.1",
+	Location:   "Moscow, RU",
+	Device:     "Mozilla/5.0 <script>alert(1)</script>",
+	RevokeLink: "http://localhost:8070/sessions/revoke?token=testToken",
+}
+
+func TestEmailsRendererRender(t *testing.T) {
+	t.Run("render russian email", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.EnglishLocale)
+		email, err := renderer.Render(emails.SuspiciousIPTemplate, emails.RussianLocale, testData)
+		require.NoError(t, err)
+		assert.Equal(t, emails.RussianLocale, email.Locale)
+		assert.Equal(t, "MEDODS: подозрительная попытка обновить вашу сессию", email.Subject)
+		assert.Contains(t, email.Text, "IP-адрес: 192.168.0.1")
+		assert.Contains(t, email.Text, "Местоположение: Moscow, RU")
+		assert.Contains(t, email.Text, "10.10.2024 12:30 UTC")
+		assert.Contains(t, email.Text, testData.RevokeLink)
+		assert.Contains(t, email.HTML, `<html lang="ru">`)
+		assert.Contains(t, email.HTML, "Это был не я")
+	})
+
+	t.Run("render english email", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.RussianLocale)
+		email, err := renderer.Render(emails.SuspiciousIPTemplate, "EN", testData)
+		require.NoError(t, err)
+		assert.Equal(t, emails.EnglishLocale, email.Locale)
+		assert.Equal(t, "MEDODS: suspicious attempt to refresh your session", email.Subject)
+		assert.Contains(t, email.Text, "IP address: 192.168.0.1")
+		assert.Contains(t, email.HTML, "This wasn't me")
+	})
+
+	t.Run("render sessions revoked email", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.RussianLocale)
+		for _, locale := range []string{emails.EnglishLocale, emails.RussianLocale} {
+			email, err := renderer.Render(
+				emails.SessionsRevokedTemplate,
+				locale,
+				entities.SessionsRevokedEmailData{Time: testData.Time, IP: testData.IP, Device: testData.Device},
+			)
+
+			require.NoError(t, err)
+			assert.Equal(t, locale, email.Locale)
+			assert.Contains(t, email.Text, "192.168.0.1")
+			assert.NotContains(t, email.HTML, "<script>")
+		}
+	})
+
+	t.Run("unknown locale falls back to default one", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.EnglishLocale)
+		email, err := renderer.Render(emails.SuspiciousIPTemplate, "de", testData)
+		require.NoError(t, err)
+		assert.Equal(t, emails.EnglishLocale, email.Locale)
+	})
+
+	t.Run("user controlled data is escaped in HTML", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.EnglishLocale)
+		email, err := renderer.Render(emails.SuspiciousIPTemplate, emails.EnglishLocale, testData)
+		require.NoError(t, err)
+		assert.NotContains(t, email.HTML, "<script>")
+		assert.Contains(t, email.HTML, "&lt;script&gt;")
+	})
+
+	t.Run("template is overridden from directory", func(t *testing.T) {
+		templatesDirectory := t.TempDir()
+		err := os.WriteFile(
+			filepath.Join(templatesDirectory, "suspicious_ip.en.subject.tmpl"),
+			[]byte("Custom subject for {{ .IP }}"),
+			0600,
+		)
+
+		require.NoError(t, err)
+
+		renderer := emails.NewRenderer(templatesDirectory, emails.EnglishLocale)
+		email, err := renderer.Render(emails.SuspiciousIPTemplate, emails.EnglishLocale, testData)
+		require.NoError(t, err)
+		assert.Equal(t, "Custom subject for 192.168.0.1", email.Subject)
+
+		// Not overridden templates are still embedded ones:
+		assert.Contains(t, email.Text, "IP address: 192.168.0.1")
+	})
+
+	t.Run("render unknown template", func(t *testing.T) {
+		renderer := emails.NewRenderer("", emails.EnglishLocale)
+		email, err := renderer.Render("unknown", emails.EnglishLocale, testData)
+		require.Error(t, err)
+		assert.Nil(t, email)
+	})
+}
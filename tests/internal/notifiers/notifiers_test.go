@@ -0,0 +1,136 @@
+package notifiers__test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	mocks "github.com/DKhorkov/medods/internal/mocks/notifiers"
+	"github.com/DKhorkov/medods/internal/notifiers"
+	"github.com/DKhorkov/medods/internal/requestid"
+	testconfig "github.com/DKhorkov/medods/tests/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testsConfig = testconfig.New()
+
+var testNotification = entities.Notification{
+	Recipients: []string{"example@yandex.ru"},
+	Subject:    "Test subject",
+	Body:       "Test body",
+}
+
+func TestNotifiersFanOutNotifier(t *testing.T) {
+	t.Run("notify through all notifiers", func(t *testing.T) {
+		firstNotifier := &mocks.MockedNotifier{}
+		secondNotifier := &mocks.MockedNotifier{}
+		notifier := &notifiers.FanOutNotifier{
+			Notifiers: []interfaces.Notifier{firstNotifier, secondNotifier},
+		}
+
+		err := notifier.Notify(context.Background(), testNotification)
+		require.NoError(t, err)
+		assert.Equal(t, []entities.Notification{testNotification}, firstNotifier.Notifications())
+		assert.Equal(t, []entities.Notification{testNotification}, secondNotifier.Notifications())
+	})
+
+	t.Run("failed notifier does not prevent notification through others", func(t *testing.T) {
+		notifierError := errors.New("notifier error")
+		failedNotifier := &mocks.MockedNotifier{Err: notifierError}
+		successfulNotifier := &mocks.MockedNotifier{}
+		notifier := &notifiers.FanOutNotifier{
+			Notifiers: []interfaces.Notifier{failedNotifier, successfulNotifier},
+		}
+
+		err := notifier.Notify(context.Background(), testNotification)
+		require.ErrorIs(t, err, notifierError)
+		assert.Empty(t, failedNotifier.Notifications())
+		assert.Equal(t, []entities.Notification{testNotification}, successfulNotifier.Notifications())
+	})
+}
+
+func TestNotifiersWebhookNotifier(t *testing.T) {
+	t.Run("successfully notify", func(t *testing.T) {
+		var received entities.Notification
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					assert.Equal(t, http.MethodPost, request.Method)
+					assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+					assert.Equal(t, "someRequestID", request.Header.Get(requestid.Header))
+					assert.NoError(t, json.NewDecoder(request.Body).Decode(&received))
+					writer.WriteHeader(http.StatusNoContent)
+				},
+			),
+		)
+
+		defer server.Close()
+
+		notifier := &notifiers.WebhookNotifier{
+			WebhookConfig: config.WebhookConfig{URL: server.URL, Timeout: time.Second},
+		}
+
+		err := notifier.Notify(requestid.NewContext(context.Background(), "someRequestID"), testNotification)
+		require.NoError(t, err)
+		assert.Equal(t, testNotification, received)
+	})
+
+	t.Run("webhook responds with error", func(t *testing.T) {
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusInternalServerError)
+				},
+			),
+		)
+
+		defer server.Close()
+
+		notifier := &notifiers.WebhookNotifier{
+			WebhookConfig: config.WebhookConfig{URL: server.URL, Timeout: time.Second},
+		}
+
+		err := notifier.Notify(context.Background(), testNotification)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.WebhookDeliveryError{}, err)
+	})
+}
+
+func TestNotifiersNew(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("create notifier with all channels", func(t *testing.T) {
+		notifier, err := notifiers.New(
+			config.NotificationsConfig{
+				Channels: []string{notifiers.SMTPChannel, notifiers.WebhookChannel, notifiers.LogChannel},
+			},
+			testsConfig.SMTP,
+			logger,
+		)
+
+		require.NoError(t, err)
+		assert.Len(t, notifier.Notifiers, 3)
+	})
+
+	t.Run("create notifier with unknown channel", func(t *testing.T) {
+		notifier, err := notifiers.New(
+			config.NotificationsConfig{Channels: []string{"pigeon"}},
+			testsConfig.SMTP,
+			logger,
+		)
+
+		require.Error(t, err)
+		assert.IsType(t, customerrors.UnknownNotificationChannelError{}, err)
+		assert.Nil(t, notifier)
+	})
+}
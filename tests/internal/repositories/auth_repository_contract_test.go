@@ -0,0 +1,502 @@
+package repositories__test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/interfaces"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// contractBackend is a database, which AuthRepository implementation should satisfy the contract on. Backends
+// with DSN in environment variable are skipped, if variable is not set, unless TEST_REQUIRE_ALL_BACKENDS is "true",
+// as in CI, where skipped backend fails the tests. MySQL DSN should contain
+// "parseTime=true&loc=UTC". Cached backends are wrapped with Redis cache, which is emulated by miniredis.
+type contractBackend struct {
+	backend string
+	driver  string
+	dsn     string
+	dsnEnv  string
+	cached  bool
+}
+
+var contractBackends = []contractBackend{
+	{backend: config.MemoryDatabaseBackend},
+	{backend: config.SQLiteDatabaseBackend, driver: "sqlite3", dsn: "file:contract?mode=memory&cache=shared"},
+	{
+		backend: config.SQLiteDatabaseBackend,
+		driver:  "sqlite3",
+		dsn:     "file:contract_cached?mode=memory&cache=shared",
+		cached:  true,
+	},
+	{backend: config.PostgreSQLDatabaseBackend, driver: "postgres", dsnEnv: "TEST_POSTGRES_DSN"},
+	{backend: config.MySQLDatabaseBackend, driver: "mysql", dsnEnv: "TEST_MYSQL_DSN"},
+}
+
+// GUIDs are valid UUIDs, because PostgreSQL stores them as UUID. Numeric GUID is ID of SSO user, which is
+// rejected by PostgreSQL:
+const (
+	contractGUID        = "4f1c2b8e-6a0d-4c3e-9b7a-2d5e8f1a3c60"
+	contractOtherGUID   = "9d2e4a61-3b7c-4f08-a5d1-6c8b0e2f4a17"
+	contractNumericGUID = "42"
+)
+
+// startContractBackend migrates database of backend and returns repository over it. Tables are cleaned
+// after every test, so that tests are independent. In-memory database of memory backend is opened for every test.
+func startContractBackend(
+	t *testing.T,
+	backend contractBackend,
+) (interfaces.AuthRepository, *database.CommonDBConnector) {
+	t.Helper()
+
+	if backend.backend == config.MemoryDatabaseBackend {
+		dbConnector := testlifespan.StartUpMemory(t)
+		authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+			config.DatabasesConfig{Backend: backend.backend},
+			config.CacheConfig{},
+			dbConnector,
+			nil,
+		)
+
+		require.NoError(t, err)
+		t.Cleanup(closeAuthRepository)
+		return authRepository, dbConnector
+	}
+
+	dsn := backend.dsn
+	if backend.dsnEnv != "" {
+		if dsn = os.Getenv(backend.dsnEnv); dsn == "" && os.Getenv("TEST_REQUIRE_ALL_BACKENDS") == "true" {
+			t.Fatalf("%s is not set, while all backends are required", backend.dsnEnv)
+		} else if dsn == "" {
+			t.Skipf("%s is not set", backend.dsnEnv)
+		}
+	}
+
+	connection, err := sql.Open(backend.driver, dsn)
+	require.NoError(t, err)
+
+	migrator, err := database.NewMigrator(connection, backend.driver)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	t.Cleanup(
+		func() {
+			for _, table := range []string{"refresh_tokens", "access_tokens_denylist", "users"} {
+				_, err := connection.Exec("DELETE FROM " + table)
+				assert.NoError(t, err)
+			}
+
+			_ = connection.Close()
+		},
+	)
+
+	var cacheConfig config.CacheConfig
+	if backend.cached {
+		cacheConfig = config.CacheConfig{
+			Address: miniredis.RunT(t).Addr(),
+			TTL:     time.Minute,
+			Timeout: time.Second,
+		}
+	}
+
+	dbConnector := &database.CommonDBConnector{Connection: connection, Driver: backend.driver}
+	authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+		config.DatabasesConfig{Backend: backend.backend},
+		cacheConfig,
+		dbConnector,
+		nil,
+	)
+
+	require.NoError(t, err)
+	t.Cleanup(closeAuthRepository)
+	return authRepository, dbConnector
+}
+
+func createContractRefreshToken(
+	t *testing.T,
+	authRepository interfaces.AuthRepository,
+	guid, ip string,
+	ttl time.Time,
+) int {
+	t.Helper()
+
+	id, err := authRepository.CreateRefreshToken(
+		context.Background(),
+		entities.CreateRefreshTokenDTO{
+			GUID:             guid,
+			Value:            "someValue",
+			TTL:              ttl,
+			SessionStartedAt: time.Now().Add(-time.Hour),
+			IP:               ip,
+		},
+	)
+
+	require.NoError(t, err)
+	return id
+}
+
+func createContractUser(t *testing.T, dbConnector *database.CommonDBConnector, guid, status string) {
+	t.Helper()
+
+	now := time.Now().UTC()
+	_, err := database.GetExecutor(context.Background(), dbConnector).ExecContext(
+		context.Background(),
+		`
+			INSERT INTO users (guid, email, status, locale, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		guid,
+		guid+"@example.com",
+		status,
+		"ru",
+		now,
+		now,
+	)
+
+	require.NoError(t, err)
+}
+
+// rotateContractRefreshToken deletes refresh token with provided ID and creates a new one for the same user, since
+// only one refresh token of user may be active.
+func rotateContractRefreshToken(
+	t *testing.T,
+	authRepository interfaces.AuthRepository,
+	id int,
+	guid, ip string,
+	ttl time.Time,
+) int {
+	t.Helper()
+
+	refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), id)
+	require.NoError(t, err)
+	require.NoError(t, authRepository.DeleteRefreshToken(context.Background(), refreshToken))
+
+	return createContractRefreshToken(t, authRepository, guid, ip, ttl)
+}
+
+func TestRepositoriesAuthRepositoryContract(t *testing.T) {
+	for _, backend := range contractBackends {
+		name := backend.backend
+		if backend.cached {
+			name += "_cached"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			runAuthRepositoryContract(t, backend)
+		})
+	}
+}
+
+func runAuthRepositoryContract(t *testing.T, backend contractBackend) {
+	ctx := context.Background()
+
+	t.Run("created refresh token is found by ID and GUID", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		ttl := time.Now().Add(time.Hour)
+		sessionStartedAt := time.Now().Add(-time.Hour)
+		id, err := authRepository.CreateRefreshToken(
+			ctx,
+			entities.CreateRefreshTokenDTO{
+				GUID:             contractGUID,
+				Value:            "someValue",
+				TTL:              ttl,
+				SessionStartedAt: sessionStartedAt,
+				IP:               "127.0.0.1",
+			},
+		)
+
+		require.NoError(t, err)
+
+		refreshToken, err := authRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, refreshToken.ID)
+		assert.Equal(t, contractGUID, refreshToken.GUID)
+		assert.Equal(t, "someValue", refreshToken.Value)
+		assert.Equal(t, "127.0.0.1", refreshToken.IP)
+		assert.WithinDuration(t, ttl, refreshToken.TTL, time.Millisecond)
+		assert.WithinDuration(t, sessionStartedAt, refreshToken.SessionStartedAt, time.Millisecond)
+		assert.Nil(t, refreshToken.DeletedAt)
+
+		refreshToken, err = authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, id, refreshToken.ID)
+	})
+
+	t.Run("numeric GUID is stored unless GUIDs are UUIDs", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		_, err := authRepository.CreateRefreshToken(
+			ctx,
+			entities.CreateRefreshTokenDTO{
+				GUID:             contractNumericGUID,
+				Value:            "someValue",
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
+		)
+
+		if backend.backend == config.PostgreSQLDatabaseBackend {
+			assert.Error(t, err)
+			return
+		}
+
+		require.NoError(t, err)
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(ctx, contractNumericGUID)
+		require.NoError(t, err)
+		assert.Equal(t, contractNumericGUID, refreshToken.GUID)
+	})
+
+	t.Run("the newest refresh token is found by GUID", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		firstID := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+		secondID := rotateContractRefreshToken(t, authRepository, firstID, contractGUID, "", time.Now().Add(time.Hour))
+		assert.Greater(t, secondID, firstID)
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, secondID, refreshToken.ID)
+	})
+
+	t.Run("second active refresh token of user is rejected", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+		_, err := authRepository.CreateRefreshToken(
+			ctx,
+			entities.CreateRefreshTokenDTO{
+				GUID:             contractGUID,
+				Value:            "someValue",
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
+		)
+
+		assert.IsType(t, customerrors.RefreshTokenRotatedError{}, err)
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, id, refreshToken.ID)
+
+		// Other users are not affected:
+		createContractRefreshToken(t, authRepository, contractOtherGUID, "", time.Now().Add(time.Hour))
+	})
+
+	t.Run("non existing and expired refresh tokens are not found", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		_, err := authRepository.GetRefreshTokenByID(ctx, 1)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Millisecond*200))
+		time.Sleep(time.Millisecond * 300)
+
+		_, err = authRepository.GetRefreshTokenByID(ctx, id)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+
+	t.Run("refresh token is deleted only once", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+		refreshToken, err := authRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+
+		require.NoError(t, authRepository.DeleteRefreshToken(ctx, refreshToken))
+		err = authRepository.DeleteRefreshToken(ctx, refreshToken)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = authRepository.GetRefreshTokenByID(ctx, id)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		refreshToken, err = authRepository.GetRefreshTokenByIDIncludingDeleted(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, refreshToken.ID)
+		assert.NotNil(t, refreshToken.DeletedAt)
+
+		_, err = authRepository.GetRefreshTokenByIDIncludingDeleted(ctx, id+1)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+
+	t.Run("refresh tokens are listed from the newest and revoked by GUID", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		ids := []int{createContractRefreshToken(t, authRepository, contractGUID, "10.0.0.1", time.Now().Add(time.Hour))}
+		for range 2 {
+			id := rotateContractRefreshToken(
+				t,
+				authRepository,
+				ids[len(ids)-1],
+				contractGUID,
+				"10.0.0.1",
+				time.Now().Add(time.Hour),
+			)
+
+			ids = append(ids, id)
+		}
+
+		createContractRefreshToken(t, authRepository, contractOtherGUID, "10.0.0.2", time.Now().Add(time.Hour))
+
+		refreshTokens, err := authRepository.GetRefreshTokensByGUID(
+			ctx,
+			contractGUID,
+			entities.Pagination{Limit: 2, Offset: 1},
+		)
+
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 2)
+		assert.Equal(t, ids[1], refreshTokens[0].ID)
+		assert.Equal(t, ids[0], refreshTokens[1].ID)
+
+		refreshTokens, err = authRepository.GetRefreshTokensByIP(ctx, "10.0.0.2", entities.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 1)
+		assert.Equal(t, contractOtherGUID, refreshTokens[0].GUID)
+
+		// Only the active refresh token is revoked, the rotated ones have been deleted before:
+		revoked, err := authRepository.DeleteRefreshTokensByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, revoked)
+
+		revoked, err = authRepository.DeleteRefreshTokensByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, revoked)
+
+		refreshTokens, err = authRepository.GetRefreshTokensByGUID(ctx, contractGUID, entities.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 3)
+		assert.NotNil(t, refreshTokens[0].DeletedAt)
+	})
+
+	t.Run("refresh tokens of disabled users are deleted", func(t *testing.T) {
+		authRepository, dbConnector := startContractBackend(t, backend)
+
+		createContractUser(t, dbConnector, contractGUID, entities.UserStatusBlocked)
+		createContractUser(t, dbConnector, contractOtherGUID, entities.UserStatusActive)
+		createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+		createContractRefreshToken(t, authRepository, contractOtherGUID, "", time.Now().Add(time.Hour))
+
+		guids, err := authRepository.DeleteRefreshTokensOfDisabledUsers(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{contractGUID}, guids)
+
+		_, err = authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = authRepository.GetRefreshTokenByGUID(ctx, contractOtherGUID)
+		assert.NoError(t, err)
+
+		// Users, which sessions have been already revoked, are not touched again:
+		guids, err = authRepository.DeleteRefreshTokensOfDisabledUsers(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, guids)
+	})
+
+	t.Run("access tokens denial is replaced and expires", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		deniedBefore, err := authRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.IsZero())
+
+		firstDeniedBefore := time.Now().Add(-time.Minute)
+		err = authRepository.DenyAccessTokens(ctx, contractGUID, firstDeniedBefore, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		secondDeniedBefore := time.Now()
+		err = authRepository.DenyAccessTokens(ctx, contractGUID, secondDeniedBefore, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		deniedBefore, err = authRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, secondDeniedBefore, deniedBefore, time.Millisecond)
+
+		err = authRepository.DenyAccessTokens(ctx, contractOtherGUID, time.Now(), time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		deniedBefore, err = authRepository.GetAccessTokensDeniedBefore(ctx, contractOtherGUID)
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.IsZero())
+
+		purged, err := authRepository.PurgeAccessTokensDenylist(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+	})
+
+	t.Run("expired and revoked refresh tokens are purged in batches", func(t *testing.T) {
+		authRepository, _ := startContractBackend(t, backend)
+
+		for range 3 {
+			id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+			require.NoError(t, authRepository.DeleteRefreshToken(ctx, &entities.RefreshToken{ID: id}))
+		}
+
+		activeID := createContractRefreshToken(t, authRepository, contractOtherGUID, "", time.Now().Add(time.Hour))
+
+		purged, err := authRepository.PurgeRefreshTokens(ctx, time.Now(), time.Now().Add(time.Minute), 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged)
+
+		purged, err = authRepository.PurgeRefreshTokens(ctx, time.Now(), time.Now().Add(time.Minute), 2)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = authRepository.GetRefreshTokenByID(ctx, activeID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("changes are rolled back with unit of work", func(t *testing.T) {
+		authRepository, dbConnector := startContractBackend(t, backend)
+
+		workErr := errors.New("work failed")
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		err := unitOfWork.Do(
+			ctx,
+			func(ctx context.Context) error {
+				_, err := authRepository.CreateRefreshToken(
+					ctx,
+					entities.CreateRefreshTokenDTO{
+						GUID:             contractGUID,
+						Value:            "someValue",
+						TTL:              time.Now().Add(time.Hour),
+						SessionStartedAt: time.Now(),
+					},
+				)
+
+				if err != nil {
+					return err
+				}
+
+				return workErr
+			},
+		)
+
+		require.ErrorIs(t, err, workErr)
+
+		_, err = authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+}
@@ -0,0 +1,109 @@
+package repositories__test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openMigratedSQLite(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	connection, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = connection.Close() })
+
+	migrator, err := database.NewMigrator(connection, "sqlite3")
+	require.NoError(t, err)
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return connection
+}
+
+func TestRepositoriesAuthRepositoryReplicas(t *testing.T) {
+	ctx := context.Background()
+	newAuthRepository := func(t *testing.T) (*repositories.CommonAuthRepository, *database.CommonDBConnector) {
+		dbConnector := &database.CommonDBConnector{
+			Connection: openMigratedSQLite(t, "file:replicas_primary?mode=memory&cache=shared"),
+			Replicas:   []*sql.DB{openMigratedSQLite(t, "file:replicas_replica?mode=memory&cache=shared")},
+			Driver:     "sqlite3",
+		}
+
+		return &repositories.CommonAuthRepository{DBConnector: dbConnector}, dbConnector
+	}
+
+	// Databases are not replicated, so that it is visible, which of them is read:
+	createRefreshToken := func(t *testing.T, connection *sql.DB, value string) int {
+		t.Helper()
+
+		repo := &repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{Connection: connection, Driver: "sqlite3"},
+		}
+
+		id, err := repo.CreateRefreshToken(
+			ctx,
+			entities.CreateRefreshTokenDTO{GUID: contractGUID, Value: value, TTL: time.Now().Add(time.Hour)},
+		)
+
+		require.NoError(t, err)
+		return id
+	}
+
+	t.Run("refresh tokens are read from replica", func(t *testing.T) {
+		authRepository, dbConnector := newAuthRepository(t)
+		createRefreshToken(t, dbConnector.Connection, "primaryValue")
+		id := createRefreshToken(t, dbConnector.Replicas[0], "replicaValue")
+
+		refreshToken, err := authRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "replicaValue", refreshToken.Value)
+
+		refreshToken, err = authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, "replicaValue", refreshToken.Value)
+	})
+
+	t.Run("primary is read, when refresh token has not been replicated yet", func(t *testing.T) {
+		authRepository, dbConnector := newAuthRepository(t)
+		id := createRefreshToken(t, dbConnector.Connection, "primaryValue")
+
+		refreshToken, err := authRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "primaryValue", refreshToken.Value)
+	})
+
+	t.Run("primary is read, when replica is unavailable", func(t *testing.T) {
+		authRepository, dbConnector := newAuthRepository(t)
+		id := createRefreshToken(t, dbConnector.Connection, "primaryValue")
+		require.NoError(t, dbConnector.Replicas[0].Close())
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, id, refreshToken.ID)
+	})
+
+	t.Run("primary is read in transaction", func(t *testing.T) {
+		authRepository, dbConnector := newAuthRepository(t)
+		id := createRefreshToken(t, dbConnector.Replicas[0], "replicaValue")
+
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		err := unitOfWork.Do(
+			ctx,
+			func(ctx context.Context) error {
+				_, err := authRepository.GetRefreshTokenByID(ctx, id)
+				return err
+			},
+		)
+
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+}
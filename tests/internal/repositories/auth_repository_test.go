@@ -1,9 +1,11 @@
 package repositories__test
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/DKhorkov/medods/internal/config"
 	"github.com/DKhorkov/medods/internal/entities"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
 	testconfig "github.com/DKhorkov/medods/tests/config"
@@ -32,16 +34,18 @@ func TestRepositoriesCreateRefreshToken(t *testing.T) {
 			},
 		}
 
-		// Error and zero userID due to returning nil ID after register.
-		// SQLite inner realization without AUTO_INCREMENT for SERIAL PRIMARY KEY
 		refreshTokenID, err := authRepository.CreateRefreshToken(
-			testsConfig.RefreshToken.GUID,
-			testsConfig.RefreshToken.Value,
-			ttl,
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             testsConfig.RefreshToken.GUID,
+				Value:            testsConfig.RefreshToken.Value,
+				TTL:              ttl,
+				SessionStartedAt: time.Now(),
+			},
 		)
 
-		require.Error(t, err)
-		assert.Equal(t, 0, refreshTokenID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, refreshTokenID)
 
 		var refreshTokensCount int
 		err = connection.QueryRow(
@@ -54,7 +58,7 @@ func TestRepositoriesCreateRefreshToken(t *testing.T) {
 		assert.Equal(t, 1, refreshTokensCount)
 	})
 
-	t.Run("create refreshToken failure due to existence of refreshToken with same value", func(t *testing.T) {
+	t.Run("create refreshToken with value of existing refreshToken", func(t *testing.T) {
 		connection := testlifespan.StartUp(t)
 		defer testlifespan.TearDown(t, connection)
 
@@ -64,7 +68,7 @@ func TestRepositoriesCreateRefreshToken(t *testing.T) {
 			VALUES ($1, $2, $3)
 			RETURNING refresh_tokens.id
 		`,
-			testsConfig.RefreshToken.GUID,
+			"anotherGUID",
 			testsConfig.RefreshToken.Value,
 			ttl,
 		)
@@ -80,13 +84,42 @@ func TestRepositoriesCreateRefreshToken(t *testing.T) {
 		}
 
 		refreshTokenID, err := authRepository.CreateRefreshToken(
-			testsConfig.RefreshToken.GUID,
-			testsConfig.RefreshToken.Value,
-			ttl,
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             testsConfig.RefreshToken.GUID,
+				Value:            testsConfig.RefreshToken.Value,
+				TTL:              ttl,
+				SessionStartedAt: time.Now(),
+			},
 		)
 
-		require.Error(t, err)
-		assert.Equal(t, 0, refreshTokenID)
+		// Values are hashes, which are not used for lookup, so they are not unique:
+		require.NoError(t, err)
+		assert.Equal(t, 2, refreshTokenID)
+	})
+
+	t.Run("create second active refreshToken of user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		refreshTokenDTO := entities.CreateRefreshTokenDTO{
+			GUID:             testsConfig.RefreshToken.GUID,
+			Value:            testsConfig.RefreshToken.Value,
+			TTL:              ttl,
+			SessionStartedAt: time.Now(),
+		}
+
+		_, err := authRepository.CreateRefreshToken(context.Background(), refreshTokenDTO)
+		require.NoError(t, err)
+
+		_, err = authRepository.CreateRefreshToken(context.Background(), refreshTokenDTO)
+		assert.IsType(t, customerrors.RefreshTokenRotatedError{}, err)
 	})
 }
 
@@ -125,13 +158,10 @@ func TestRepositoriesGetRefreshTokenByID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByID(refreshTokenID)
+		refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), refreshTokenID)
 		require.NoError(t, err)
 		assert.NotNil(t, refreshToken)
-		assert.Equal(
-			t,
-			time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
-			refreshToken.DeletedAt)
+		assert.Nil(t, refreshToken.DeletedAt)
 	})
 
 	t.Run("get non existing refreshToken", func(t *testing.T) {
@@ -144,7 +174,7 @@ func TestRepositoriesGetRefreshTokenByID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByID(refreshTokenID)
+		refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), refreshTokenID)
 		require.Error(t, err)
 		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 		assert.Nil(t, refreshToken)
@@ -176,7 +206,7 @@ func TestRepositoriesGetRefreshTokenByID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByID(refreshTokenID)
+		refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), refreshTokenID)
 		require.Error(t, err)
 		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 		assert.Nil(t, refreshToken)
@@ -188,13 +218,14 @@ func TestRepositoriesGetRefreshTokenByID(t *testing.T) {
 
 		_, err := connection.Exec(
 			`
-				INSERT INTO refresh_tokens (id, guid, value, ttl) 
-				VALUES ($1, $2, $3, $4)
+				INSERT INTO refresh_tokens (id, guid, value, ttl, created_at) 
+				VALUES ($1, $2, $3, $4, $5)
 			`,
 			refreshTokenID,
 			testsConfig.RefreshToken.GUID,
 			testsConfig.RefreshToken.Value,
-			time.Now().Add(time.Hour*time.Duration(-1)),
+			time.Now().Add(time.Hour*time.Duration(-1)).UTC(),
+			time.Now().Add(time.Hour*time.Duration(-2)).UTC(),
 		)
 
 		if err != nil {
@@ -207,10 +238,43 @@ func TestRepositoriesGetRefreshTokenByID(t *testing.T) {
 			},
 		}
 
-		// No error due to in memory sqlite and CURRENT_TIMESTAMP returns 1 January 1970
-		refreshToken, err := authRepository.GetRefreshTokenByID(refreshTokenID)
-		require.NoError(t, err)
-		assert.True(t, refreshToken.TTL.Before(time.Now()))
+		refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), refreshTokenID)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Nil(t, refreshToken)
+	})
+	t.Run("database failure is not reported as not found", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		refreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), refreshTokenID)
+		require.Error(t, err)
+		assert.NotEqual(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Nil(t, refreshToken)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		refreshToken, err := authRepository.GetRefreshTokenByID(ctx, refreshTokenID)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, refreshToken)
 	})
 }
 
@@ -249,13 +313,10 @@ func TestRepositoriesGetRefreshTokenByGUID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByGUID(testsConfig.RefreshToken.GUID)
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
 		require.NoError(t, err)
 		assert.NotNil(t, refreshToken)
-		assert.Equal(
-			t,
-			time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
-			refreshToken.DeletedAt)
+		assert.Nil(t, refreshToken.DeletedAt)
 	})
 
 	t.Run("get non existing refreshToken", func(t *testing.T) {
@@ -268,7 +329,7 @@ func TestRepositoriesGetRefreshTokenByGUID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByGUID(testsConfig.RefreshToken.GUID)
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
 		require.Error(t, err)
 		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 		assert.Nil(t, refreshToken)
@@ -300,7 +361,7 @@ func TestRepositoriesGetRefreshTokenByGUID(t *testing.T) {
 			},
 		}
 
-		refreshToken, err := authRepository.GetRefreshTokenByGUID(testsConfig.RefreshToken.GUID)
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
 		require.Error(t, err)
 		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 		assert.Nil(t, refreshToken)
@@ -312,13 +373,14 @@ func TestRepositoriesGetRefreshTokenByGUID(t *testing.T) {
 
 		_, err := connection.Exec(
 			`
-				INSERT INTO refresh_tokens (id, guid, value, ttl) 
-				VALUES ($1, $2, $3, $4)
+				INSERT INTO refresh_tokens (id, guid, value, ttl, created_at) 
+				VALUES ($1, $2, $3, $4, $5)
 			`,
 			refreshTokenID,
 			testsConfig.RefreshToken.GUID,
 			testsConfig.RefreshToken.Value,
-			time.Now().Add(time.Hour*time.Duration(-1)),
+			time.Now().Add(time.Hour*time.Duration(-1)).UTC(),
+			time.Now().Add(time.Hour*time.Duration(-2)).UTC(),
 		)
 
 		if err != nil {
@@ -331,10 +393,25 @@ func TestRepositoriesGetRefreshTokenByGUID(t *testing.T) {
 			},
 		}
 
-		// No error due to in memory sqlite and CURRENT_TIMESTAMP returns 1 January 1970
-		refreshToken, err := authRepository.GetRefreshTokenByGUID(testsConfig.RefreshToken.GUID)
-		require.NoError(t, err)
-		assert.True(t, refreshToken.TTL.Before(time.Now()))
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Nil(t, refreshToken)
+	})
+	t.Run("database failure is not reported as not found", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		refreshToken, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		require.Error(t, err)
+		assert.NotEqual(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Nil(t, refreshToken)
 	})
 }
 
@@ -371,8 +448,12 @@ func TestRepositoriesDeleteRefreshToken(t *testing.T) {
 			},
 		}
 
-		err = authRepository.DeleteRefreshToken(testRefreshToken)
+		err = authRepository.DeleteRefreshToken(context.Background(), testRefreshToken)
 		require.NoError(t, err)
+
+		// Refresh token, deleted by concurrent rotation, can not be deleted again:
+		err = authRepository.DeleteRefreshToken(context.Background(), testRefreshToken)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 	})
 
 	t.Run("delete non existing refreshToken", func(t *testing.T) {
@@ -392,8 +473,36 @@ func TestRepositoriesDeleteRefreshToken(t *testing.T) {
 			},
 		}
 
-		// No error due to update stmt inside of DeleteRefreshToken method
-		err := authRepository.DeleteRefreshToken(testRefreshToken)
-		require.NoError(t, err)
+		err := authRepository.DeleteRefreshToken(context.Background(), testRefreshToken)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
 	})
 }
+
+func TestRepositoriesNewAuthRepository(t *testing.T) {
+	// SQL of CommonAuthRepository is deliberately portable, so every database backend shares it, while tests above
+	// and contract tests run it on SQLite:
+	testCases := []struct {
+		backend  string
+		expected any
+	}{
+		{backend: config.PostgreSQLDatabaseBackend, expected: &repositories.CommonAuthRepository{}},
+		{backend: config.SQLiteDatabaseBackend, expected: &repositories.CommonAuthRepository{}},
+		{backend: config.MySQLDatabaseBackend, expected: &repositories.CommonAuthRepository{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.backend, func(t *testing.T) {
+			authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+				config.DatabasesConfig{Backend: tc.backend},
+				config.CacheConfig{},
+				nil,
+				nil,
+			)
+
+			require.NoError(t, err)
+			defer closeAuthRepository()
+			assert.IsType(t, tc.expected, authRepository)
+		})
+	}
+}
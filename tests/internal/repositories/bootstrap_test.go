@@ -0,0 +1,74 @@
+package repositories__test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoriesBootstrapDatabase(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("memory database is migrated and seeded on every bootstrap", func(t *testing.T) {
+		databasesConfig := config.DatabasesConfig{
+			Backend: config.MemoryDatabaseBackend,
+			Memory:  config.MemoryStoreConfig{UsersPath: filepath.Join(t.TempDir(), "users.json")},
+		}
+
+		err := os.WriteFile(
+			databasesConfig.Memory.UsersPath,
+			[]byte(`[{"GUID": "`+testsConfig.RefreshToken.GUID+`", "email": "user@example.com"}]`),
+			0o600,
+		)
+
+		require.NoError(t, err)
+
+		dbConnector, err := database.New(context.Background(), databasesConfig, logger)
+		require.NoError(t, err)
+		defer dbConnector.CloseConnection()
+
+		// The second bootstrap is the same as start with restored snapshot:
+		for range 2 {
+			err = repositories.BootstrapDatabase(context.Background(), databasesConfig, "ru", dbConnector, logger)
+			require.NoError(t, err)
+		}
+
+		usersRepository := &repositories.CommonUsersRepository{DBConnector: dbConnector}
+		user, err := usersRepository.GetUserByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+		assert.Equal(t, "user@example.com", user.Email)
+	})
+
+	t.Run("database is not migrated without MigrateOnStart", func(t *testing.T) {
+		databasesConfig := config.DatabasesConfig{
+			Backend: config.SQLiteDatabaseBackend,
+			SQLite: config.DatabaseConfig{
+				DatabaseName: filepath.Join(t.TempDir(), "medods.db"),
+				Driver:       "sqlite3",
+			},
+		}
+
+		dbConnector, err := database.New(context.Background(), databasesConfig, logger)
+		require.NoError(t, err)
+		defer dbConnector.CloseConnection()
+
+		err = repositories.BootstrapDatabase(context.Background(), databasesConfig, "ru", dbConnector, logger)
+		require.NoError(t, err)
+
+		var tables int
+		err = dbConnector.GetConnection().QueryRow(
+			"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'refresh_tokens'",
+		).Scan(&tables)
+
+		require.NoError(t, err)
+		assert.Zero(t, tables)
+	})
+}
@@ -0,0 +1,300 @@
+package repositories__test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testconfig "github.com/DKhorkov/medods/tests/config"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingAuthRepository counts reads, which reach database, and fails deletions, if deleteErr is set.
+type countingAuthRepository struct {
+	*repositories.CommonAuthRepository
+	refreshTokenReads int
+	denialReads       int
+	deleteErr         error
+}
+
+func (repo *countingAuthRepository) GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	repo.refreshTokenReads++
+	return repo.CommonAuthRepository.GetRefreshTokenByID(ctx, id)
+}
+
+func (repo *countingAuthRepository) DeleteRefreshToken(ctx context.Context, token *entities.RefreshToken) error {
+	if repo.deleteErr != nil {
+		return repo.deleteErr
+	}
+
+	return repo.CommonAuthRepository.DeleteRefreshToken(ctx, token)
+}
+
+func (repo *countingAuthRepository) GetAccessTokensDeniedBefore(ctx context.Context, guid string) (time.Time, error) {
+	repo.denialReads++
+	return repo.CommonAuthRepository.GetAccessTokensDeniedBefore(ctx, guid)
+}
+
+func newCachedAuthRepository(
+	t *testing.T,
+) (*repositories.CachedAuthRepository, *countingAuthRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+
+	testsConfig := testconfig.New()
+	authRepository := &countingAuthRepository{
+		CommonAuthRepository: &repositories.CommonAuthRepository{DBConnector: testlifespan.StartUpMemory(t)},
+	}
+	cachedAuthRepository := repositories.NewCachedAuthRepository(
+		authRepository,
+		client,
+		config.CacheConfig{TTL: time.Minute, Timeout: time.Millisecond * 200},
+		logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+	)
+
+	return cachedAuthRepository, authRepository, server
+}
+
+func TestRepositoriesCachedAuthRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cached refresh token is read without database", func(t *testing.T) {
+		cachedAuthRepository, authRepository, _ := newCachedAuthRepository(t)
+		id := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "10.0.0.1", time.Now().Add(time.Hour))
+
+		for range 3 {
+			refreshToken, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, id, refreshToken.ID)
+			assert.Equal(t, "10.0.0.1", refreshToken.IP)
+		}
+
+		assert.Equal(t, 1, authRepository.refreshTokenReads)
+	})
+
+	t.Run("deleted refresh token is not read from cache", func(t *testing.T) {
+		cachedAuthRepository, _, _ := newCachedAuthRepository(t)
+		id := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "", time.Now().Add(time.Hour))
+
+		refreshToken, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, cachedAuthRepository.DeleteRefreshToken(ctx, refreshToken))
+
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+
+	t.Run("failed deletion does not hide refresh token", func(t *testing.T) {
+		cachedAuthRepository, authRepository, _ := newCachedAuthRepository(t)
+		id := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "", time.Now().Add(time.Hour))
+
+		refreshToken, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+
+		authRepository.deleteErr = errors.New("transaction is rolled back")
+		require.Error(t, cachedAuthRepository.DeleteRefreshToken(ctx, refreshToken))
+
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refresh tokens revoked by GUID are not read from cache", func(t *testing.T) {
+		cachedAuthRepository, _, _ := newCachedAuthRepository(t)
+		revokedID := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "", time.Now().Add(time.Hour))
+		otherID := createContractRefreshToken(t, cachedAuthRepository, contractOtherGUID, "", time.Now().Add(time.Hour))
+
+		for _, id := range []int{revokedID, otherID} {
+			_, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+			require.NoError(t, err)
+		}
+
+		deleted, err := cachedAuthRepository.DeleteRefreshTokensByGUID(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, revokedID)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, otherID)
+		assert.NoError(t, err)
+
+		// Refresh tokens, created after revocation, are valid:
+		id := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "", time.Now().Add(time.Hour))
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("access tokens denylist is cached and invalidated on denial", func(t *testing.T) {
+		cachedAuthRepository, authRepository, _ := newCachedAuthRepository(t)
+
+		for range 3 {
+			deniedBefore, err := cachedAuthRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+			require.NoError(t, err)
+			assert.True(t, deniedBefore.IsZero())
+		}
+
+		assert.Equal(t, 1, authRepository.denialReads)
+
+		deniedBefore := time.Now()
+		require.NoError(
+			t,
+			cachedAuthRepository.DenyAccessTokens(ctx, contractGUID, deniedBefore, time.Now().Add(time.Hour)),
+		)
+
+		cachedDeniedBefore, err := cachedAuthRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.Equal(cachedDeniedBefore))
+	})
+
+	t.Run("database is used, when cache is down", func(t *testing.T) {
+		cachedAuthRepository, authRepository, server := newCachedAuthRepository(t)
+		id := createContractRefreshToken(t, cachedAuthRepository, contractGUID, "", time.Now().Add(time.Hour))
+		server.Close()
+
+		refreshToken, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, cachedAuthRepository.DeleteRefreshToken(ctx, refreshToken))
+
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		deniedBefore, err := cachedAuthRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.IsZero())
+		assert.Equal(t, 2, authRepository.refreshTokenReads)
+	})
+}
+
+// startHangingRedis accepts connections and never responds, as Redis, which is unreachable behind firewall or
+// overloaded, so that every call waits for timeout.
+func startHangingRedis(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		var connections []net.Conn
+		defer func() {
+			for _, connection := range connections {
+				_ = connection.Close()
+			}
+		}()
+
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			connections = append(connections, connection)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRepositoriesCachedAuthRepositoryFailureBackoff(t *testing.T) {
+	ctx := context.Background()
+	testsConfig := testconfig.New()
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+	const timeout = time.Millisecond * 200
+
+	newRepository := func(
+		t *testing.T,
+		address string,
+		failureBackoff time.Duration,
+	) (*repositories.CachedAuthRepository, *countingAuthRepository) {
+		client := redis.NewClient(&redis.Options{Addr: address, MaxRetries: -1, ContextTimeoutEnabled: true})
+		t.Cleanup(func() { _ = client.Close() })
+
+		authRepository := &countingAuthRepository{
+			CommonAuthRepository: &repositories.CommonAuthRepository{DBConnector: testlifespan.StartUpMemory(t)},
+		}
+
+		cachedAuthRepository := repositories.NewCachedAuthRepository(
+			authRepository,
+			client,
+			config.CacheConfig{TTL: time.Minute, Timeout: timeout, FailureBackoff: failureBackoff},
+			logger,
+		)
+
+		return cachedAuthRepository, authRepository
+	}
+
+	t.Run("reads do not wait for unresponsive cache during backoff", func(t *testing.T) {
+		cachedAuthRepository, authRepository := newRepository(t, startHangingRedis(t), time.Minute)
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+
+		startedAt := time.Now()
+		_, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(startedAt), timeout)
+		assert.Less(t, time.Since(startedAt), timeout*3, "cache call is limited by timeout")
+
+		startedAt = time.Now()
+		for range 5 {
+			_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+			require.NoError(t, err)
+
+			_, err = cachedAuthRepository.GetAccessTokensDeniedBefore(ctx, contractGUID)
+			require.NoError(t, err)
+		}
+
+		assert.Less(t, time.Since(startedAt), timeout)
+		assert.Equal(t, 6, authRepository.refreshTokenReads)
+		assert.Equal(t, 5, authRepository.denialReads)
+	})
+
+	t.Run("every read waits for unresponsive cache without backoff", func(t *testing.T) {
+		cachedAuthRepository, authRepository := newRepository(t, startHangingRedis(t), 0)
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+
+		startedAt := time.Now()
+		for range 2 {
+			_, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+			require.NoError(t, err)
+		}
+
+		assert.GreaterOrEqual(t, time.Since(startedAt), timeout*2)
+	})
+
+	t.Run("cache is used again after backoff", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		cachedAuthRepository, authRepository := newRepository(t, server.Addr(), time.Millisecond*100)
+		id := createContractRefreshToken(t, authRepository, contractGUID, "", time.Now().Add(time.Hour))
+
+		server.Close()
+		_, err := cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+
+		require.NoError(t, server.Restart())
+		_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+		require.NoError(t, err)
+		assert.False(t, server.Exists("medods:refresh_token:1"), "cache is not filled during backoff")
+
+		time.Sleep(time.Millisecond * 150)
+		for range 2 {
+			_, err = cachedAuthRepository.GetRefreshTokenByID(ctx, id)
+			require.NoError(t, err)
+		}
+
+		assert.True(t, server.Exists("medods:refresh_token:1"))
+		assert.Equal(t, 3, authRepository.refreshTokenReads)
+	})
+}
@@ -1,11 +1,15 @@
 package testslifespan
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"path"
 	"testing"
 
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
 	testconfig "github.com/DKhorkov/medods/tests/config"
 	"github.com/pressly/goose/v3"
 )
@@ -49,7 +53,7 @@ func TearDown(t *testing.T, connection *sql.DB) {
 		t.Fatalf("failed to get cwd: %v", err)
 	}
 
-	err = goose.Down(
+	err = goose.Reset(
 		connection,
 		path.Dir(
 			path.Dir(
@@ -66,3 +70,32 @@ func TearDown(t *testing.T, connection *sql.DB) {
 		t.Fatalf("failed to close the connection to the database: %v", err)
 	}
 }
+
+// StartUpMemory opens migrated in-memory database of memory backend, which is closed after test. Unlike database
+// of StartUp, it is safe for concurrent use, because it has the only connection.
+func StartUpMemory(t *testing.T) *database.CommonDBConnector {
+	t.Helper()
+
+	dbConnector, err := database.New(
+		context.Background(),
+		config.DatabasesConfig{Backend: config.MemoryDatabaseBackend},
+		logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+	)
+
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+
+	t.Cleanup(dbConnector.CloseConnection)
+
+	migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate in-memory database: %v", err)
+	}
+
+	return dbConnector
+}
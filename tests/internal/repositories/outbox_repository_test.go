@@ -0,0 +1,214 @@
+package repositories__test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNotification = entities.Notification{
+	Recipients: []string{"example@yandex.ru"},
+	Subject:    "Test subject",
+	Body:       "Test body",
+}
+
+func TestRepositoriesCreateOutboxMessage(t *testing.T) {
+	t.Run("successfully create outbox message", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+		assert.NotEmpty(t, id)
+
+		messages, err := outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, id, messages[0].ID)
+		assert.Equal(t, entities.OutboxMessagePending, messages[0].Status)
+		assert.Equal(t, 0, messages[0].Attempts)
+		assert.Equal(t, id, messages[0].Notification.ID)
+		assert.Equal(t, testNotification.Recipients, messages[0].Notification.Recipients)
+		assert.Equal(t, testNotification.Body, messages[0].Notification.Body)
+	})
+}
+
+func TestRepositoriesClaimOutboxMessage(t *testing.T) {
+	t.Run("message is claimed only once", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		claimed, err := outboxRepository.ClaimOutboxMessage(context.Background(), id, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		claimed, err = outboxRepository.ClaimOutboxMessage(context.Background(), id, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		// Claimed message is not pending until lease expiration:
+		messages, err := outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("claim non existing message", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		claimed, err := outboxRepository.ClaimOutboxMessage(context.Background(), "non existing", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, claimed)
+	})
+}
+
+func TestRepositoriesMarkOutboxMessage(t *testing.T) {
+	t.Run("delivered message is not pending", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		err = outboxRepository.MarkOutboxMessageDelivered(context.Background(), id)
+		require.NoError(t, err)
+
+		messages, err := outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("failed message is pending after next attempt time", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		err = outboxRepository.MarkOutboxMessageFailed(context.Background(), id, "smtp is down", time.Now().Add(-time.Second))
+		require.NoError(t, err)
+
+		messages, err := outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, 1, messages[0].Attempts)
+		assert.Equal(t, "smtp is down", messages[0].LastError)
+
+		err = outboxRepository.MarkOutboxMessageFailed(context.Background(), id, "smtp is down", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		messages, err = outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("dead message is not pending", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		err = outboxRepository.MarkOutboxMessageDead(context.Background(), id, "invalid recipient")
+		require.NoError(t, err)
+
+		var status string
+		err = connection.QueryRow(
+			`
+				SELECT status
+				FROM notifications_outbox
+				WHERE id = $1
+			`,
+			id,
+		).Scan(&status)
+
+		require.NoError(t, err)
+		assert.Equal(t, entities.OutboxMessageDead, status)
+	})
+}
+
+func TestRepositoriesPurgeOutbox(t *testing.T) {
+	t.Run("delivered and dead messages are purged in batches", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		deliveredID, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+		require.NoError(t, outboxRepository.MarkOutboxMessageDelivered(context.Background(), deliveredID))
+
+		deadID, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+		require.NoError(t, outboxRepository.MarkOutboxMessageDead(context.Background(), deadID, "smtp is down"))
+
+		pendingID, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		// Messages, updated within retention window, are kept:
+		purged, err := outboxRepository.PurgeOutbox(context.Background(), time.Now().Add(-time.Hour), 10)
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		for _, expected := range []int{1, 1, 0} {
+			purged, err = outboxRepository.PurgeOutbox(context.Background(), time.Now().Add(time.Minute), 1)
+			require.NoError(t, err)
+			assert.Equal(t, expected, purged)
+		}
+
+		messages, err := outboxRepository.GetPendingOutboxMessages(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, pendingID, messages[0].ID)
+	})
+}
@@ -0,0 +1,184 @@
+package repositories__test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestRefreshToken(t *testing.T, connection *sql.DB, id int, guid, ip string, createdAt time.Time) {
+	t.Helper()
+
+	_, err := connection.Exec(
+		`
+			INSERT INTO refresh_tokens (id, guid, value, ttl, ip, created_at, updated_at, session_started_at) 
+			VALUES ($1, $2, $3, $4, $5, $6, $6, $6)
+		`,
+		id,
+		guid,
+		fmt.Sprintf("value%d", id),
+		time.Now().Add(time.Hour).UTC(),
+		ip,
+		createdAt.UTC(),
+	)
+
+	require.NoError(t, err)
+}
+
+func deleteTestRefreshToken(t *testing.T, connection *sql.DB, id int) {
+	t.Helper()
+
+	_, err := connection.Exec(`UPDATE refresh_tokens SET deleted_at = $1 WHERE id = $2`, time.Now().UTC(), id)
+	require.NoError(t, err)
+}
+
+func TestRepositoriesGetRefreshTokensByGUID(t *testing.T) {
+	t.Run("get refreshTokens page", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		now := time.Now()
+		for id := 1; id <= 3; id++ {
+			// Only the newest refresh token of user may be active, previous ones were rotated:
+			if id > 1 {
+				deleteTestRefreshToken(t, connection, id-1)
+			}
+
+			createTestRefreshToken(t, connection, id, "someGUID", "127.0.0.1", now.Add(time.Duration(id)*time.Minute))
+		}
+
+		createTestRefreshToken(t, connection, 4, "anotherGUID", "127.0.0.1", now)
+
+		refreshTokens, err := authRepository.GetRefreshTokensByGUID(context.Background(), "someGUID", entities.Pagination{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 2)
+		assert.Equal(t, 3, refreshTokens[0].ID)
+		assert.Equal(t, 2, refreshTokens[1].ID)
+		assert.Equal(t, "127.0.0.1", refreshTokens[0].IP)
+
+		refreshTokens, err = authRepository.GetRefreshTokensByGUID(
+			context.Background(),
+			"someGUID",
+			entities.Pagination{Limit: 2, Offset: 2},
+		)
+
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 1)
+		assert.Equal(t, 1, refreshTokens[0].ID)
+	})
+}
+
+func TestRepositoriesGetRefreshTokensByIP(t *testing.T) {
+	t.Run("get refreshTokens by IP", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		createTestRefreshToken(t, connection, 1, "someGUID", "127.0.0.1", time.Now())
+		createTestRefreshToken(t, connection, 2, "anotherGUID", "10.0.0.1", time.Now())
+
+		refreshTokens, err := authRepository.GetRefreshTokensByIP(context.Background(), "10.0.0.1", entities.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 1)
+		assert.Equal(t, "anotherGUID", refreshTokens[0].GUID)
+	})
+}
+
+func TestRepositoriesDeleteRefreshTokensByGUID(t *testing.T) {
+	t.Run("delete all refreshTokens of user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		createTestRefreshToken(t, connection, 1, "someGUID", "127.0.0.1", time.Now())
+		deleteTestRefreshToken(t, connection, 1)
+		createTestRefreshToken(t, connection, 2, "someGUID", "127.0.0.1", time.Now())
+		createTestRefreshToken(t, connection, 3, "anotherGUID", "127.0.0.1", time.Now())
+
+		// Only the active refresh token is counted, the rotated one has been deleted before:
+		deleted, err := authRepository.DeleteRefreshTokensByGUID(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		// Already deleted refresh tokens are not counted:
+		deleted, err = authRepository.DeleteRefreshTokensByGUID(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		refreshTokens, err := authRepository.GetRefreshTokensByGUID(context.Background(), "someGUID", entities.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, refreshTokens, 2)
+		assert.NotNil(t, refreshTokens[0].DeletedAt)
+
+		_, err = authRepository.GetRefreshTokenByID(context.Background(), 3)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRepositoriesAccessTokensDenylist(t *testing.T) {
+	t.Run("deny access tokens", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		deniedBefore, err := authRepository.GetAccessTokensDeniedBefore(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.IsZero())
+
+		now := time.Now().Truncate(time.Second)
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "someGUID", now.Add(-time.Minute), now.Add(time.Hour)))
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "someGUID", now, now.Add(time.Hour)))
+
+		deniedBefore, err = authRepository.GetAccessTokensDeniedBefore(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.True(t, now.Equal(deniedBefore))
+	})
+
+	t.Run("expired denial is ignored", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		now := time.Now()
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "someGUID", now.Add(-time.Hour), now.Add(-time.Minute)))
+
+		deniedBefore, err := authRepository.GetAccessTokensDeniedBefore(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.True(t, deniedBefore.IsZero())
+	})
+}
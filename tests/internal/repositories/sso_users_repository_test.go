@@ -0,0 +1,284 @@
+package repositories__test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/protobuf/generated/go/sso"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ssoStandIn is an in-process stand-in for hmtm-sso users service, which fails first failures calls with
+// failureCode, which is codes.Unavailable by default.
+type ssoStandIn struct {
+	sso.UnimplementedUsersServiceServer
+	users       map[int64]string
+	failures    int32
+	failureCode codes.Code
+	calls       atomic.Int32
+	requestID   atomic.Value
+}
+
+func (s *ssoStandIn) GetUser(ctx context.Context, request *sso.GetUserRequest) (*sso.GetUserResponse, error) {
+	if requestIDs := metadata.ValueFromIncomingContext(ctx, requestid.MetadataKey); len(requestIDs) > 0 {
+		s.requestID.Store(requestIDs[0])
+	}
+
+	if s.calls.Add(1) <= s.failures {
+		if s.failureCode != codes.OK {
+			return nil, status.Error(s.failureCode, "sso is failing")
+		}
+
+		return nil, status.Error(codes.Unavailable, "sso is unavailable")
+	}
+
+	email, ok := s.users[request.GetUserID()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	return &sso.GetUserResponse{
+		UserID:    request.GetUserID(),
+		Email:     email,
+		CreatedAt: timestamppb.Now(),
+		UpdatedAt: timestamppb.Now(),
+	}, nil
+}
+
+func (s *ssoStandIn) GetUsers(_ context.Context, _ *emptypb.Empty) (*sso.GetUsersResponse, error) {
+	s.calls.Add(1)
+	response := &sso.GetUsersResponse{}
+	for userID, email := range s.users {
+		response.Users = append(
+			response.Users,
+			&sso.GetUserResponse{UserID: userID, Email: email, CreatedAt: timestamppb.Now(), UpdatedAt: timestamppb.Now()},
+		)
+	}
+
+	return response, nil
+}
+
+func startSSOStandIn(t *testing.T, standIn *ssoStandIn) sso.UsersServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	sso.RegisterUsersServiceServer(server, standIn)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	connection, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			},
+		),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	require.NoError(t, err)
+	t.Cleanup(
+		func() {
+			_ = connection.Close()
+			server.Stop()
+		},
+	)
+
+	return sso.NewUsersServiceClient(connection)
+}
+
+var testSSOConfig = config.SSOConfig{
+	Timeout:      time.Second,
+	MaxAttempts:  3,
+	RetryBackoff: time.Millisecond,
+	CacheTTL:     time.Minute,
+}
+
+func TestRepositoriesSSOGetUserByGUID(t *testing.T) {
+	t.Run("get existing user", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		user, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, "1", user.GUID)
+		assert.Equal(t, "example@yandex.ru", user.Email)
+		assert.Equal(t, entities.UserStatusActive, user.Status)
+	})
+
+	t.Run("get non existing user", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		user, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+		assert.Nil(t, user)
+	})
+
+	t.Run("non numeric GUID is not found without calling SSO", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		_, err := usersRepository.GetUserByGUID(context.Background(), "someGUID")
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+		assert.Equal(t, int32(0), standIn.calls.Load())
+	})
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}, failures: 2}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		email, err := usersRepository.GetUserEmail(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, "example@yandex.ru", email)
+		assert.Equal(t, int32(3), standIn.calls.Load())
+	})
+
+	t.Run("request ID is forwarded", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		_, err := usersRepository.GetUserByGUID(requestid.NewContext(context.Background(), "someRequestID"), "1")
+		require.NoError(t, err)
+		assert.Equal(t, "someRequestID", standIn.requestID.Load())
+	})
+
+	t.Run("retries are exhausted", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}, failures: 3}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		_, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		require.Error(t, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("rate limiting after retries is reported as too many requests", func(t *testing.T) {
+		standIn := &ssoStandIn{
+			users:       map[int64]string{1: "example@yandex.ru"},
+			failures:    3,
+			failureCode: codes.ResourceExhausted,
+		}
+
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		_, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		assert.IsType(t, customerrors.TooManyRequestsError{}, err)
+		assert.Equal(t, int32(3), standIn.calls.Load())
+	})
+
+	t.Run("retries are stopped, when context is done", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}, failures: 3}
+		ssoConfig := testSSOConfig
+		ssoConfig.RetryBackoff = time.Hour
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), ssoConfig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		_, err := usersRepository.GetUserByGUID(ctx, "1")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), standIn.calls.Load())
+	})
+
+	t.Run("user is cached", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		for range 3 {
+			_, err := usersRepository.GetUserByGUID(context.Background(), "1")
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(1), standIn.calls.Load())
+	})
+
+	t.Run("users, which expire first, are evicted from full cache", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "first@yandex.ru", 2: "second@yandex.ru", 3: "third@yandex.ru"}}
+		ssoConfig := testSSOConfig
+		ssoConfig.CacheSize = 2
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), ssoConfig)
+
+		for _, guid := range []string{"1", "2", "3", "3", "2"} {
+			_, err := usersRepository.GetUserByGUID(context.Background(), guid)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(3), standIn.calls.Load())
+
+		_, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, int32(4), standIn.calls.Load())
+	})
+
+	t.Run("expired user is fetched again", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "example@yandex.ru"}}
+		ssoConfig := testSSOConfig
+		ssoConfig.CacheTTL = time.Millisecond * 50
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), ssoConfig)
+
+		_, err := usersRepository.GetUserByGUID(context.Background(), "1")
+		require.NoError(t, err)
+
+		time.Sleep(time.Millisecond * 100)
+		_, err = usersRepository.GetUserByGUID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), standIn.calls.Load())
+	})
+}
+
+func TestRepositoriesSSOGetUserByEmail(t *testing.T) {
+	t.Run("list of users is cached", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "first@yandex.ru", 2: "second@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		for _, email := range []string{"first@yandex.ru", "second@yandex.ru", "third@yandex.ru"} {
+			_, _ = usersRepository.GetUserByEmail(context.Background(), email)
+		}
+
+		users, err := usersRepository.GetUsersByStatus(context.Background(), entities.UserStatusActive)
+		require.NoError(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, int32(1), standIn.calls.Load())
+
+		// Users, found by email, are cached by GUID too:
+		_, err = usersRepository.GetUserByGUID(context.Background(), "2")
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), standIn.calls.Load())
+	})
+
+	t.Run("get existing user", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "first@yandex.ru", 2: "second@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		user, err := usersRepository.GetUserByEmail(context.Background(), "second@yandex.ru")
+		require.NoError(t, err)
+		assert.Equal(t, "2", user.GUID)
+	})
+
+	t.Run("get non existing user", func(t *testing.T) {
+		standIn := &ssoStandIn{users: map[int64]string{1: "first@yandex.ru"}}
+		usersRepository := repositories.NewSSOUsersRepository(startSSOStandIn(t, standIn), testSSOConfig)
+
+		_, err := usersRepository.GetUserByEmail(context.Background(), "second@yandex.ru")
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+	})
+}
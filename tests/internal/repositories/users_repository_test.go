@@ -0,0 +1,261 @@
+package repositories__test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	"github.com/DKhorkov/medods/internal/repositories"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestUser(t *testing.T, connection *sql.DB, guid, email, status, locale string) {
+	t.Helper()
+
+	now := time.Now().UTC()
+	_, err := connection.Exec(
+		`
+			INSERT INTO users (guid, email, status, locale, created_at, updated_at) 
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		guid,
+		email,
+		status,
+		locale,
+		now,
+		now,
+	)
+
+	require.NoError(t, err)
+}
+
+func TestRepositoriesGetUserByGUID(t *testing.T) {
+	t.Run("get existing user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersRepository := repositories.CommonUsersRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		createTestUser(t, connection, "someGUID", "example@yandex.ru", entities.UserStatusActive, "en")
+
+		user, err := usersRepository.GetUserByGUID(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, "example@yandex.ru", user.Email)
+		assert.Equal(t, entities.UserStatusActive, user.Status)
+		assert.Equal(t, "en", user.Locale)
+
+		email, err := usersRepository.GetUserEmail(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, "example@yandex.ru", email)
+
+		locale, err := usersRepository.GetUserLocale(context.Background(), "someGUID")
+		require.NoError(t, err)
+		assert.Equal(t, "en", locale)
+	})
+
+	t.Run("get non existing user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersRepository := repositories.CommonUsersRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		user, err := usersRepository.GetUserByGUID(context.Background(), "someGUID")
+		require.Error(t, err)
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+		assert.Nil(t, user)
+
+		_, err = usersRepository.GetUserEmail(context.Background(), "someGUID")
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+	})
+}
+
+func TestRepositoriesGetUserByEmail(t *testing.T) {
+	t.Run("get existing user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersRepository := repositories.CommonUsersRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		createTestUser(t, connection, "someGUID", "example@yandex.ru", entities.UserStatusActive, "ru")
+
+		user, err := usersRepository.GetUserByEmail(context.Background(), "example@yandex.ru")
+		require.NoError(t, err)
+		assert.Equal(t, "someGUID", user.GUID)
+	})
+
+	t.Run("get non existing user", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersRepository := repositories.CommonUsersRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		user, err := usersRepository.GetUserByEmail(context.Background(), "example@yandex.ru")
+		assert.IsType(t, customerrors.UserNotFoundError{}, err)
+		assert.Nil(t, user)
+	})
+}
+
+func TestRepositoriesGetUsersByStatus(t *testing.T) {
+	t.Run("get users by status", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersRepository := repositories.CommonUsersRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		createTestUser(t, connection, "firstGUID", "first@yandex.ru", entities.UserStatusActive, "ru")
+		createTestUser(t, connection, "secondGUID", "second@yandex.ru", entities.UserStatusBlocked, "ru")
+
+		users, err := usersRepository.GetUsersByStatus(context.Background(), entities.UserStatusBlocked)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "secondGUID", users[0].GUID)
+
+		users, err = usersRepository.GetUsersByStatus(context.Background(), entities.UserStatusDeleted)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+}
+
+func TestRepositoriesNewUsersRepository(t *testing.T) {
+	t.Run("sso can not be used with postgres", func(t *testing.T) {
+		_, _, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.SSOUsersSource},
+			config.DatabasesConfig{Backend: config.PostgreSQLDatabaseBackend},
+			nil,
+		)
+
+		assert.IsType(t, customerrors.UnsupportedUsersSourceError{}, err)
+	})
+
+	t.Run("sso is used with sqlite", func(t *testing.T) {
+		usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.SSOUsersSource},
+			config.DatabasesConfig{Backend: config.SQLiteDatabaseBackend},
+			nil,
+		)
+
+		require.NoError(t, err)
+		defer closeUsersRepository()
+		assert.IsType(t, &repositories.SSOUsersRepository{}, usersRepository)
+	})
+
+	t.Run("database can not be used with memory without seeded users", func(t *testing.T) {
+		_, _, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.DatabaseUsersSource},
+			config.DatabasesConfig{Backend: config.MemoryDatabaseBackend},
+			nil,
+		)
+
+		assert.IsType(t, customerrors.UnsupportedUsersSourceError{}, err)
+	})
+
+	t.Run("database is used with memory and seeded users", func(t *testing.T) {
+		usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.DatabaseUsersSource},
+			config.DatabasesConfig{
+				Backend: config.MemoryDatabaseBackend,
+				Memory:  config.MemoryStoreConfig{UsersPath: "users.json"},
+			},
+			nil,
+		)
+
+		require.NoError(t, err)
+		defer closeUsersRepository()
+		assert.IsType(t, &repositories.CommonUsersRepository{}, usersRepository)
+	})
+
+	t.Run("database is used with postgres", func(t *testing.T) {
+		usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.DatabaseUsersSource},
+			config.DatabasesConfig{Backend: config.PostgreSQLDatabaseBackend},
+			nil,
+		)
+
+		require.NoError(t, err)
+		defer closeUsersRepository()
+		assert.IsType(t, &repositories.CommonUsersRepository{}, usersRepository)
+	})
+}
+
+func TestRepositoriesSeedUsers(t *testing.T) {
+	t.Run("users are seeded with default status and locale", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersPath := filepath.Join(t.TempDir(), "users.json")
+		err := os.WriteFile(
+			usersPath,
+			[]byte(`[
+				{"GUID": "42385b4f-d5cd-4543-acef-229fb60fe35f", "email": "first@example.com"},
+				{"GUID": "9d2e4a61-3b7c-4f08-a5d1-6c8b0e2f4a17", "email": "second@example.com", "status": "locked",
+				 "locale": "en"}
+			]`),
+			0o600,
+		)
+
+		require.NoError(t, err)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		seeded, err := repositories.SeedUsers(context.Background(), dbConnector, usersPath, "ru")
+		require.NoError(t, err)
+		assert.Equal(t, 2, seeded)
+
+		usersRepository := repositories.CommonUsersRepository{DBConnector: dbConnector}
+		user, err := usersRepository.GetUserByEmail(context.Background(), "first@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserStatusActive, user.Status)
+		assert.Equal(t, "ru", user.Locale)
+
+		user, err = usersRepository.GetUserByEmail(context.Background(), "second@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserStatusLocked, user.Status)
+		assert.Equal(t, "en", user.Locale)
+
+		// Existing users, for example, restored from snapshot, are not seeded again:
+		seeded, err = repositories.SeedUsers(context.Background(), dbConnector, usersPath, "ru")
+		require.NoError(t, err)
+		assert.Zero(t, seeded)
+	})
+
+	t.Run("no users are seeded from malformed file", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		usersPath := filepath.Join(t.TempDir(), "users.json")
+		require.NoError(t, os.WriteFile(usersPath, []byte("{"), 0o600))
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		seeded, err := repositories.SeedUsers(context.Background(), dbConnector, usersPath, "ru")
+		require.Error(t, err)
+		assert.Zero(t, seeded)
+	})
+}
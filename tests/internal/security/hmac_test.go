@@ -0,0 +1,70 @@
+package security__test
+
+import (
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecuritySignPayload(t *testing.T) {
+	const (
+		secret    = "testSecret"
+		timestamp = int64(1728561600)
+	)
+
+	payload := []byte(`{"type":"token.issued"}`)
+	signature := security.SignPayload(secret, timestamp, payload)
+
+	testCases := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   []byte
+		signature string
+		valid     bool
+	}{
+		{
+			name:      "valid signature",
+			secret:    secret,
+			timestamp: timestamp,
+			payload:   payload,
+			signature: signature,
+			valid:     true,
+		},
+		{
+			name:      "another secret",
+			secret:    "anotherSecret",
+			timestamp: timestamp,
+			payload:   payload,
+			signature: signature,
+			valid:     false,
+		},
+		{
+			name:      "another timestamp",
+			secret:    secret,
+			timestamp: timestamp + 1,
+			payload:   payload,
+			signature: signature,
+			valid:     false,
+		},
+		{
+			name:      "tampered payload",
+			secret:    secret,
+			timestamp: timestamp,
+			payload:   []byte(`{"type":"session.revoked"}`),
+			signature: signature,
+			valid:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(
+				t,
+				tc.valid,
+				security.ValidatePayloadSignature(tc.secret, tc.timestamp, tc.payload, tc.signature),
+			)
+		})
+	}
+}
@@ -131,6 +131,10 @@ func TestSecurityParseJWT(t *testing.T) {
 				assert.IsType(t, tc.errorType, err)
 			} else {
 				require.NoError(t, err, tc.message)
+				assert.WithinDuration(t, time.Now(), parsedJWT.IssuedAt, 2*time.Second)
+				assert.WithinDuration(t, time.Now().Add(tc.data.TTL), parsedJWT.ExpiresAt, 2*time.Second)
+				parsedJWT.IssuedAt = time.Time{}
+				parsedJWT.ExpiresAt = time.Time{}
 			}
 
 			assert.Equal(
@@ -0,0 +1,24 @@
+package security__test
+
+import (
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityGenerateSecretKey(t *testing.T) {
+	t.Run("generate unique keys of provided size", func(t *testing.T) {
+		first, err := security.GenerateSecretKey(32)
+		require.NoError(t, err)
+
+		decoded, err := security.Decode(first)
+		require.NoError(t, err)
+		assert.Len(t, decoded, 32)
+
+		second, err := security.GenerateSecretKey(32)
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+}
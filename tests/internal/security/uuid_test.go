@@ -0,0 +1,45 @@
+package security__test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityGenerateUUID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	t.Run("generate valid unique UUIDs", func(t *testing.T) {
+		first, err := security.GenerateUUID()
+		require.NoError(t, err)
+		assert.Regexp(t, uuidPattern, first)
+
+		second, err := security.GenerateUUID()
+		require.NoError(t, err)
+		assert.Regexp(t, uuidPattern, second)
+		assert.NotEqual(t, first, second)
+	})
+}
+
+func TestSecurityIsUUID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{name: "generated UUID", value: "42385b4f-d5cd-4543-acef-229fb60fe35f", expected: true},
+		{name: "uppercase UUID", value: "42385B4F-D5CD-4543-ACEF-229FB60FE35F", expected: true},
+		{name: "UUID without hyphens", value: "42385b4fd5cd4543acef229fb60fe35f", expected: false},
+		{name: "numeric ID", value: "42", expected: false},
+		{name: "empty value", value: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, security.IsUUID(tc.value))
+		})
+	}
+}
@@ -1,15 +1,24 @@
 package services__test
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/DKhorkov/medods/internal/database"
 	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
 	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
+	"github.com/DKhorkov/medods/internal/repositories"
 	"github.com/DKhorkov/medods/internal/services"
 	testconfig "github.com/DKhorkov/medods/tests/config"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var testsConfig = testconfig.New()
@@ -21,9 +30,13 @@ func TestServicesCreateRefreshToken(t *testing.T) {
 
 		previousRefreshTokensCount := len(authRepository.RefreshTokensStorage)
 		refreshTokenID, err := authService.CreateRefreshToken(
-			testsConfig.RefreshToken.GUID,
-			testsConfig.RefreshToken.Value,
-			time.Now().Add(time.Hour),
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             testsConfig.RefreshToken.GUID,
+				Value:            testsConfig.RefreshToken.Value,
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
 		)
 
 		require.NoError(t, err)
@@ -51,9 +64,13 @@ func TestServicesCreateRefreshToken(t *testing.T) {
 
 		previousRefreshTokensCount := len(authRepository.RefreshTokensStorage)
 		refreshTokenID, err := authService.CreateRefreshToken(
-			oldRefreshToken.GUID,
-			"newTestValue",
-			time.Now().Add(time.Hour),
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             oldRefreshToken.GUID,
+				Value:            "newTestValue",
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
 		)
 
 		require.NoError(t, err)
@@ -62,6 +79,251 @@ func TestServicesCreateRefreshToken(t *testing.T) {
 			previousRefreshTokensCount+1,
 			refreshTokenID)
 
+		require.NotNil(t, oldRefreshToken.DeletedAt)
 		assert.True(t, oldRefreshToken.DeletedAt.Before(time.Now()))
 	})
+
+	t.Run("refresh token, which has been already rotated, is not rotated again", func(t *testing.T) {
+		deletedAt := time.Now()
+		authRepository := &mocks.MockedAuthRepository{
+			RefreshTokensStorage: map[int]*entities.RefreshToken{
+				1: {ID: 1, GUID: testsConfig.RefreshToken.GUID, TTL: time.Now().Add(time.Hour), DeletedAt: &deletedAt},
+				2: {ID: 2, GUID: testsConfig.RefreshToken.GUID, TTL: time.Now().Add(time.Hour)},
+			},
+		}
+
+		authService := &services.CommonAuthService{AuthRepository: authRepository}
+		_, err := authService.CreateRefreshToken(
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:                  testsConfig.RefreshToken.GUID,
+				Value:                 "newTestValue",
+				TTL:                   time.Now().Add(time.Hour),
+				SessionStartedAt:      time.Now(),
+				RotatedRefreshTokenID: 1,
+			},
+		)
+
+		assert.IsType(t, customerrors.RefreshTokenRotatedError{}, err)
+		assert.Len(t, authRepository.RefreshTokensStorage, 2)
+		assert.Nil(t, authRepository.RefreshTokensStorage[2].DeletedAt)
+	})
+
+	t.Run("only one of concurrent rotations of refresh token succeeds", func(t *testing.T) {
+		memoryAuthRepository := &repositories.CommonAuthRepository{DBConnector: testlifespan.StartUpMemory(t)}
+		rotatedRefreshTokenID, err := memoryAuthRepository.CreateRefreshToken(
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             testsConfig.RefreshToken.GUID,
+				Value:            testsConfig.RefreshToken.Value,
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
+		)
+
+		require.NoError(t, err)
+
+		const rotations = 2
+		authRepository := &barrierAuthRepository{CommonAuthRepository: memoryAuthRepository}
+		authRepository.reads.Add(rotations)
+		authService := &services.CommonAuthService{AuthRepository: authRepository}
+
+		errs := make(chan error, rotations)
+		for i := range rotations {
+			go func() {
+				_, err := authService.CreateRefreshToken(
+					context.Background(),
+					entities.CreateRefreshTokenDTO{
+						GUID:                  testsConfig.RefreshToken.GUID,
+						Value:                 fmt.Sprintf("newTestValue%d", i),
+						TTL:                   time.Now().Add(time.Hour),
+						SessionStartedAt:      time.Now(),
+						RotatedRefreshTokenID: rotatedRefreshTokenID,
+					},
+				)
+
+				errs <- err
+			}()
+		}
+
+		var failed []error
+		for range rotations {
+			if err = <-errs; err != nil {
+				failed = append(failed, err)
+			}
+		}
+
+		require.Len(t, failed, 1)
+		assert.IsType(t, customerrors.RefreshTokenRotatedError{}, failed[0])
+
+		refreshTokens, err := memoryAuthRepository.GetRefreshTokensByGUID(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			entities.Pagination{Limit: 10},
+		)
+
+		require.NoError(t, err)
+		assert.Len(t, refreshTokens, 2)
+	})
+}
+
+// barrierAuthRepository makes concurrent rotations read rotated refresh token before any of them deletes it.
+type barrierAuthRepository struct {
+	*repositories.CommonAuthRepository
+	reads sync.WaitGroup
+}
+
+func (repo *barrierAuthRepository) GetRefreshTokenByID(ctx context.Context, id int) (*entities.RefreshToken, error) {
+	refreshToken, err := repo.CommonAuthRepository.GetRefreshTokenByID(ctx, id)
+	repo.reads.Done()
+	repo.reads.Wait()
+	return refreshToken, err
+}
+
+func TestServicesRevokeTokenFamily(t *testing.T) {
+	newAuthService := func(t *testing.T) (*services.CommonAuthService, *database.CommonDBConnector) {
+		connection := testlifespan.StartUp(t)
+		t.Cleanup(
+			func() {
+				testlifespan.TearDown(t, connection)
+			},
+		)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		authService := &services.CommonAuthService{
+			AuthRepository: &repositories.CommonAuthRepository{DBConnector: dbConnector},
+			UnitOfWork:     &database.CommonUnitOfWork{DBConnector: dbConnector},
+		}
+
+		_, err := authService.AuthRepository.CreateRefreshToken(
+			context.Background(),
+			entities.CreateRefreshTokenDTO{
+				GUID:             testsConfig.RefreshToken.GUID,
+				Value:            testsConfig.RefreshToken.Value,
+				TTL:              time.Now().Add(time.Hour),
+				SessionStartedAt: time.Now(),
+			},
+		)
+
+		require.NoError(t, err)
+
+		return authService, dbConnector
+	}
+
+	t.Run("revoke token family successfully", func(t *testing.T) {
+		authService, _ := newAuthService(t)
+
+		now := time.Now()
+		revoked, err := authService.RevokeTokenFamily(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			now,
+			now.Add(time.Hour),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, revoked)
+
+		denied, err := authService.IsAccessTokenDenied(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			now.Add(-time.Minute),
+		)
+
+		require.NoError(t, err)
+		assert.True(t, denied)
+
+		// Access token, issued within the second of denial, has the same truncated issue time as tokens, issued
+		// right after denial:
+		denied, err = authService.IsAccessTokenDenied(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			now.Truncate(time.Second),
+		)
+
+		require.NoError(t, err)
+		assert.False(t, denied)
+	})
+
+	t.Run("refresh tokens are not revoked, when access tokens can not be denied", func(t *testing.T) {
+		authService, dbConnector := newAuthService(t)
+
+		// Denial fails inside the transaction, after refresh tokens have been already deleted:
+		_, err := dbConnector.Connection.Exec(`ALTER TABLE access_tokens_denylist RENAME TO access_tokens_denylist_old`)
+		require.NoError(t, err)
+		defer func() {
+			_, err = dbConnector.Connection.Exec(`ALTER TABLE access_tokens_denylist_old RENAME TO access_tokens_denylist`)
+			require.NoError(t, err)
+		}()
+
+		now := time.Now()
+		_, err = authService.RevokeTokenFamily(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			now,
+			now.Add(time.Hour),
+		)
+
+		require.Error(t, err)
+
+		refreshToken, err := authService.AuthRepository.GetRefreshTokenByGUID(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+		)
+
+		require.NoError(t, err)
+		assert.Nil(t, refreshToken.DeletedAt)
+	})
+}
+
+func TestServicesIsSessionActive(t *testing.T) {
+	sessionStartedAt := time.Now().Add(-time.Hour)
+	deletedAt := time.Now()
+	newAuthService := func() *services.CommonAuthService {
+		return &services.CommonAuthService{
+			AuthRepository: &mocks.MockedAuthRepository{
+				RefreshTokensStorage: map[int]*entities.RefreshToken{
+					1: {
+						ID:               1,
+						GUID:             testsConfig.RefreshToken.GUID,
+						TTL:              time.Now().Add(time.Hour),
+						SessionStartedAt: sessionStartedAt,
+						DeletedAt:        &deletedAt,
+					},
+					2: {
+						ID:               2,
+						GUID:             testsConfig.RefreshToken.GUID,
+						TTL:              time.Now().Add(time.Hour),
+						SessionStartedAt: sessionStartedAt,
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("session of rotated refresh token is active", func(t *testing.T) {
+		active, err := newAuthService().IsSessionActive(context.Background(), testsConfig.RefreshToken.GUID, 1)
+		require.NoError(t, err)
+		assert.True(t, active)
+	})
+
+	t.Run("session of refresh token of another user is not active", func(t *testing.T) {
+		active, err := newAuthService().IsSessionActive(context.Background(), "anotherGUID", 2)
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
+
+	t.Run("ended session is not active", func(t *testing.T) {
+		authService := newAuthService()
+		_, err := authService.RevokeAllRefreshTokens(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+
+		active, err := authService.IsSessionActive(context.Background(), testsConfig.RefreshToken.GUID, 1)
+		require.NoError(t, err)
+		assert.False(t, active)
+
+		active, err = authService.IsSessionActive(context.Background(), testsConfig.RefreshToken.GUID, 3)
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
 }
@@ -0,0 +1,174 @@
+package usecases__test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/entities"
+	customerrors "github.com/DKhorkov/medods/internal/errors"
+	publishermocks "github.com/DKhorkov/medods/internal/mocks/publishers"
+	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/DKhorkov/medods/internal/services"
+	"github.com/DKhorkov/medods/internal/usecases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRefreshTokensStorage() map[int]*entities.RefreshToken {
+	storage := map[int]*entities.RefreshToken{}
+	for id := 1; id <= 3; id++ {
+		storage[id] = &entities.RefreshToken{
+			ID:   id,
+			GUID: testsConfig.RefreshToken.GUID,
+			IP:   testsConfig.IP,
+			TTL:  time.Now().Add(time.Hour),
+		}
+	}
+
+	storage[4] = &entities.RefreshToken{ID: 4, GUID: "anotherGUID", IP: "10.0.0.1", TTL: time.Now().Add(time.Hour)}
+	return storage
+}
+
+func TestAdminUseCasesGetUserSessions(t *testing.T) {
+	t.Run("get sessions page", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: newTestRefreshTokensStorage()}
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		page, err := adminUseCases.GetUserSessions(context.Background(), testsConfig.RefreshToken.GUID, entities.Pagination{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 2)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, 3, page.Sessions[0].ID)
+		assert.True(t, page.Sessions[0].Active)
+
+		page, err = adminUseCases.GetUserSessions(
+			context.Background(),
+			testsConfig.RefreshToken.GUID,
+			entities.Pagination{Limit: 2, Offset: 2},
+		)
+
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("search sessions by IP", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: newTestRefreshTokensStorage()}
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		page, err := adminUseCases.SearchSessionsByIP(context.Background(), "10.0.0.1", entities.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "anotherGUID", page.Sessions[0].GUID)
+	})
+}
+
+func TestAdminUseCasesRevokeSessions(t *testing.T) {
+	t.Run("revoke single session", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: newTestRefreshTokensStorage()}
+		eventPublisher := &publishermocks.MockedEventPublisher{}
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService:    &services.CommonAuthService{AuthRepository: authRepository},
+			EventPublisher: eventPublisher,
+			Logger:         logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		require.NoError(t, adminUseCases.RevokeSession(context.Background(), 2))
+		_, err := authRepository.GetRefreshTokenByID(context.Background(), 2)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		require.Len(t, eventPublisher.Events(), 1)
+		assert.Equal(t, entities.SessionRevokedEvent, eventPublisher.Events()[0].Type)
+
+		err = adminUseCases.RevokeSession(context.Background(), 2)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+	})
+
+	t.Run("revoke all sessions of user", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: newTestRefreshTokensStorage()}
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		revoked, err := adminUseCases.RevokeAllUserSessions(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, revoked)
+
+		_, err = authRepository.GetRefreshTokenByID(context.Background(), 4)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAdminUseCasesExpireUserAccessTokens(t *testing.T) {
+	t.Run("expired access tokens are not valid", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: newTestRefreshTokensStorage()}
+		authService := &services.CommonAuthService{AuthRepository: authRepository}
+		logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+		useCases := &usecases.CommonUseCases{AuthService: authService, JWTConfig: testsConfig.JWT, Logger: logger}
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService: authService,
+			JWTConfig:   testsConfig.JWT,
+			Logger:      logger,
+		}
+
+		accessToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.AccessTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     strconv.Itoa(1),
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		require.NoError(t, err)
+
+		guid, err := useCases.ValidateAccessToken(context.Background(), accessToken)
+		require.NoError(t, err)
+		assert.Equal(t, testsConfig.RefreshToken.GUID, guid)
+
+		waitForNextSecond()
+		require.NoError(t, adminUseCases.ExpireUserAccessTokens(context.Background(), testsConfig.RefreshToken.GUID))
+
+		_, err = useCases.ValidateAccessToken(context.Background(), accessToken)
+		assert.IsType(t, customerrors.AccessTokenRevokedError{}, err)
+	})
+
+	t.Run("expired access token can not be refreshed", func(t *testing.T) {
+		useCases, _, _ := newReuseTestUseCases(t, 0)
+		adminUseCases := &usecases.CommonAdminUseCases{
+			AuthService: useCases.AuthService,
+			JWTConfig:   testsConfig.JWT,
+			Logger:      useCases.Logger,
+		}
+
+		tokens := createTestTokens(t, useCases)
+
+		waitForNextSecond()
+		require.NoError(t, adminUseCases.ExpireUserAccessTokens(context.Background(), testsConfig.RefreshToken.GUID))
+
+		_, err := refreshTestTokens(useCases, tokens)
+		assert.IsType(t, customerrors.AccessTokenRevokedError{}, err)
+
+		// Tokens of the next login are issued after denial:
+		_, err = refreshTestTokens(useCases, createTestTokens(t, useCases))
+		assert.NoError(t, err)
+	})
+}
+
+// waitForNextSecond waits until the next second starts. Issue time of access tokens has second precision, so that
+// only access tokens, issued in previous seconds, are denied.
+func waitForNextSecond() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
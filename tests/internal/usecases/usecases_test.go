@@ -1,20 +1,35 @@
 package usecases__test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/emails"
 	"github.com/DKhorkov/medods/internal/entities"
 	customerrors "github.com/DKhorkov/medods/internal/errors"
+	notifiermocks "github.com/DKhorkov/medods/internal/mocks/notifiers"
+	publishermocks "github.com/DKhorkov/medods/internal/mocks/publishers"
 	mocks "github.com/DKhorkov/medods/internal/mocks/repositories"
+	"github.com/DKhorkov/medods/internal/notifiers"
+	"github.com/DKhorkov/medods/internal/repositories"
 	"github.com/DKhorkov/medods/internal/security"
 	"github.com/DKhorkov/medods/internal/services"
 	"github.com/DKhorkov/medods/internal/usecases"
 	testconfig "github.com/DKhorkov/medods/tests/config"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var testsConfig = testconfig.New()
@@ -30,11 +45,12 @@ func TestUseCasesCreateTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
 		}
 
 		tokens, err := useCases.CreateTokens(
+			context.Background(),
 			entities.CreateTokensDTO{
 				GUID: testsConfig.RefreshToken.GUID,
 				IP:   testsConfig.IP,
@@ -81,7 +97,7 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
 		}
 
@@ -116,6 +132,7 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		}
 
 		tokens, err := useCases.RefreshTokens(
+			context.Background(),
 			entities.RefreshTokensDTO{
 				Tokens: entities.Tokens{
 					AccessToken:  accessToken,
@@ -158,13 +175,16 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		usersRepository := &mocks.MockedUsersRepository{}
 		authService := &services.CommonAuthService{AuthRepository: authRepository}
 		usersService := &services.CommonUsersService{UsersRepository: usersRepository}
+		notifier := &notifiermocks.MockedNotifier{}
 		useCases := &usecases.CommonUseCases{
-			AuthService:  authService,
-			UsersService: usersService,
-			HashCost:     testsConfig.HashCost,
-			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
-			Logger:       logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+			AuthService:   authService,
+			UsersService:  usersService,
+			HashCost:      testsConfig.HashCost,
+			JWTConfig:     testsConfig.JWT,
+			Notifier:      notifier,
+			EmailRenderer: emails.NewRenderer("", testsConfig.Emails.DefaultLocale),
+			EmailsConfig:  testsConfig.Emails,
+			Logger:        logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
 		}
 
 		refreshToken, err := security.GenerateJWT(
@@ -198,6 +218,7 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		}
 
 		tokens, err := useCases.RefreshTokens(
+			context.Background(),
 			entities.RefreshTokensDTO{
 				Tokens: entities.Tokens{
 					AccessToken:  accessToken,
@@ -210,6 +231,98 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		require.Error(t, err)
 		assert.IsType(t, customerrors.IPAddressDoesNotMatchWithTokensIPError{}, err)
 		assert.Nil(t, tokens)
+
+		// User should be warned:
+		require.Len(t, notifier.Notifications(), 1)
+		notification := notifier.Notifications()[0]
+		email, err := usersRepository.GetUserEmail(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{email}, notification.Recipients)
+		assert.Contains(t, notification.Body, "[::1]")
+		assert.Contains(t, notification.HTMLBody, testsConfig.Emails.RevokeLinkURL+"?token=")
+	})
+
+	t.Run("refresh tokens with another IP and unknown user email", func(t *testing.T) {
+		hashedRefreshTokenValue, err := security.HashRefreshToken(testsConfig.RefreshToken.Value, testsConfig.HashCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dbRefreshToken := &entities.RefreshToken{
+			ID:    1,
+			Value: hashedRefreshTokenValue,
+			TTL:   time.Now().Add(time.Hour),
+			GUID:  testsConfig.RefreshToken.GUID,
+		}
+
+		authRepository := &mocks.MockedAuthRepository{
+			RefreshTokensStorage: map[int]*entities.RefreshToken{
+				dbRefreshToken.ID: dbRefreshToken,
+			},
+		}
+
+		usersRepository := &mocks.MockedUsersRepository{Err: errors.New("user not found")}
+		authService := &services.CommonAuthService{AuthRepository: authRepository}
+		usersService := &services.CommonUsersService{UsersRepository: usersRepository}
+		notifier := &notifiermocks.MockedNotifier{}
+		useCases := &usecases.CommonUseCases{
+			AuthService:   authService,
+			UsersService:  usersService,
+			HashCost:      testsConfig.HashCost,
+			JWTConfig:     testsConfig.JWT,
+			Notifier:      notifier,
+			EmailRenderer: emails.NewRenderer("", testsConfig.Emails.DefaultLocale),
+			EmailsConfig:  testsConfig.Emails,
+			Logger:        logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		refreshToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.RefreshTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     testsConfig.RefreshToken.Value,
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		accessToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.RefreshTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     strconv.Itoa(dbRefreshToken.ID),
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokens, err := useCases.RefreshTokens(
+			context.Background(),
+			entities.RefreshTokensDTO{
+				Tokens: entities.Tokens{
+					AccessToken:  accessToken,
+					RefreshToken: refreshToken,
+				},
+				IP: "[::1]",
+			},
+		)
+
+		require.Error(t, err)
+		assert.IsType(t, customerrors.IPAddressDoesNotMatchWithTokensIPError{}, err)
+		assert.Nil(t, tokens)
+
+		// Notification without recipient should not be sent:
+		assert.Empty(t, notifier.Notifications())
 	})
 
 	t.Run("access token does not belong to refresh token", func(t *testing.T) {
@@ -252,7 +365,7 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 			UsersService: usersService,
 			HashCost:     testsConfig.HashCost,
 			JWTConfig:    testsConfig.JWT,
-			SMTPConfig:   testsConfig.SMTP,
+			Notifier:     &notifiermocks.MockedNotifier{},
 			Logger:       logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
 		}
 
@@ -287,6 +400,7 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		}
 
 		tokens, err := useCases.RefreshTokens(
+			context.Background(),
 			entities.RefreshTokensDTO{
 				Tokens: entities.Tokens{
 					AccessToken:  accessToken,
@@ -301,3 +415,769 @@ func TestUseCasesRefreshTokens(t *testing.T) {
 		assert.Nil(t, tokens)
 	})
 }
+
+func TestUseCasesRefreshTokensSessionLifetime(t *testing.T) {
+	generateTokens := func(t *testing.T, refreshTokenID int) entities.Tokens {
+		refreshToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.RefreshTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     testsConfig.RefreshToken.Value,
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		accessToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.AccessTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     strconv.Itoa(refreshTokenID),
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return entities.Tokens{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+		}
+	}
+
+	hashedRefreshTokenValue, err := security.HashRefreshToken(testsConfig.RefreshToken.Value, testsConfig.HashCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		createdAt        time.Time
+		sessionStartedAt time.Time
+		expectedError    error
+	}{
+		{
+			name:             "session is alive",
+			createdAt:        time.Now().Add(-testsConfig.Session.IdleTimeout / 2),
+			sessionStartedAt: time.Now().Add(-testsConfig.Session.MaxAge / 2),
+		},
+		{
+			name:             "session reached maximum lifetime",
+			createdAt:        time.Now(),
+			sessionStartedAt: time.Now().Add(-testsConfig.Session.MaxAge - time.Minute),
+			expectedError:    customerrors.SessionExpiredError{},
+		},
+		{
+			name:             "session is idle for too long",
+			createdAt:        time.Now().Add(-testsConfig.Session.IdleTimeout - time.Minute),
+			sessionStartedAt: time.Now().Add(-testsConfig.Session.IdleTimeout - time.Minute),
+			expectedError:    customerrors.SessionIdleTimeoutError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbRefreshToken := &entities.RefreshToken{
+				ID:               1,
+				Value:            hashedRefreshTokenValue,
+				TTL:              time.Now().Add(time.Hour),
+				GUID:             testsConfig.RefreshToken.GUID,
+				CreatedAt:        tc.createdAt,
+				SessionStartedAt: tc.sessionStartedAt,
+			}
+
+			authRepository := &mocks.MockedAuthRepository{
+				RefreshTokensStorage: map[int]*entities.RefreshToken{
+					dbRefreshToken.ID: dbRefreshToken,
+				},
+			}
+
+			usersRepository := &mocks.MockedUsersRepository{}
+			authService := &services.CommonAuthService{AuthRepository: authRepository}
+			usersService := &services.CommonUsersService{UsersRepository: usersRepository}
+			useCases := &usecases.CommonUseCases{
+				AuthService:   authService,
+				UsersService:  usersService,
+				HashCost:      testsConfig.HashCost,
+				JWTConfig:     testsConfig.JWT,
+				SessionConfig: testsConfig.Session,
+				Notifier:      &notifiermocks.MockedNotifier{},
+				Logger:        logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+			}
+
+			tokens, err := useCases.RefreshTokens(
+				context.Background(),
+				entities.RefreshTokensDTO{
+					Tokens: generateTokens(t, dbRefreshToken.ID),
+					IP:     testsConfig.IP,
+				},
+			)
+
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				assert.IsType(t, tc.expectedError, err)
+				assert.Nil(t, tokens)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, tokens)
+
+			// New refresh token should belong to the same session:
+			newRefreshToken, err := authRepository.GetRefreshTokenByID(context.Background(), dbRefreshToken.ID+1)
+			require.NoError(t, err)
+			assert.True(t, tc.sessionStartedAt.Equal(newRefreshToken.SessionStartedAt))
+		})
+	}
+}
+
+func TestUseCasesRevokeTokens(t *testing.T) {
+	t.Run("revoke tokens successfully", func(t *testing.T) {
+		dbRefreshToken := &entities.RefreshToken{
+			ID:    1,
+			Value: testsConfig.RefreshToken.Value,
+			TTL:   time.Now().Add(time.Hour),
+			GUID:  testsConfig.RefreshToken.GUID,
+		}
+
+		authRepository := &mocks.MockedAuthRepository{
+			RefreshTokensStorage: map[int]*entities.RefreshToken{
+				dbRefreshToken.ID: dbRefreshToken,
+			},
+		}
+
+		useCases := &usecases.CommonUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			JWTConfig:   testsConfig.JWT,
+			Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		revokeToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.Emails.RevokeLinkTTL,
+				IP:        testsConfig.IP,
+				Value:     "revoke:" + strconv.Itoa(dbRefreshToken.ID),
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = useCases.RevokeTokens(context.Background(), revokeToken)
+		require.NoError(t, err)
+		assert.NotNil(t, dbRefreshToken.DeletedAt)
+	})
+
+	t.Run("revoke token ends session of its refresh token only", func(t *testing.T) {
+		useCases, authRepository, _ := newReuseTestUseCases(t, 0)
+		suspiciousTokens := createTestTokens(t, useCases)
+		refreshedTokens, err := refreshTestTokens(useCases, suspiciousTokens)
+		require.NoError(t, err)
+
+		// Session is revoked, even though refresh token has been rotated since revoke token was issued:
+		err = useCases.RevokeTokens(context.Background(), newTestRevokeToken(t, 1))
+		require.NoError(t, err)
+
+		_, err = refreshTestTokens(useCases, refreshedTokens)
+		assert.Error(t, err)
+
+		// Session, which has been started after the revoked one, is not revoked by the same revoke token:
+		newSessionTokens := createTestTokens(t, useCases)
+		err = useCases.RevokeTokens(context.Background(), newTestRevokeToken(t, 1))
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = refreshTestTokens(useCases, newSessionTokens)
+		assert.NoError(t, err)
+		assert.Len(t, authRepository.RefreshTokensStorage, 4)
+	})
+
+	t.Run("access token can not be used as revoke token", func(t *testing.T) {
+		dbRefreshToken := &entities.RefreshToken{
+			ID:    1,
+			Value: testsConfig.RefreshToken.Value,
+			TTL:   time.Now().Add(time.Hour),
+			GUID:  testsConfig.RefreshToken.GUID,
+		}
+
+		authRepository := &mocks.MockedAuthRepository{
+			RefreshTokensStorage: map[int]*entities.RefreshToken{
+				dbRefreshToken.ID: dbRefreshToken,
+			},
+		}
+
+		useCases := &usecases.CommonUseCases{
+			AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+			JWTConfig:   testsConfig.JWT,
+			Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		accessToken, err := security.GenerateJWT(
+			security.JWTData{
+				SecretKey: testsConfig.JWT.SecretKey,
+				Algorithm: testsConfig.JWT.Algorithm,
+				TTL:       testsConfig.JWT.AccessTokenTTL,
+				IP:        testsConfig.IP,
+				Value:     strconv.Itoa(dbRefreshToken.ID),
+				GUID:      testsConfig.RefreshToken.GUID,
+			},
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = useCases.RevokeTokens(context.Background(), accessToken)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.InvalidJWTError{}, err)
+		assert.Nil(t, dbRefreshToken.DeletedAt)
+	})
+}
+
+func TestUseCasesSecurityEvents(t *testing.T) {
+	t.Run("token issued event is published", func(t *testing.T) {
+		authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: map[int]*entities.RefreshToken{}}
+		eventPublisher := &publishermocks.MockedEventPublisher{}
+		useCases := &usecases.CommonUseCases{
+			AuthService:    &services.CommonAuthService{AuthRepository: authRepository},
+			UsersService:   &services.CommonUsersService{UsersRepository: &mocks.MockedUsersRepository{}},
+			HashCost:       testsConfig.HashCost,
+			JWTConfig:      testsConfig.JWT,
+			EventPublisher: eventPublisher,
+			Logger:         logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		_, err := useCases.CreateTokens(
+			context.Background(),
+			entities.CreateTokensDTO{
+				GUID: testsConfig.RefreshToken.GUID,
+				IP:   testsConfig.IP,
+			},
+		)
+
+		require.NoError(t, err)
+		events := eventPublisher.Events()
+		require.Len(t, events, 1)
+		assert.Equal(t, entities.TokenIssuedEvent, events[0].Type)
+		assert.Equal(t, testsConfig.RefreshToken.GUID, events[0].GUID)
+		assert.Equal(t, testsConfig.IP, events[0].IP)
+		assert.NotEmpty(t, events[0].ID)
+	})
+
+	t.Run("refresh token reuse revokes active session", func(t *testing.T) {
+		useCases, authRepository, eventPublisher := newReuseTestUseCases(t, 0)
+		rotatedTokens := createTestTokens(t, useCases)
+		activeTokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		tokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Nil(t, tokens)
+
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.Error(t, err)
+
+		// Access tokens of the whole family are denied together with refresh tokens:
+		assert.Contains(t, authRepository.DenylistStorage, testsConfig.RefreshToken.GUID)
+
+		events := eventPublisher.Events()
+		require.Len(t, events, 4)
+		assert.Equal(t, entities.RefreshTokenReusedEvent, events[2].Type)
+		assert.Equal(t, entities.SessionRevokedEvent, events[3].Type)
+
+		// User is notified about ended sessions:
+		notifications := useCases.Notifier.(*notifiermocks.MockedNotifier).Notifications()
+		require.Len(t, notifications, 1)
+		assert.Equal(t, "MEDODS: все ваши сессии завершены", notifications[0].Subject)
+	})
+}
+
+func TestUseCasesSuspiciousIPNotification(t *testing.T) {
+	refreshFromAnotherIP := func(useCases *usecases.CommonUseCases, tokens *entities.Tokens) error {
+		_, err := useCases.RefreshTokens(
+			context.Background(),
+			entities.RefreshTokensDTO{
+				Tokens: *tokens,
+				IP:     "[::1]",
+			},
+		)
+
+		return err
+	}
+
+	t.Run("user is warned about refresh of rotated refresh token of active session", func(t *testing.T) {
+		useCases, _, _ := newReuseTestUseCases(t, 0)
+		rotatedTokens := createTestTokens(t, useCases)
+		_, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		err = refreshFromAnotherIP(useCases, rotatedTokens)
+		assert.IsType(t, customerrors.IPAddressDoesNotMatchWithTokensIPError{}, err)
+		assert.Len(t, useCases.Notifier.(*notifiermocks.MockedNotifier).Notifications(), 1)
+	})
+
+	t.Run("user is not warned about refresh of ended session", func(t *testing.T) {
+		useCases, authRepository, _ := newReuseTestUseCases(t, 0)
+		tokens := createTestTokens(t, useCases)
+		_, err := authRepository.DeleteRefreshTokensByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+
+		err = refreshFromAnotherIP(useCases, tokens)
+		assert.IsType(t, customerrors.IPAddressDoesNotMatchWithTokensIPError{}, err)
+		assert.Empty(t, useCases.Notifier.(*notifiermocks.MockedNotifier).Notifications())
+	})
+}
+
+func TestUseCasesRefreshTokenReuse(t *testing.T) {
+	t.Run("retry within grace period is not reuse", func(t *testing.T) {
+		useCases, authRepository, eventPublisher := newReuseTestUseCases(t, time.Minute)
+		rotatedTokens := createTestTokens(t, useCases)
+		activeTokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		_, err = refreshTestTokens(useCases, rotatedTokens)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenRotatedError{}, err)
+		assert.Empty(t, authRepository.DenylistStorage)
+		assert.Len(t, eventPublisher.Events(), 2)
+
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refresh token, replaced by login on another device, is not reused", func(t *testing.T) {
+		useCases, authRepository, eventPublisher := newReuseTestUseCases(t, 0)
+		replacedTokens := createTestTokens(t, useCases)
+		activeTokens := createTestTokens(t, useCases)
+
+		_, err := refreshTestTokens(useCases, replacedTokens)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Empty(t, authRepository.DenylistStorage)
+		assert.Len(t, eventPublisher.Events(), 2)
+
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired refresh token is not reused", func(t *testing.T) {
+		useCases, authRepository, eventPublisher := newReuseTestUseCases(t, 0)
+		rotatedTokens := createTestTokens(t, useCases)
+		activeTokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		for _, refreshToken := range authRepository.RefreshTokensStorage {
+			if refreshToken.DeletedAt != nil {
+				refreshToken.TTL = time.Now().Add(-time.Minute)
+			}
+		}
+
+		_, err = refreshTestTokens(useCases, rotatedTokens)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Empty(t, authRepository.DenylistStorage)
+		assert.Len(t, eventPublisher.Events(), 2)
+
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refresh token of revoked session is not reused", func(t *testing.T) {
+		useCases, authRepository, eventPublisher := newReuseTestUseCases(t, 0)
+		rotatedTokens := createTestTokens(t, useCases)
+		_, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		_, err = authRepository.DeleteRefreshTokensByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		require.NoError(t, err)
+
+		_, err = refreshTestTokens(useCases, rotatedTokens)
+		require.Error(t, err)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Empty(t, authRepository.DenylistStorage)
+		assert.Len(t, eventPublisher.Events(), 2)
+	})
+}
+
+func TestUseCasesRefreshTokenReuseAtomicity(t *testing.T) {
+	newTransactionalUseCases := func(t *testing.T) (*usecases.CommonUseCases, *sql.DB) {
+		connection := testlifespan.StartUp(t)
+		t.Cleanup(
+			func() {
+				testlifespan.TearDown(t, connection)
+			},
+		)
+
+		dbConnector := &database.CommonDBConnector{Connection: connection}
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		useCases := &usecases.CommonUseCases{
+			AuthService: &services.CommonAuthService{
+				AuthRepository: &repositories.CommonAuthRepository{DBConnector: dbConnector},
+				UnitOfWork:     unitOfWork,
+			},
+			UsersService: &services.CommonUsersService{UsersRepository: &mocks.MockedUsersRepository{}},
+			HashCost:     testsConfig.HashCost,
+			JWTConfig:    testsConfig.JWT,
+			Notifier: &notifiers.OutboxNotifier{
+				OutboxRepository: &repositories.CommonOutboxRepository{DBConnector: dbConnector},
+			},
+			EmailRenderer: emails.NewRenderer("", testsConfig.Emails.DefaultLocale),
+			EmailsConfig:  testsConfig.Emails,
+			UnitOfWork:    unitOfWork,
+			Logger:        logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		}
+
+		return useCases, connection
+	}
+
+	countOutboxMessages := func(t *testing.T, connection *sql.DB) int {
+		var count int
+		require.NoError(t, connection.QueryRow(`SELECT COUNT(*) FROM notifications_outbox`).Scan(&count))
+		return count
+	}
+
+	t.Run("token family is revoked together with notification", func(t *testing.T) {
+		useCases, connection := newTransactionalUseCases(t)
+		rotatedTokens := createTestTokens(t, useCases)
+		activeTokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		_, err = refreshTestTokens(useCases, rotatedTokens)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+		assert.Equal(t, 1, countOutboxMessages(t, connection))
+
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.Error(t, err)
+	})
+
+	t.Run("token family is not revoked, when notification can not be written", func(t *testing.T) {
+		useCases, connection := newTransactionalUseCases(t)
+		rotatedTokens := createTestTokens(t, useCases)
+		activeTokens, err := refreshTestTokens(useCases, rotatedTokens)
+		require.NoError(t, err)
+
+		_, err = connection.Exec(`ALTER TABLE notifications_outbox RENAME TO notifications_outbox_old`)
+		require.NoError(t, err)
+
+		_, err = refreshTestTokens(useCases, rotatedTokens)
+		assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+
+		_, err = connection.Exec(`ALTER TABLE notifications_outbox_old RENAME TO notifications_outbox`)
+		require.NoError(t, err)
+		assert.Equal(t, 0, countOutboxMessages(t, connection))
+
+		// Revocation has been rolled back together with notification:
+		_, err = refreshTestTokens(useCases, activeTokens)
+		assert.NoError(t, err)
+	})
+}
+
+// newTestRevokeToken creates revoke token for session of refresh token with provided ID.
+func newTestRevokeToken(t *testing.T, refreshTokenID int) string {
+	t.Helper()
+
+	revokeToken, err := security.GenerateJWT(
+		security.JWTData{
+			SecretKey: testsConfig.JWT.SecretKey,
+			Algorithm: testsConfig.JWT.Algorithm,
+			TTL:       testsConfig.Emails.RevokeLinkTTL,
+			IP:        testsConfig.IP,
+			Value:     "revoke:" + strconv.Itoa(refreshTokenID),
+			GUID:      testsConfig.RefreshToken.GUID,
+		},
+	)
+
+	require.NoError(t, err)
+	return revokeToken
+}
+
+// newReuseTestUseCases creates use cases, which consider rotated refresh token, presented again after
+// gracePeriod, to be reused.
+func newReuseTestUseCases(
+	t *testing.T,
+	gracePeriod time.Duration,
+) (*usecases.CommonUseCases, *mocks.MockedAuthRepository, *publishermocks.MockedEventPublisher) {
+	t.Helper()
+
+	authRepository := &mocks.MockedAuthRepository{RefreshTokensStorage: map[int]*entities.RefreshToken{}}
+	eventPublisher := &publishermocks.MockedEventPublisher{}
+	sessionConfig := testsConfig.Session
+	sessionConfig.ReuseGracePeriod = gracePeriod
+	useCases := &usecases.CommonUseCases{
+		AuthService:    &services.CommonAuthService{AuthRepository: authRepository},
+		UsersService:   &services.CommonUsersService{UsersRepository: &mocks.MockedUsersRepository{}},
+		HashCost:       testsConfig.HashCost,
+		JWTConfig:      testsConfig.JWT,
+		SessionConfig:  sessionConfig,
+		Notifier:       &notifiermocks.MockedNotifier{},
+		EmailRenderer:  emails.NewRenderer("", testsConfig.Emails.DefaultLocale),
+		EmailsConfig:   testsConfig.Emails,
+		EventPublisher: eventPublisher,
+		Logger:         logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+	}
+
+	return useCases, authRepository, eventPublisher
+}
+
+func createTestTokens(t *testing.T, useCases *usecases.CommonUseCases) *entities.Tokens {
+	t.Helper()
+
+	tokens, err := useCases.CreateTokens(
+		context.Background(),
+		entities.CreateTokensDTO{
+			GUID: testsConfig.RefreshToken.GUID,
+			IP:   testsConfig.IP,
+		},
+	)
+
+	require.NoError(t, err)
+	return tokens
+}
+
+func refreshTestTokens(useCases *usecases.CommonUseCases, tokens *entities.Tokens) (*entities.Tokens, error) {
+	return useCases.RefreshTokens(
+		context.Background(),
+		entities.RefreshTokensDTO{
+			Tokens: *tokens,
+			IP:     testsConfig.IP,
+		},
+	)
+}
+
+func TestUseCasesMemoryBackend(t *testing.T) {
+	t.Run("tokens are created and refreshed for seeded user", func(t *testing.T) {
+		logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+		databasesConfig := config.DatabasesConfig{
+			Backend: config.MemoryDatabaseBackend,
+			Memory: config.MemoryStoreConfig{
+				UsersPath: filepath.Join(t.TempDir(), "users.json"),
+			},
+		}
+
+		err := os.WriteFile(
+			databasesConfig.Memory.UsersPath,
+			[]byte(`[{"GUID": "`+testsConfig.RefreshToken.GUID+`", "email": "user@example.com"}]`),
+			0o600,
+		)
+
+		require.NoError(t, err)
+
+		dbConnector, err := database.New(context.Background(), databasesConfig, logger)
+		require.NoError(t, err)
+		defer dbConnector.CloseConnection()
+
+		migrator, err := database.NewMigrator(dbConnector.GetConnection(), dbConnector.Driver)
+		require.NoError(t, err)
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+
+		_, err = repositories.SeedUsers(context.Background(), dbConnector, databasesConfig.Memory.UsersPath, "ru")
+		require.NoError(t, err)
+
+		authRepository, closeAuthRepository, err := repositories.NewAuthRepository(
+			databasesConfig,
+			config.CacheConfig{},
+			dbConnector,
+			logger,
+		)
+
+		require.NoError(t, err)
+		defer closeAuthRepository()
+
+		usersRepository, closeUsersRepository, err := repositories.NewUsersRepository(
+			config.UsersConfig{Source: config.DatabaseUsersSource},
+			databasesConfig,
+			dbConnector,
+		)
+
+		require.NoError(t, err)
+		defer closeUsersRepository()
+
+		unitOfWork := &database.CommonUnitOfWork{DBConnector: dbConnector}
+		outboxRepository := &repositories.CommonOutboxRepository{DBConnector: dbConnector}
+		useCases := &usecases.CommonUseCases{
+			AuthService:    &services.CommonAuthService{AuthRepository: authRepository, UnitOfWork: unitOfWork},
+			UsersService:   &services.CommonUsersService{UsersRepository: usersRepository},
+			HashCost:       testsConfig.HashCost,
+			JWTConfig:      testsConfig.JWT,
+			SessionConfig:  testsConfig.Session,
+			Notifier:       &notifiers.OutboxNotifier{OutboxRepository: outboxRepository},
+			EmailRenderer:  emails.NewRenderer("", testsConfig.Emails.DefaultLocale),
+			EmailsConfig:   testsConfig.Emails,
+			EventPublisher: &publishermocks.MockedEventPublisher{},
+			UnitOfWork:     unitOfWork,
+			Logger:         logger,
+		}
+
+		tokens := createTestTokens(t, useCases)
+		refreshedTokens, err := refreshTestTokens(useCases, tokens)
+		require.NoError(t, err)
+		assert.NotEqual(t, tokens.RefreshToken, refreshedTokens.RefreshToken)
+	})
+}
+
+func TestUseCasesUserStatus(t *testing.T) {
+	testCases := []struct {
+		name          string
+		users         map[string]*entities.User
+		expectedError error
+		revoked       bool
+	}{
+		{
+			name: "active user gets tokens",
+			users: map[string]*entities.User{
+				testsConfig.RefreshToken.GUID: {Status: entities.UserStatusActive},
+			},
+		},
+		{
+			name: "locked user keeps session",
+			users: map[string]*entities.User{
+				testsConfig.RefreshToken.GUID: {Status: entities.UserStatusLocked},
+			},
+			expectedError: customerrors.UserLockedError{},
+		},
+		{
+			name: "blocked user loses session",
+			users: map[string]*entities.User{
+				testsConfig.RefreshToken.GUID: {Status: entities.UserStatusBlocked},
+			},
+			expectedError: customerrors.UserDisabledError{Status: entities.UserStatusBlocked},
+			revoked:       true,
+		},
+		{
+			name:          "unknown user",
+			users:         map[string]*entities.User{},
+			expectedError: customerrors.UserNotFoundError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			liveRefreshToken := &entities.RefreshToken{
+				ID:   1,
+				GUID: testsConfig.RefreshToken.GUID,
+				TTL:  time.Now().Add(time.Hour),
+			}
+
+			authRepository := &mocks.MockedAuthRepository{
+				RefreshTokensStorage: map[int]*entities.RefreshToken{liveRefreshToken.ID: liveRefreshToken},
+			}
+
+			useCases := &usecases.CommonUseCases{
+				AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+				UsersService: &services.CommonUsersService{
+					UsersRepository: &mocks.MockedUsersRepository{UsersStorage: tc.users},
+				},
+				HashCost:  testsConfig.HashCost,
+				JWTConfig: testsConfig.JWT,
+				Logger:    logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+			}
+
+			tokens, err := useCases.CreateTokens(
+				context.Background(),
+				entities.CreateTokensDTO{
+					GUID: testsConfig.RefreshToken.GUID,
+					IP:   testsConfig.IP,
+				},
+			)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Nil(t, tokens)
+
+			_, err = authRepository.GetRefreshTokenByID(context.Background(), liveRefreshToken.ID)
+			if tc.revoked {
+				assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Access tokens of revoked session are denied:
+			assert.Equal(t, tc.revoked, !authRepository.DenylistStorage[testsConfig.RefreshToken.GUID].IsZero())
+		})
+	}
+}
+
+func TestUseCasesRevokeDisabledUsersSessions(t *testing.T) {
+	const blockedGUID, deletedGUID, activeGUID = "blockedGUID", "deletedGUID", "activeGUID"
+
+	newLiveRefreshToken := func(id int, guid string) *entities.RefreshToken {
+		return &entities.RefreshToken{ID: id, GUID: guid, TTL: time.Now().Add(time.Hour)}
+	}
+
+	authRepository := &mocks.MockedAuthRepository{
+		RefreshTokensStorage: map[int]*entities.RefreshToken{
+			1: newLiveRefreshToken(1, blockedGUID),
+			2: newLiveRefreshToken(2, deletedGUID),
+			3: newLiveRefreshToken(3, activeGUID),
+		},
+		DisabledUsers: map[string]bool{blockedGUID: true, deletedGUID: true},
+	}
+
+	eventPublisher := &publishermocks.MockedEventPublisher{}
+	useCases := &usecases.CommonUseCases{
+		AuthService:    &services.CommonAuthService{AuthRepository: authRepository},
+		JWTConfig:      testsConfig.JWT,
+		EventPublisher: eventPublisher,
+		Logger:         logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+	}
+
+	t.Run("sessions of disabled users are revoked and access tokens are denied", func(t *testing.T) {
+		revoked, err := useCases.RevokeDisabledUsersSessions(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, revoked)
+		assert.Len(t, eventPublisher.Events(), 2)
+
+		for _, guid := range []string{blockedGUID, deletedGUID} {
+			_, err = authRepository.GetRefreshTokenByGUID(context.Background(), guid)
+			assert.IsType(t, customerrors.RefreshTokenNotFoundError{}, err)
+			assert.False(t, authRepository.DenylistStorage[guid].IsZero())
+		}
+
+		_, err = authRepository.GetRefreshTokenByGUID(context.Background(), activeGUID)
+		assert.NoError(t, err)
+		assert.True(t, authRepository.DenylistStorage[activeGUID].IsZero())
+	})
+
+	t.Run("access tokens are denied only once", func(t *testing.T) {
+		deniedBefore := authRepository.DenylistStorage[blockedGUID]
+
+		revoked, err := useCases.RevokeDisabledUsersSessions(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, revoked)
+		assert.Len(t, eventPublisher.Events(), 2)
+		assert.Equal(t, deniedBefore, authRepository.DenylistStorage[blockedGUID])
+	})
+
+	t.Run("database error is returned", func(t *testing.T) {
+		dbConnector := testlifespan.StartUpMemory(t)
+		dbConnector.CloseConnection()
+
+		failingUseCases := *useCases
+		failingUseCases.AuthService = &services.CommonAuthService{
+			AuthRepository: &repositories.CommonAuthRepository{DBConnector: dbConnector},
+		}
+
+		_, err := failingUseCases.RevokeDisabledUsersSessions(context.Background())
+		assert.Error(t, err)
+	})
+}
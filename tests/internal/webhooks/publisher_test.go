@@ -0,0 +1,186 @@
+package webhooks__test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/requestid"
+	"github.com/DKhorkov/medods/internal/security"
+	"github.com/DKhorkov/medods/internal/webhooks"
+	testconfig "github.com/DKhorkov/medods/tests/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testsConfig = testconfig.New()
+
+var testEvent = entities.SecurityEvent{
+	ID:         "b3f6c5e2-6d8e-4f5a-9a43-2b0f0d7a1c11",
+	Type:       entities.TokenIssuedEvent,
+	GUID:       testsConfig.RefreshToken.GUID,
+	IP:         testsConfig.IP,
+	OccurredAt: time.Now().UTC().Truncate(time.Second),
+}
+
+func TestWebhooksPublisherPublish(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("deliver signed event", func(t *testing.T) {
+		const secret = "webhookSecret"
+
+		received := make(chan entities.SecurityEvent, 1)
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					body, err := io.ReadAll(request.Body)
+					assert.NoError(t, err)
+
+					assert.Equal(t, "someRequestID", request.Header.Get(requestid.Header))
+
+					timestamp, err := strconv.ParseInt(request.Header.Get(webhooks.TimestampHeader), 10, 64)
+					assert.NoError(t, err)
+					assert.True(
+						t,
+						security.ValidatePayloadSignature(
+							secret,
+							timestamp,
+							body,
+							request.Header.Get(webhooks.SignatureHeader),
+						),
+					)
+
+					assert.Equal(t, testEvent.Type, request.Header.Get(webhooks.EventHeader))
+					assert.Equal(t, testEvent.ID, request.Header.Get(webhooks.DeliveryHeader))
+
+					var event entities.SecurityEvent
+					assert.NoError(t, json.Unmarshal(body, &event))
+					received <- event
+				},
+			),
+		)
+
+		defer server.Close()
+
+		webhooksConfig := testsConfig.Webhooks
+		webhooksConfig.Subscriptions = []config.WebhookSubscription{
+			{URL: server.URL, Secret: secret, Events: []string{entities.TokenIssuedEvent}},
+		}
+
+		publisher := webhooks.NewPublisher(webhooksConfig, logger)
+		publisher.Publish(requestid.NewContext(context.Background(), "someRequestID"), testEvent)
+		publisher.Stop()
+
+		select {
+		case event := <-received:
+			assert.Equal(t, testEvent, event)
+		default:
+			t.Fatal("event was not delivered")
+		}
+
+		statuses := publisher.Statuses()
+		require.Len(t, statuses, 1)
+		assert.Equal(t, 1, statuses[0].Delivered)
+		assert.Equal(t, 0, statuses[0].Failed)
+		assert.Equal(t, statuses, publisher.Status())
+	})
+
+	t.Run("event is not delivered to endpoint without subscription", func(t *testing.T) {
+		var requestsCount atomic.Int32
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					requestsCount.Add(1)
+				},
+			),
+		)
+
+		defer server.Close()
+
+		webhooksConfig := testsConfig.Webhooks
+		webhooksConfig.Subscriptions = []config.WebhookSubscription{
+			{URL: server.URL, Secret: "webhookSecret", Events: []string{entities.SessionRevokedEvent}},
+		}
+
+		publisher := webhooks.NewPublisher(webhooksConfig, logger)
+		publisher.Publish(context.Background(), testEvent)
+		publisher.Stop()
+
+		assert.Equal(t, int32(0), requestsCount.Load())
+		assert.Empty(t, publisher.Statuses())
+	})
+
+	t.Run("failed delivery is retried and tracked", func(t *testing.T) {
+		var requestsCount atomic.Int32
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					if requestsCount.Add(1) == 1 {
+						writer.WriteHeader(http.StatusServiceUnavailable)
+					}
+				},
+			),
+		)
+
+		defer server.Close()
+
+		webhooksConfig := testsConfig.Webhooks
+		webhooksConfig.Subscriptions = []config.WebhookSubscription{
+			{URL: server.URL, Secret: "webhookSecret", Events: []string{entities.AllSecurityEventsWildcard}},
+		}
+
+		publisher := webhooks.NewPublisher(webhooksConfig, logger)
+		publisher.Publish(context.Background(), testEvent)
+
+		assert.Eventually(
+			t,
+			func() bool {
+				return requestsCount.Load() == 2
+			},
+			time.Second,
+			time.Millisecond*10,
+		)
+
+		publisher.Stop()
+		statuses := publisher.Statuses()
+		require.Len(t, statuses, 1)
+		assert.Equal(t, 1, statuses[0].Delivered)
+		assert.Equal(t, 1, statuses[0].Failed)
+		assert.Equal(t, 0, statuses[0].ConsecutiveFailures)
+		assert.NotEmpty(t, statuses[0].LastError)
+	})
+
+	t.Run("events are not published after stop", func(t *testing.T) {
+		var requestsCount atomic.Int32
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					requestsCount.Add(1)
+				},
+			),
+		)
+
+		defer server.Close()
+
+		webhooksConfig := testsConfig.Webhooks
+		webhooksConfig.Subscriptions = []config.WebhookSubscription{
+			{URL: server.URL, Secret: "webhookSecret", Events: []string{entities.AllSecurityEventsWildcard}},
+		}
+
+		publisher := webhooks.NewPublisher(webhooksConfig, logger)
+		publisher.Stop()
+		publisher.Publish(context.Background(), testEvent)
+		publisher.Stop()
+
+		assert.Equal(t, int32(0), requestsCount.Load())
+	})
+}
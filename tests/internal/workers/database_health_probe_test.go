@@ -0,0 +1,76 @@
+package workers__test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkersDatabaseHealthProbe(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+	healthProbeConfig := config.DatabaseHealthProbeConfig{Interval: time.Millisecond * 20, Timeout: time.Second}
+
+	t.Run("database is unhealthy until probed", func(t *testing.T) {
+		connection, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+		defer connection.Close()
+
+		probe := workers.NewDatabaseHealthProbe(
+			&database.CommonDBConnector{Connection: connection},
+			healthProbeConfig,
+			logger,
+		)
+
+		assert.False(t, probe.Health().Healthy)
+		require.NoError(t, probe.Probe(context.Background()))
+
+		health := probe.Health()
+		assert.True(t, health.Healthy)
+		assert.False(t, health.CheckedAt.IsZero())
+		assert.Empty(t, health.Error)
+	})
+
+	t.Run("database becomes unhealthy, when it is unavailable", func(t *testing.T) {
+		connection, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+
+		probe := workers.NewDatabaseHealthProbe(
+			&database.CommonDBConnector{Connection: connection},
+			healthProbeConfig,
+			logger,
+		)
+
+		go probe.Run()
+		defer probe.Stop()
+
+		require.Eventually(t, func() bool { return probe.Health().Healthy }, time.Second, time.Millisecond*10)
+		require.NoError(t, connection.Close())
+		require.Eventually(t, func() bool { return !probe.Health().Healthy }, time.Second, time.Millisecond*10)
+		assert.NotEmpty(t, probe.Health().Error)
+	})
+
+	t.Run("zero interval is replaced with default", func(t *testing.T) {
+		connection, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+		defer connection.Close()
+
+		probe := workers.NewDatabaseHealthProbe(
+			&database.CommonDBConnector{Connection: connection},
+			config.DatabaseHealthProbeConfig{Timeout: time.Second},
+			logger,
+		)
+
+		go probe.Run()
+		defer probe.Stop()
+
+		require.Eventually(t, func() bool { return probe.Health().Healthy }, time.Second, time.Millisecond*10)
+	})
+}
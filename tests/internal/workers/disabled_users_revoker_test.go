@@ -0,0 +1,128 @@
+package workers__test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/services"
+	"github.com/DKhorkov/medods/internal/usecases"
+	"github.com/DKhorkov/medods/internal/workers"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDisabledUsersTestUseCases returns use cases over in-memory database with active session of blocked test user.
+func newDisabledUsersTestUseCases(t *testing.T) (*usecases.CommonUseCases, *repositories.CommonAuthRepository) {
+	t.Helper()
+
+	dbConnector := testlifespan.StartUpMemory(t)
+	now := time.Now().UTC()
+	_, err := dbConnector.GetConnection().Exec(
+		`
+			INSERT INTO users (guid, email, status, locale, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		testsConfig.RefreshToken.GUID,
+		"example@yandex.ru",
+		entities.UserStatusBlocked,
+		"ru",
+		now,
+		now,
+	)
+
+	require.NoError(t, err)
+
+	authRepository := &repositories.CommonAuthRepository{DBConnector: dbConnector}
+	_, err = authRepository.CreateRefreshToken(
+		context.Background(),
+		entities.CreateRefreshTokenDTO{
+			GUID:             testsConfig.RefreshToken.GUID,
+			Value:            testsConfig.RefreshToken.Value,
+			TTL:              time.Now().Add(time.Hour),
+			SessionStartedAt: time.Now(),
+		},
+	)
+
+	require.NoError(t, err)
+
+	useCases := &usecases.CommonUseCases{
+		AuthService: &services.CommonAuthService{AuthRepository: authRepository},
+		JWTConfig:   testsConfig.JWT,
+		Logger:      logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+	}
+
+	return useCases, authRepository
+}
+
+func TestWorkersDisabledUsersRevoker(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("sessions of disabled users are revoked periodically", func(t *testing.T) {
+		useCases, authRepository := newDisabledUsersTestUseCases(t)
+		revoker := workers.NewDisabledUsersRevoker(useCases, time.Millisecond*10, logger)
+
+		stopped := make(chan struct{})
+		go func() {
+			revoker.Run()
+			close(stopped)
+		}()
+
+		require.Eventually(
+			t,
+			func() bool {
+				_, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+				return err != nil
+			},
+			time.Second,
+			time.Millisecond*10,
+		)
+
+		revoker.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("revoker has not been stopped")
+		}
+	})
+
+	t.Run("zero interval disables periodic revocation", func(t *testing.T) {
+		useCases, authRepository := newDisabledUsersTestUseCases(t)
+		revoker := workers.NewDisabledUsersRevoker(useCases, 0, logger)
+
+		stopped := make(chan struct{})
+		go func() {
+			revoker.Run()
+			close(stopped)
+		}()
+
+		time.Sleep(time.Millisecond * 50)
+		_, err := authRepository.GetRefreshTokenByGUID(context.Background(), testsConfig.RefreshToken.GUID)
+		assert.NoError(t, err)
+
+		revoked, err := revoker.Revoke(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, revoked)
+
+		revoker.Stop()
+		revoker.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("revoker has not been stopped")
+		}
+	})
+
+	t.Run("failure is returned", func(t *testing.T) {
+		useCases, authRepository := newDisabledUsersTestUseCases(t)
+		authRepository.DBConnector.CloseConnection()
+
+		revoker := workers.NewDisabledUsersRevoker(useCases, 0, logger)
+		_, err := revoker.Revoke(context.Background())
+		assert.Error(t, err)
+	})
+}
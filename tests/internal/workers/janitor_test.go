@@ -0,0 +1,203 @@
+package workers__test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/workers"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertRefreshToken(t *testing.T, connection *sql.DB, id int, ttl time.Time, deletedAt *time.Time) {
+	t.Helper()
+
+	_, err := connection.Exec(
+		`
+			INSERT INTO refresh_tokens (id, guid, value, ttl, deleted_at, created_at) 
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		id,
+		// Each refresh token belongs to its own user, since only one refresh token of user may be active:
+		fmt.Sprintf("%s-%d", testsConfig.RefreshToken.GUID, id),
+		fmt.Sprintf("value%d", id),
+		ttl.UTC(),
+		deletedAt,
+		ttl.Add(-testsConfig.JWT.RefreshTokenTTL).UTC(),
+	)
+
+	require.NoError(t, err)
+}
+
+func insertOutboxMessage(t *testing.T, connection *sql.DB, id, status string, updatedAt time.Time) {
+	t.Helper()
+
+	_, err := connection.Exec(
+		`
+			INSERT INTO notifications_outbox (id, payload, status, next_attempt_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		id,
+		"{}",
+		status,
+		updatedAt.UTC(),
+		updatedAt.UTC(),
+		updatedAt.UTC(),
+	)
+
+	require.NoError(t, err)
+}
+
+func TestWorkersJanitorPurge(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("purge expired and long revoked refresh tokens in batches", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		authRepository := &repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		now := time.Now()
+		longAgo := now.Add(-48 * time.Hour).UTC()
+		recently := now.Add(-time.Hour).UTC()
+		insertRefreshToken(t, connection, 1, now.Add(-time.Minute), nil)    // expired
+		insertRefreshToken(t, connection, 2, now.Add(-time.Hour), nil)      // expired
+		insertRefreshToken(t, connection, 3, now.Add(time.Hour), &longAgo)  // revoked long ago
+		insertRefreshToken(t, connection, 4, now.Add(time.Hour), &recently) // revoked within retention
+		insertRefreshToken(t, connection, 5, now.Add(time.Hour), nil)       // active
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "someGUID", longAgo, recently))
+
+		outboxRepository := &repositories.CommonOutboxRepository{DBConnector: authRepository.DBConnector}
+		insertOutboxMessage(t, connection, "delivered", entities.OutboxMessageDelivered, longAgo)
+		insertOutboxMessage(t, connection, "dead", entities.OutboxMessageDead, longAgo)
+		insertOutboxMessage(t, connection, "recent", entities.OutboxMessageDelivered, recently)
+		insertOutboxMessage(t, connection, "pending", entities.OutboxMessagePending, longAgo)
+
+		janitor := workers.NewJanitor(
+			authRepository,
+			outboxRepository,
+			config.JanitorConfig{Interval: time.Hour, Retention: 24 * time.Hour, BatchSize: 2},
+			logger,
+		)
+
+		purged, err := janitor.Purge(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, workers.JanitorPurged{RefreshTokens: 3, Denials: 1, Outbox: 2}, purged)
+
+		var ids []int
+		rows, err := connection.Query(`SELECT id FROM refresh_tokens ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+
+		assert.Equal(t, []int{4, 5}, ids)
+
+		stats := janitor.Stats()
+		assert.Equal(t, 1, stats.Runs)
+		assert.Equal(t, 0, stats.Failures)
+		assert.Equal(t, 3, stats.PurgedRefreshTokens)
+		assert.Equal(t, 2, stats.PurgedOutbox)
+		assert.False(t, stats.LastRunAt.IsZero())
+	})
+
+	t.Run("failure is counted", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		authRepository := &repositories.CommonAuthRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		testlifespan.TearDown(t, connection)
+
+		janitor := workers.NewJanitor(
+			authRepository,
+			&repositories.CommonOutboxRepository{DBConnector: authRepository.DBConnector},
+			config.JanitorConfig{Interval: time.Hour, BatchSize: 10},
+			logger,
+		)
+
+		_, err := janitor.Purge(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, 1, janitor.Stats().Failures)
+		assert.NotEmpty(t, janitor.Stats().LastError)
+	})
+}
+
+func TestWorkersJanitorZeroConfig(t *testing.T) {
+	t.Run("zero interval disables periodic purge and zero batch size is replaced", func(t *testing.T) {
+		authRepository := &repositories.CommonAuthRepository{DBConnector: testlifespan.StartUpMemory(t)}
+		now := time.Now()
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "someGUID", now, now.Add(time.Hour)))
+		require.NoError(t, authRepository.DenyAccessTokens(context.Background(), "otherGUID", now, now))
+
+		janitor := workers.NewJanitor(
+			authRepository,
+			&repositories.CommonOutboxRepository{DBConnector: authRepository.DBConnector},
+			config.JanitorConfig{},
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		stopped := make(chan struct{})
+		go func() {
+			janitor.Run()
+			close(stopped)
+		}()
+
+		purged, err := janitor.Purge(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged.Denials)
+		assert.Equal(t, 1, janitor.Stats().Runs)
+
+		janitor.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("janitor has not been stopped")
+		}
+	})
+}
+
+func TestWorkersJanitorStop(t *testing.T) {
+	t.Run("stop running janitor", func(t *testing.T) {
+		janitor := workers.NewJanitor(
+			nil,
+			nil,
+			config.JanitorConfig{Interval: time.Hour, BatchSize: 10},
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		stopped := make(chan struct{})
+		go func() {
+			janitor.Run()
+			close(stopped)
+		}()
+
+		janitor.Stop()
+		janitor.Stop()
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("janitor has not been stopped")
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package workers__test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/workers"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkersMemorySnapshotter(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	runSnapshotter := func(
+		t *testing.T,
+		snapshotInterval time.Duration,
+	) (*workers.MemorySnapshotter, string, chan struct{}) {
+		t.Helper()
+
+		snapshotPath := filepath.Join(t.TempDir(), "medods.snapshot")
+		snapshotter := workers.NewMemorySnapshotter(
+			testlifespan.StartUpMemory(t),
+			config.MemoryStoreConfig{SnapshotPath: snapshotPath, SnapshotInterval: snapshotInterval},
+			logger,
+		)
+
+		stopped := make(chan struct{})
+		go func() {
+			snapshotter.Run()
+			close(stopped)
+		}()
+
+		return snapshotter, snapshotPath, stopped
+	}
+
+	t.Run("snapshots are saved periodically", func(t *testing.T) {
+		snapshotter, snapshotPath, _ := runSnapshotter(t, time.Millisecond*10)
+		defer snapshotter.Stop()
+
+		require.Eventually(
+			t,
+			func() bool {
+				_, err := os.Stat(snapshotPath)
+				return err == nil
+			},
+			time.Second,
+			time.Millisecond*10,
+		)
+	})
+
+	t.Run("zero interval disables periodic snapshots, while snapshot is saved on stop", func(t *testing.T) {
+		snapshotter, snapshotPath, stopped := runSnapshotter(t, 0)
+
+		time.Sleep(time.Millisecond * 50)
+		_, err := os.Stat(snapshotPath)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		snapshotter.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("snapshotter has not been stopped")
+		}
+
+		_, err = os.Stat(snapshotPath)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing is saved without snapshot path", func(t *testing.T) {
+		snapshotter := workers.NewMemorySnapshotter(
+			testlifespan.StartUpMemory(t),
+			config.MemoryStoreConfig{SnapshotInterval: time.Millisecond * 10},
+			logger,
+		)
+
+		assert.NoError(t, snapshotter.Snapshot(context.Background()))
+		snapshotter.Stop()
+		snapshotter.Run()
+	})
+
+	t.Run("failure is returned", func(t *testing.T) {
+		snapshotter := workers.NewMemorySnapshotter(
+			testlifespan.StartUpMemory(t),
+			config.MemoryStoreConfig{SnapshotPath: filepath.Join(t.TempDir(), "missing", "medods.snapshot")},
+			logger,
+		)
+
+		assert.Error(t, snapshotter.Snapshot(context.Background()))
+	})
+}
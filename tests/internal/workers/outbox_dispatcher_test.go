@@ -0,0 +1,299 @@
+package workers__test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DKhorkov/hmtm-sso/pkg/logging"
+	"github.com/DKhorkov/medods/internal/config"
+	"github.com/DKhorkov/medods/internal/database"
+	"github.com/DKhorkov/medods/internal/entities"
+	mocks "github.com/DKhorkov/medods/internal/mocks/notifiers"
+	"github.com/DKhorkov/medods/internal/repositories"
+	"github.com/DKhorkov/medods/internal/workers"
+	testconfig "github.com/DKhorkov/medods/tests/config"
+	testlifespan "github.com/DKhorkov/medods/tests/internal/repositories/lifespan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testsConfig = testconfig.New()
+
+var testNotification = entities.Notification{
+	Recipients: []string{"example@yandex.ru"},
+	Subject:    "Test subject",
+	Body:       "Test body",
+}
+
+func TestWorkersOutboxDispatcherDispatchPending(t *testing.T) {
+	logger := logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath)
+
+	t.Run("successfully deliver pending message", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		id, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		notifier := &mocks.MockedNotifier{}
+		dispatcher := workers.NewOutboxDispatcher(outboxRepository, notifier, testsConfig.Outbox, logger)
+
+		assert.Equal(t, 1, dispatcher.DispatchPending(context.Background()))
+		require.Len(t, notifier.Notifications(), 1)
+		assert.Equal(t, id, notifier.Notifications()[0].ID)
+
+		// Delivered message should not be delivered again:
+		assert.Equal(t, 0, dispatcher.DispatchPending(context.Background()))
+		assert.Len(t, notifier.Notifications(), 1)
+	})
+
+	t.Run("failed message is retried with backoff", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		_, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		notifier := &mocks.MockedNotifier{Err: errors.New("smtp is down")}
+		dispatcher := workers.NewOutboxDispatcher(outboxRepository, notifier, testsConfig.Outbox, logger)
+
+		assert.Equal(t, 0, dispatcher.DispatchPending(context.Background()))
+
+		var (
+			attempts      int
+			status        string
+			nextAttemptAt time.Time
+		)
+
+		err = connection.QueryRow(
+			`
+				SELECT attempts, status, next_attempt_at
+				FROM notifications_outbox
+			`,
+		).Scan(&attempts, &status, &nextAttemptAt)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, entities.OutboxMessagePending, status)
+		assert.True(t, nextAttemptAt.After(time.Now()))
+	})
+
+	t.Run("message is dead-lettered after max attempts", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		_, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		outboxConfig := config.OutboxConfig{
+			BatchSize:   testsConfig.Outbox.BatchSize,
+			MaxAttempts: 2,
+			Lease:       testsConfig.Outbox.Lease,
+		}
+
+		notifier := &mocks.MockedNotifier{Err: errors.New("smtp is down")}
+		dispatcher := workers.NewOutboxDispatcher(outboxRepository, notifier, outboxConfig, logger)
+
+		// Zero backoff makes message available for the next attempt immediately:
+		for range outboxConfig.MaxAttempts {
+			assert.Equal(t, 0, dispatcher.DispatchPending(context.Background()))
+		}
+
+		var (
+			attempts int
+			status   string
+		)
+
+		err = connection.QueryRow(
+			`
+				SELECT attempts, status
+				FROM notifications_outbox
+			`,
+		).Scan(&attempts, &status)
+
+		require.NoError(t, err)
+		assert.Equal(t, outboxConfig.MaxAttempts, attempts)
+		assert.Equal(t, entities.OutboxMessageDead, status)
+	})
+}
+
+func TestWorkersOutboxDispatcherRun(t *testing.T) {
+	t.Run("deliver messages in background until stopped", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		notifier := &mocks.MockedNotifier{}
+		dispatcher := workers.NewOutboxDispatcher(
+			outboxRepository,
+			notifier,
+			testsConfig.Outbox,
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		stopped := make(chan struct{})
+		go func() {
+			dispatcher.Run()
+			close(stopped)
+		}()
+
+		_, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		assert.Eventually(
+			t,
+			func() bool {
+				return len(notifier.Notifications()) == 1
+			},
+			time.Second,
+			testsConfig.Outbox.PollInterval,
+		)
+
+		dispatcher.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("dispatcher was not stopped")
+		}
+	})
+}
+
+func TestWorkersOutboxDispatcherZeroConfig(t *testing.T) {
+	t.Run("zero poll interval and batch size are replaced with defaults", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		outboxConfig := testsConfig.Outbox
+		outboxConfig.PollInterval = 0
+		outboxConfig.BatchSize = 0
+
+		notifier := &mocks.MockedNotifier{}
+		dispatcher := workers.NewOutboxDispatcher(
+			outboxRepository,
+			notifier,
+			outboxConfig,
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		stopped := make(chan struct{})
+		go func() {
+			dispatcher.Run()
+			close(stopped)
+		}()
+
+		_, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dispatcher.DispatchPending(context.Background()))
+
+		dispatcher.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("dispatcher was not stopped")
+		}
+	})
+}
+
+// blockingNotifier notifies started on delivery and waits for release. Delivery fails, if its context is done.
+type blockingNotifier struct {
+	mocks.MockedNotifier
+	started chan struct{}
+	release chan struct{}
+}
+
+func (notifier *blockingNotifier) Notify(ctx context.Context, notification entities.Notification) error {
+	close(notifier.started)
+	<-notifier.release
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return notifier.MockedNotifier.Notify(ctx, notification)
+}
+
+func TestWorkersOutboxDispatcherStop(t *testing.T) {
+	t.Run("stop waits for delivery in progress", func(t *testing.T) {
+		connection := testlifespan.StartUp(t)
+		defer testlifespan.TearDown(t, connection)
+
+		outboxRepository := &repositories.CommonOutboxRepository{
+			DBConnector: &database.CommonDBConnector{
+				Connection: connection,
+			},
+		}
+
+		notifier := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{})}
+		dispatcher := workers.NewOutboxDispatcher(
+			outboxRepository,
+			notifier,
+			testsConfig.Outbox,
+			logging.GetInstance(testsConfig.Logging.Level, testsConfig.Logging.LogFilePath),
+		)
+
+		_, err := outboxRepository.CreateOutboxMessage(context.Background(), testNotification)
+		require.NoError(t, err)
+
+		go dispatcher.Run()
+		<-notifier.started
+
+		stopped := make(chan struct{})
+		go func() {
+			dispatcher.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			t.Fatal("dispatcher was stopped before delivery completed")
+		case <-time.After(testsConfig.Outbox.PollInterval * 2):
+		}
+
+		close(notifier.release)
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("dispatcher was not stopped")
+		}
+
+		assert.Len(t, notifier.Notifications(), 1)
+	})
+
+	t.Run("run after stop returns immediately", func(t *testing.T) {
+		dispatcher := workers.NewOutboxDispatcher(nil, nil, testsConfig.Outbox, nil)
+		dispatcher.Stop()
+		dispatcher.Run()
+	})
+}